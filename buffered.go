@@ -0,0 +1,98 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 预生成缓冲的雪花算法
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+	"gorm.io/gorm"
+)
+
+// BufferedSnowflake 预生成ID的雪花算法
+// 后台goroutine持续将ID写入缓冲区，Generate直接从缓冲区读取，调用方不会同步竞争节点锁或等待时钟
+// 注意：缓冲区中的ID在生成时即确定了时间戳，空闲时间越长，取出的ID时间戳越滞后
+type BufferedSnowflake struct {
+	node   *Wrapper
+	buffer chan snowflake.ID
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewBufferedSnowflake 创建一个预生成缓冲的雪花算法
+// @param bufferSize 缓冲区大小
+// @return *BufferedSnowflake
+// @return error
+func NewBufferedSnowflake(ctx context.Context, db *gorm.DB, name string, port int, acceptableClockDrift,
+	nodeIdContentionInterval time.Duration, logger nodeidgorm.Logger, bufferSize int,
+	opts ...OptionFn) (*BufferedSnowflake, error) {
+	if bufferSize <= 0 {
+		return nil, errors.New("buffer size must be greater than 0")
+	}
+
+	node, err := NewSnowflake(ctx, db, name, port, acceptableClockDrift, nodeIdContentionInterval, logger, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	b := &BufferedSnowflake{
+		node:   node,
+		buffer: make(chan snowflake.ID, bufferSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go b.fill(ctx)
+	return b, nil
+}
+
+// fill 持续生成ID写入缓冲区，直到context结束
+// 单一生产者保证缓冲区中的ID严格递增
+func (b *BufferedSnowflake) fill(ctx context.Context) {
+	defer close(b.done)
+	defer close(b.buffer)
+
+	for {
+		id := b.node.Generate()
+		select {
+		case b.buffer <- id:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Generate 从缓冲区取出一个ID，仅在缓冲区为空时阻塞
+// 取出的ID的时间戳是写入缓冲区时的时间，而不是调用时的时间；不感知Pause，维护窗口内也会返回ID
+// 关闭后先取完缓冲区中剩余的ID，之后直接由节点生成，此时节点ID已释放，与Wrapper关闭后调用Generate相同，不应再调用
+// @return snowflake.ID
+func (b *BufferedSnowflake) Generate() snowflake.ID {
+	if id, ok := <-b.buffer; ok {
+		return id
+	}
+	return b.node.Generate()
+}
+
+// Close 停止后台生成goroutine并等待其退出，再关闭内部的雪花算法，释放节点ID并停止时间同步器
+// 缓冲区中剩余的ID仍可被取出
+// @return error
+func (b *BufferedSnowflake) Close() error {
+	b.closeOnce.Do(func() {
+		b.cancel()
+		<-b.done
+		b.closeErr = b.node.Close()
+	})
+	return b.closeErr
+}
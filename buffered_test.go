@@ -0,0 +1,132 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 预生成缓冲的雪花算法测试
+package snowflake
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBufferedSnowflake 创建测试用的预生成缓冲雪花算法
+func newTestBufferedSnowflake(t *testing.T, bufferSize int) *BufferedSnowflake {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	sf, err := NewBufferedSnowflake(ctx, setupTestDB(t), "test_buffered", 8080, time.Second, 5*time.Second,
+		logger, bufferSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sf.Close() })
+	return sf
+}
+
+// TestNewBufferedSnowflake_InvalidBufferSize 测试缓冲区大小校验
+func TestNewBufferedSnowflake_InvalidBufferSize(t *testing.T) {
+	_, err := NewBufferedSnowflake(context.Background(), setupTestDB(t), "test_buffered", 8080, time.Second,
+		5*time.Second, logger, 0)
+	assert.Error(t, err)
+}
+
+// TestBufferedSnowflake_Generate_Ordered 测试单个调用方取出的ID严格递增
+func TestBufferedSnowflake_Generate_Ordered(t *testing.T) {
+	sf := newTestBufferedSnowflake(t, 128)
+
+	last := sf.Generate()
+	for i := 0; i < 10000; i++ {
+		id := sf.Generate()
+		assert.Greater(t, id.Int64(), last.Int64())
+		last = id
+	}
+}
+
+// TestBufferedSnowflake_Generate_Unique 测试并发取出的ID唯一
+func TestBufferedSnowflake_Generate_Unique(t *testing.T) {
+	sf := newTestBufferedSnowflake(t, 128)
+
+	const workers, perWorker = 8, 5000
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		ids = make(map[int64]struct{}, workers*perWorker)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := sf.Generate().Int64()
+				mu.Lock()
+				ids[id] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, ids, workers*perWorker)
+}
+
+// TestBufferedSnowflake_Close 测试关闭后缓冲区被取完，之后仍可生成唯一ID
+func TestBufferedSnowflake_Close(t *testing.T) {
+	sf := newTestBufferedSnowflake(t, 16)
+
+	// 等待缓冲区填满
+	assert.Eventually(t, func() bool { return len(sf.buffer) == cap(sf.buffer) }, time.Second, time.Millisecond)
+	require.NoError(t, sf.Close())
+	require.NoError(t, sf.Close())
+
+	// 后台goroutine已退出
+	select {
+	case <-sf.done:
+	default:
+		t.Fatal("fill goroutine is still running")
+	}
+
+	ids := make(map[int64]struct{})
+	for i := 0; i < cap(sf.buffer)*2; i++ {
+		ids[sf.Generate().Int64()] = struct{}{}
+	}
+	assert.Len(t, ids, cap(sf.buffer)*2)
+	assert.Len(t, sf.buffer, 0)
+}
+
+// TestBufferedSnowflake_Close_Release 测试关闭后释放内部雪花算法的节点ID记录与进程内注册
+func TestBufferedSnowflake_Close_Release(t *testing.T) {
+	db := setupTestDB(t)
+	sf, err := NewBufferedSnowflake(context.Background(), db, "test_buffered_release", 8080, time.Second,
+		5*time.Second, logger, 16)
+	require.NoError(t, err)
+	sf.Generate()
+
+	tab := dao.Use(db).SnowflakeKv
+	count := func() int64 {
+		n, err := tab.WithContext(context.Background()).
+			Where(tab.Key.Eq(nodeidgorm.GetNodeIdKey("test_buffered_release", 8080))).Count()
+		require.NoError(t, err)
+		return n
+	}
+	require.Equal(t, int64(1), count())
+
+	var closer io.Closer = sf
+	require.NoError(t, closer.Close())
+	assert.Zero(t, count())
+	assert.NoError(t, closer.Close())
+
+	// 进程内注册已注销，严格模式下以相同身份重新创建不会返回ErrKeyInUse
+	again, err := NewBufferedSnowflake(context.Background(), db, "test_buffered_release", 8080, time.Second,
+		5*time.Second, logger, 16, WithNodeIdOptions(nodeidgorm.WithStrictUniqueness(true)))
+	require.NoError(t, err)
+	require.NoError(t, again.Close())
+}
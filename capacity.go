@@ -0,0 +1,74 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 位布局容量
+package snowflake
+
+import (
+	"math"
+	"time"
+)
+
+// CapacityInfo 位布局的理论容量
+type CapacityInfo struct {
+	// MaxIDsPerMillisecond 单个节点每毫秒最多生成的ID数量
+	MaxIDsPerMillisecond int64
+	// MaxIDsPerSecond 单个节点每秒最多生成的ID数量
+	MaxIDsPerSecond int64
+	// MaxNodes 最大节点数量
+	MaxNodes int64
+	// TimestampBits 时间戳位数
+	TimestampBits uint8
+	// Lifetime 从纪元开始时间戳可表示的时长，超过time.Duration的表示范围（约292年，时间戳位数不少于44）时为math.MaxInt64
+	Lifetime time.Duration
+	// Exhausted 时间戳溢出的时间
+	Exhausted time.Time
+}
+
+// Capacity 计算位布局的理论容量，纯计算，不修改全局位布局
+// 雪花ID的时间戳固定为毫秒，持久化时间戳的单位不影响容量
+// @param layout 未配置时使用DefaultLayout，应先通过Validate校验
+// @return CapacityInfo
+func Capacity(layout Layout) CapacityInfo {
+	if layout.IsZero() {
+		layout = DefaultLayout
+	}
+
+	// 最高位为符号位，剩余的位数全部留给时间戳
+	timestampBits := 63 - layout.NodeBits - layout.StepBits
+	lifetime := int64(math.MaxInt64)
+	if timestampBits < 63 {
+		lifetime = int64(1) << timestampBits
+	}
+	duration := time.Duration(math.MaxInt64)
+	if lifetime <= int64(math.MaxInt64/time.Millisecond) {
+		duration = time.Duration(lifetime) * time.Millisecond
+	}
+	perMillisecond := int64(1) << layout.StepBits
+	return CapacityInfo{
+		MaxIDsPerMillisecond: perMillisecond,
+		MaxIDsPerSecond:      perMillisecond * 1000,
+		MaxNodes:             int64(1) << layout.NodeBits,
+		TimestampBits:        timestampBits,
+		Lifetime:             duration,
+		// 分别累加秒与毫秒，纪元与时长相加不会溢出int64
+		Exhausted: time.Unix(layout.Epoch/1000+lifetime/1000,
+			(layout.Epoch%1000+lifetime%1000)*int64(time.Millisecond)),
+	}
+}
+
+// MaxIDsPerMillisecond 按snowflake包当前的全局位布局，单个节点每毫秒最多生成的ID数量
+// 修改序列号位数后随之变化，可用于容量规划评估是否需要调整位布局
+// @return int
+func MaxIDsPerMillisecond() int {
+	return int(Capacity(Layout{}.resolve()).MaxIDsPerMillisecond)
+}
+
+// MaxIDsPerSecond 按snowflake包当前的全局位布局，单个节点每秒最多生成的ID数量
+// @return int
+func MaxIDsPerSecond() int {
+	return int(Capacity(Layout{}.resolve()).MaxIDsPerSecond)
+}
@@ -0,0 +1,71 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 位布局容量测试
+package snowflake
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCapacity_DefaultLayout 测试默认位布局的容量
+func TestCapacity_DefaultLayout(t *testing.T) {
+	info := Capacity(DefaultLayout)
+
+	assert.Equal(t, int64(4096), info.MaxIDsPerMillisecond)
+	assert.Equal(t, int64(4096000), info.MaxIDsPerSecond)
+	assert.Equal(t, int64(nodeid.MaxNodes), info.MaxNodes)
+	assert.Equal(t, uint8(41), info.TimestampBits)
+	// 约69.7年
+	years := info.Lifetime.Hours() / 24 / 365.25
+	assert.InDelta(t, 69.7, years, 0.1)
+	// twitter纪元 + 2^41毫秒
+	assert.Equal(t, time.Date(2080, 7, 10, 17, 30, 30, 209000000, time.UTC), info.Exhausted.UTC())
+
+	assert.Equal(t, info, Capacity(Layout{}))
+}
+
+// TestCapacity_CustomLayout 测试自定义位布局的容量
+func TestCapacity_CustomLayout(t *testing.T) {
+	info := Capacity(Layout{Epoch: 0, NodeBits: 5, StepBits: 12})
+
+	assert.Equal(t, int64(4096), info.MaxIDsPerMillisecond)
+	assert.Equal(t, int64(32), info.MaxNodes)
+	// 未使用的位留给时间戳
+	assert.Equal(t, uint8(46), info.TimestampBits)
+	assert.Equal(t, time.UnixMilli(int64(1)<<46), info.Exhausted)
+}
+
+// TestCapacity_LongLifetime 测试时间戳位数超出time.Duration表示范围时时长被截断，溢出时间仍按毫秒精确计算
+func TestCapacity_LongLifetime(t *testing.T) {
+	info := Capacity(Layout{Epoch: DefaultLayout.Epoch, NodeBits: 5, StepBits: 5})
+
+	assert.Equal(t, uint8(53), info.TimestampBits)
+	assert.Equal(t, time.Duration(math.MaxInt64), info.Lifetime)
+	assert.Equal(t, time.UnixMilli(DefaultLayout.Epoch+int64(1)<<53), info.Exhausted)
+
+	// 时间戳位数为43时恰好不溢出
+	info = Capacity(Layout{Epoch: 0, NodeBits: 10, StepBits: 10})
+	assert.Equal(t, time.Duration(int64(1)<<43)*time.Millisecond, info.Lifetime)
+	assert.Equal(t, time.UnixMilli(int64(1)<<43), info.Exhausted)
+}
+
+// TestMaxIDs 测试生成上限跟随全局序列号位数变化
+func TestMaxIDs(t *testing.T) {
+	assert.Equal(t, 4096, MaxIDsPerMillisecond())
+	assert.Equal(t, 4096000, MaxIDsPerSecond())
+
+	previous := Layout{}.resolve()
+	defer previous.apply()
+	Layout{Epoch: DefaultLayout.Epoch, NodeBits: 12, StepBits: 10}.apply()
+	assert.Equal(t, 1024, MaxIDsPerMillisecond())
+	assert.Equal(t, 1024000, MaxIDsPerSecond())
+}
@@ -0,0 +1,171 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 配置
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidConfig 配置校验失败
+	ErrInvalidConfig = errors.New("invalid snowflake config")
+	// ErrIncompatibleConfig 两份配置生成的ID不兼容
+	ErrIncompatibleConfig = errors.New("incompatible snowflake config")
+)
+
+// Config 雪花算法配置，可由viper、envconfig等配置加载器填充
+type Config struct {
+	// DB 数据库连接，无法由配置加载器填充，需要手动设置
+	DB *gorm.DB `json:"-" yaml:"-" mapstructure:"-"`
+	// CoordinationDB 节点ID注册表所在的协调数据库，为空时使用DB，需要手动设置
+	CoordinationDB *gorm.DB `json:"-" yaml:"-" mapstructure:"-"`
+	// Name 服务名称，用于生成节点ID Key
+	Name string `json:"name" yaml:"name" mapstructure:"name"`
+	// Port 服务端口，用于生成节点ID Key
+	Port int `json:"port" yaml:"port" mapstructure:"port"`
+	// AcceptableClockDrift 可接受的时钟回拨容忍时间
+	AcceptableClockDrift time.Duration `json:"acceptable_clock_drift" yaml:"acceptable_clock_drift" mapstructure:"acceptable_clock_drift"`
+	// NodeIdContentionInterval 节点ID抢占时间间隔
+	NodeIdContentionInterval time.Duration `json:"node_id_contention_interval" yaml:"node_id_contention_interval" mapstructure:"node_id_contention_interval"`
+	// SyncInterval 时间同步器写入数据库的间隔，0表示使用DefaultSyncInterval
+	SyncInterval time.Duration `json:"sync_interval" yaml:"sync_interval" mapstructure:"sync_interval"`
+	// TimeUnit 持久化时间戳的单位 millis/seconds，默认millis
+	TimeUnit string `json:"time_unit" yaml:"time_unit" mapstructure:"time_unit"`
+	// Encoding GenerateString使用的编码，默认decimal
+	Encoding Encoding `json:"encoding" yaml:"encoding" mapstructure:"encoding"`
+	// Layout 位布局，未配置的字段使用snowflake包当前的全局设置
+	Layout Layout `json:"layout" yaml:"layout" mapstructure:"layout"`
+	// DatacenterBits 节点ID中数据中心ID的位数，0表示不划分数据中心
+	DatacenterBits uint8 `json:"datacenter_bits" yaml:"datacenter_bits" mapstructure:"datacenter_bits"`
+	// DatacenterID 数据中心ID，DatacenterBits大于0时生效
+	DatacenterID int64 `json:"datacenter_id" yaml:"datacenter_id" mapstructure:"datacenter_id"`
+}
+
+// Validate 校验配置
+// @return error
+func (c Config) Validate() error {
+	var errs []string
+	if c.DB == nil && c.CoordinationDB == nil {
+		errs = append(errs, "db is required")
+	}
+	if c.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Sprintf("port %d is out of range", c.Port))
+	}
+	if c.AcceptableClockDrift < 0 {
+		errs = append(errs, "acceptable clock drift must not be negative")
+	}
+	if c.NodeIdContentionInterval <= 0 {
+		errs = append(errs, "node id contention interval must be positive")
+	}
+	if c.SyncInterval < 0 {
+		errs = append(errs, "sync interval must not be negative")
+	}
+	if _, err := nodeidgorm.ParseTimeUnit(c.TimeUnit); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := c.Encoding.Validate(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if !c.Layout.IsZero() {
+		if err := c.Layout.resolve().Validate(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if c.DatacenterBits > 0 {
+		if nodeBits := c.Layout.resolve().NodeBits; c.DatacenterBits >= nodeBits {
+			errs = append(errs, fmt.Sprintf("datacenter bits %d must be less than node bits %d", c.DatacenterBits, nodeBits))
+		} else if c.DatacenterID < 0 || c.DatacenterID >= 1<<c.DatacenterBits {
+			errs = append(errs, fmt.Sprintf("datacenter id %d is out of range", c.DatacenterID))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// NewSnowflakeFromConfig 根据配置创建一个雪花算法
+// 配置了位布局时会修改snowflake包的全局位布局，未配置的字段沿用全局设置，创建失败时恢复
+// @param cfg
+// @return *Wrapper
+// @return error
+func NewSnowflakeFromConfig(ctx context.Context, cfg Config, logger nodeidgorm.Logger, opts ...OptionFn) (*Wrapper, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	previous := Layout{}.resolve()
+	if !cfg.Layout.IsZero() {
+		cfg.Layout.resolve().apply()
+	}
+
+	timeUnit, _ := nodeidgorm.ParseTimeUnit(cfg.TimeUnit)
+	defaults := []OptionFn{
+		WithNodeIdOptions(nodeidgorm.WithTimeUnit(timeUnit)),
+		WithEncoding(cfg.Encoding),
+		WithSyncInterval(cfg.SyncInterval),
+	}
+	if cfg.CoordinationDB != nil {
+		defaults = append(defaults, WithCoordinationDB(cfg.CoordinationDB))
+	}
+	if cfg.DatacenterBits > 0 {
+		defaults = append(defaults, WithDatacenterBits(cfg.DatacenterBits), WithDatacenterID(cfg.DatacenterID))
+	}
+	opts = append(defaults, opts...)
+	sf, err := NewSnowflake(ctx, cfg.DB, cfg.Name, cfg.Port, cfg.AcceptableClockDrift, cfg.NodeIdContentionInterval,
+		logger, opts...)
+	if err != nil {
+		// 创建失败时恢复全局位布局
+		previous.apply()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// CompatibleConfig 校验两份配置生成的ID是否兼容，即纪元、节点位数、序列号位数与时间单位一致
+// 可在灰度发布配置变更前校验新旧配置，保证新旧实例生成的ID可比较；未配置位布局时按snowflake包当前的全局设置比较
+// @param a
+// @param b
+// @return error 不兼容时返回包装了ErrIncompatibleConfig的错误，列出每一项不一致的配置
+func CompatibleConfig(a, b Config) error {
+	var errs []string
+	layoutA, layoutB := a.Layout.resolve(), b.Layout.resolve()
+	if layoutA.Epoch != layoutB.Epoch {
+		errs = append(errs, fmt.Sprintf("epoch %d != %d", layoutA.Epoch, layoutB.Epoch))
+	}
+	if layoutA.NodeBits != layoutB.NodeBits {
+		errs = append(errs, fmt.Sprintf("node bits %d != %d", layoutA.NodeBits, layoutB.NodeBits))
+	}
+	if layoutA.StepBits != layoutB.StepBits {
+		errs = append(errs, fmt.Sprintf("step bits %d != %d", layoutA.StepBits, layoutB.StepBits))
+	}
+
+	unitA, errA := nodeidgorm.ParseTimeUnit(a.TimeUnit)
+	unitB, errB := nodeidgorm.ParseTimeUnit(b.TimeUnit)
+	switch {
+	case errA != nil || errB != nil:
+		errs = append(errs, fmt.Sprintf("time unit %q != %q", a.TimeUnit, b.TimeUnit))
+	case unitA != unitB:
+		errs = append(errs, fmt.Sprintf("time unit %s != %s", unitA, unitB))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s", ErrIncompatibleConfig, strings.Join(errs, "; "))
+	}
+	return nil
+}
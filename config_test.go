@@ -0,0 +1,213 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 配置测试
+package snowflake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// restoreLayout 测试结束后恢复snowflake包的全局位布局
+func restoreLayout(t *testing.T) {
+	layout := Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits}
+	t.Cleanup(layout.apply)
+}
+
+// validConfig 一份完整的测试配置
+func validConfig(t *testing.T) Config {
+	return Config{
+		DB:                       setupTestDB(t),
+		Name:                     "test_config",
+		Port:                     8080,
+		AcceptableClockDrift:     time.Second,
+		NodeIdContentionInterval: 5 * time.Second,
+		TimeUnit:                 "seconds",
+		Encoding:                 EncodingBase58,
+		Layout:                   Layout{Epoch: 1700000000000, NodeBits: 8, StepBits: 14},
+	}
+}
+
+// TestNewSnowflakeFromConfig 测试根据完整配置创建雪花算法
+func TestNewSnowflakeFromConfig(t *testing.T) {
+	restoreLayout(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := validConfig(t)
+	sf, err := NewSnowflakeFromConfig(ctx, cfg, logger)
+	require.NoError(t, err)
+
+	// 位布局已生效
+	id := sf.Generate()
+	assert.Less(t, id.Node(), int64(1)<<cfg.Layout.NodeBits)
+	assert.InDelta(t, time.Now().UnixMilli(), id.Time(), float64(time.Minute.Milliseconds()))
+	assert.Equal(t, id.Time()-cfg.Layout.Epoch, id.Int64()>>(cfg.Layout.NodeBits+cfg.Layout.StepBits))
+
+	// 编码已生效
+	encoded := sf.GenerateString()
+	parsed, err := snowflake.ParseBase58([]byte(encoded))
+	require.NoError(t, err)
+	assert.Greater(t, parsed.Int64(), id.Int64())
+
+	// 时间单位已生效，持久化的时间戳为秒
+	var saved int64
+	require.NoError(t, cfg.DB.Table("snowflake_kv").Select("time").
+		Where("`key` LIKE ?", cfg.Name+"%").Scan(&saved).Error)
+	assert.InDelta(t, time.Now().Unix(), saved, 5)
+}
+
+// TestNewSnowflakeFromConfig_PartialLayout 测试只配置位数时纪元沿用全局设置
+func TestNewSnowflakeFromConfig_PartialLayout(t *testing.T) {
+	restoreLayout(t)
+	DefaultLayout.apply()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := validConfig(t)
+	cfg.Layout = Layout{NodeBits: 12, StepBits: 10}
+	sf, err := NewSnowflakeFromConfig(ctx, cfg, logger)
+	require.NoError(t, err)
+	assert.Equal(t, Layout{Epoch: DefaultLayout.Epoch, NodeBits: 12, StepBits: 10},
+		Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits})
+	assert.InDelta(t, time.Now().UnixMilli(), sf.Generate().Time(), float64(time.Minute.Milliseconds()))
+
+	// 未配置的序列号位数沿用全局设置后一并校验
+	DefaultLayout.apply()
+	cfg.Layout = Layout{NodeBits: 14}
+	err = cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceed a total of 22 bits")
+}
+
+// TestNewSnowflakeFromConfig_RestoreLayout 测试创建失败时恢复全局位布局
+func TestNewSnowflakeFromConfig_RestoreLayout(t *testing.T) {
+	restoreLayout(t)
+	DefaultLayout.apply()
+
+	// 未迁移表结构，分配节点ID失败
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "empty.db")))
+	require.NoError(t, err)
+	cfg := validConfig(t)
+	cfg.DB = db
+	_, err = NewSnowflakeFromConfig(context.Background(), cfg, logger)
+	require.Error(t, err)
+	assert.Equal(t, DefaultLayout, Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits})
+}
+
+// TestConfig_Unmarshal 测试从配置文件加载
+func TestConfig_Unmarshal(t *testing.T) {
+	var cfg Config
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"name": "svc", "port": 8080,
+		"acceptable_clock_drift": 1000000000, "node_id_contention_interval": 5000000000,
+		"time_unit": "millis", "encoding": "base32",
+		"layout": {"epoch": 1288834974657, "node_bits": 10, "step_bits": 12}
+	}`), &cfg))
+	cfg.DB = setupTestDB(t)
+
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, DefaultLayout, cfg.Layout)
+	assert.Equal(t, EncodingBase32, cfg.Encoding)
+	assert.Equal(t, time.Second, cfg.AcceptableClockDrift)
+}
+
+// TestConfig_Validate 测试配置校验失败
+func TestConfig_Validate(t *testing.T) {
+	cases := map[string]func(cfg *Config){
+		"db is required":              func(cfg *Config) { cfg.DB = nil },
+		"name is required":            func(cfg *Config) { cfg.Name = "" },
+		"port 0 is out of range":      func(cfg *Config) { cfg.Port = 0 },
+		"port 70000 is out of range":  func(cfg *Config) { cfg.Port = 70000 },
+		"acceptable clock drift":      func(cfg *Config) { cfg.AcceptableClockDrift = -time.Second },
+		"node id contention interval": func(cfg *Config) { cfg.NodeIdContentionInterval = 0 },
+		`unknown time unit "minutes"`: func(cfg *Config) { cfg.TimeUnit = "minutes" },
+		`unknown encoding "hex"`:      func(cfg *Config) { cfg.Encoding = "hex" },
+		"exceed a total of 22 bits":   func(cfg *Config) { cfg.Layout.StepBits = 20 },
+		"node bits and step bits":     func(cfg *Config) { cfg.Layout.NodeBits, cfg.Layout.StepBits = 250, 10 },
+		"epoch must not be negative":  func(cfg *Config) { cfg.Layout.Epoch = -1 },
+	}
+
+	for expected, mutate := range cases {
+		t.Run(expected, func(t *testing.T) {
+			cfg := validConfig(t)
+			mutate(&cfg)
+
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidConfig))
+			assert.Contains(t, err.Error(), expected)
+
+			_, err = NewSnowflakeFromConfig(context.Background(), cfg, logger)
+			assert.True(t, errors.Is(err, ErrInvalidConfig))
+		})
+	}
+
+	assert.NoError(t, validConfig(t).Validate())
+}
+
+// TestCompatibleConfig 测试两份配置的兼容性校验
+func TestCompatibleConfig(t *testing.T) {
+	base := validConfig(t)
+	base.Layout, base.TimeUnit = DefaultLayout, ""
+	assert.NoError(t, CompatibleConfig(base, base))
+
+	// 编码等不影响ID的配置不参与比较，默认时间单位与millis等价
+	other := base
+	other.Encoding, other.Port, other.TimeUnit = EncodingBase58, 9090, "millis"
+	assert.NoError(t, CompatibleConfig(base, other))
+
+	cases := []struct {
+		name   string
+		mutate func(c *Config)
+		expect string
+	}{
+		{"epoch", func(c *Config) { c.Layout.Epoch++ }, "epoch 1288834974657 != 1288834974658"},
+		{"node bits", func(c *Config) { c.Layout.NodeBits, c.Layout.StepBits = 8, 14 }, "node bits 10 != 8"},
+		{"step bits", func(c *Config) { c.Layout.StepBits = 10 }, "step bits 12 != 10"},
+		{"time unit", func(c *Config) { c.TimeUnit = "seconds" }, "time unit millis != seconds"},
+		{"unknown time unit", func(c *Config) { c.TimeUnit = "hours" }, `time unit "" != "hours"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			changed := base
+			c.mutate(&changed)
+			err := CompatibleConfig(base, changed)
+			assert.True(t, errors.Is(err, ErrIncompatibleConfig))
+			assert.Contains(t, err.Error(), c.expect)
+		})
+	}
+
+	// 多项不一致时全部列出
+	changed := base
+	changed.Layout.Epoch, changed.TimeUnit = base.Layout.Epoch+1, "s"
+	err := CompatibleConfig(base, changed)
+	assert.Contains(t, err.Error(), "epoch")
+	assert.Contains(t, err.Error(), "time unit")
+}
+
+// TestCompatibleConfig_ZeroLayout 测试未配置位布局时按全局位布局比较
+func TestCompatibleConfig_ZeroLayout(t *testing.T) {
+	restoreLayout(t)
+	DefaultLayout.apply()
+
+	implicit := validConfig(t)
+	implicit.Layout = Layout{}
+	explicit := implicit
+	explicit.Layout = DefaultLayout
+	assert.NoError(t, CompatibleConfig(implicit, explicit))
+}
@@ -0,0 +1,85 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 数据中心ID
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/bwmarrin/snowflake"
+)
+
+const (
+	// EnvDatacenterBits 节点ID中数据中心ID的位数，由ConfigFromEnv读取
+	EnvDatacenterBits = "SNOWFLAKE_DATACENTER_BITS"
+	// EnvDatacenterID 数据中心ID，由ConfigFromEnv读取；配置了WithDatacenterBits但未设置WithDatacenterID时同样读取
+	EnvDatacenterID = "SNOWFLAKE_DATACENTER_ID"
+)
+
+// ErrInvalidDatacenter 数据中心配置无效
+var ErrInvalidDatacenter = errors.New("invalid snowflake datacenter")
+
+// resolveDatacenter 解析数据中心ID并计算其工作节点ID范围，配置了类型前缀时数据中心ID位于类型前缀之后
+// @param typeBits 类型前缀的位数
+// @param typeValue 类型前缀
+// @param bits 数据中心ID的位数
+// @param id 数据中心ID，小于0时从环境变量读取
+// @return int64 数据中心ID
+// @return nodeid.NodeIdRange 数据中心的节点ID范围
+// @return error
+func resolveDatacenter(typeBits uint8, typeValue int64, bits uint8, id int64) (int64, nodeid.NodeIdRange, error) {
+	if int(typeBits)+int(bits) >= int(snowflake.NodeBits) {
+		return 0, nodeid.NodeIdRange{}, fmt.Errorf("%w: datacenter bits %d plus type bits %d must be less than node bits %d",
+			ErrInvalidDatacenter, bits, typeBits, snowflake.NodeBits)
+	}
+	if id < 0 {
+		value, ok := os.LookupEnv(EnvDatacenterID)
+		if !ok {
+			return 0, nodeid.NodeIdRange{}, fmt.Errorf("%w: datacenter id is required, set WithDatacenterID or %s",
+				ErrInvalidDatacenter, EnvDatacenterID)
+		}
+		var err error
+		if id, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, nodeid.NodeIdRange{}, fmt.Errorf("%w: %s=%q is not a valid datacenter id",
+				ErrInvalidDatacenter, EnvDatacenterID, value)
+		}
+	}
+	if id < 0 || id >= 1<<bits {
+		return 0, nodeid.NodeIdRange{}, fmt.Errorf("%w: datacenter id %d is out of range [0, %d]",
+			ErrInvalidDatacenter, id, int64(1)<<bits-1)
+	}
+
+	restBits := snowflake.NodeBits - typeBits
+	workerBits := restBits - bits
+	first := typeValue<<restBits | id<<workerBits
+	return id, nodeid.NodeIdRange{Min: first, Max: first + 1<<workerBits - 1}, nil
+}
+
+// ParseIDWithDatacenterBits 按snowflake包当前的全局位布局拆分雪花ID，并将节点ID拆分为数据中心ID与工作节点ID
+// @param id
+// @param datacenterBits 节点ID中数据中心ID的位数，0时Datacenter为0，Worker与Node相同
+// @return ParsedID
+func ParseIDWithDatacenterBits(id snowflake.ID, datacenterBits uint8) ParsedID {
+	return ParseIDWithTypeBits(id, 0, datacenterBits)
+}
+
+// Parse 拆分雪花ID，配置了WithTypePrefix、WithDatacenterBits时同时拆分类型前缀、数据中心ID与工作节点ID
+// @param id
+// @return ParsedID
+func (w *Wrapper) Parse(id snowflake.ID) ParsedID {
+	return ParseIDWithTypeBits(id, w.typeBits, w.datacenterBits)
+}
+
+// DatacenterID 数据中心ID，未配置WithDatacenterBits时返回0
+// @return int64
+func (w *Wrapper) DatacenterID() int64 {
+	return w.datacenterId
+}
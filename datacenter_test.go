@@ -0,0 +1,106 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 数据中心ID测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_Datacenter 测试节点ID由数据中心ID与工作节点ID组成，Parse能正确拆分
+func TestWrapper_Datacenter(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_datacenter", 8080, time.Second, 5*time.Second,
+		logger, WithDatacenterBits(3), WithDatacenterID(5))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	workerBits := snowflake.NodeBits - 3
+	assert.Equal(t, int64(5), sf.DatacenterID())
+	assert.Equal(t, int64(5), sf.NodeId()>>workerBits)
+
+	parsed := sf.Parse(sf.Generate())
+	assert.Equal(t, sf.NodeId(), parsed.Node)
+	assert.Equal(t, int64(5), parsed.Datacenter)
+	assert.Equal(t, sf.NodeId()&(1<<workerBits-1), parsed.Worker)
+	assert.Equal(t, parsed.Datacenter<<workerBits|parsed.Worker, parsed.Node)
+}
+
+// TestWrapper_Datacenter_Injected 测试注入的分配器分配的节点ID作为工作节点ID，数据中心ID从环境变量读取
+func TestWrapper_Datacenter_Injected(t *testing.T) {
+	setEnv(t, map[string]string{EnvDatacenterID: "2"})
+	worker, err := nodeid.NewHashNodeIdAllocator("test_datacenter").Alloc()
+	require.NoError(t, err)
+
+	sf, err := NewSnowflake(context.Background(), nil, "test_datacenter", 8080, time.Second, 5*time.Second, logger,
+		WithDatacenterBits(3), WithAllocator(nodeid.NewHashNodeIdAllocator("test_datacenter")),
+		WithSynchronizer(&memSynchronizer{}))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	workerBits := snowflake.NodeBits - 3
+	assert.Equal(t, int64(2)<<workerBits|worker%(1<<workerBits), sf.NodeId())
+	parsed := sf.Parse(sf.Generate())
+	assert.Equal(t, int64(2), parsed.Datacenter)
+	assert.Equal(t, worker%(1<<workerBits), parsed.Worker)
+}
+
+// TestWrapper_Datacenter_Invalid 测试数据中心ID缺失或越界时创建失败
+func TestWrapper_Datacenter_Invalid(t *testing.T) {
+	setEnv(t, nil)
+	for _, opts := range [][]OptionFn{
+		{WithDatacenterBits(3)},
+		{WithDatacenterBits(3), WithDatacenterID(8)},
+		{WithDatacenterBits(snowflake.NodeBits), WithDatacenterID(0)},
+	} {
+		sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_datacenter", 8080, time.Second,
+			5*time.Second, logger, opts...)
+		assert.Nil(t, sf)
+		assert.True(t, errors.Is(err, ErrInvalidDatacenter))
+	}
+
+	cfg := Config{DB: setupTestDB(t), Name: "test_datacenter", Port: 8080, NodeIdContentionInterval: time.Second,
+		DatacenterBits: 3, DatacenterID: 8}
+	assert.True(t, errors.Is(cfg.Validate(), ErrInvalidConfig))
+}
+
+// TestParseIDWithDatacenterBits 测试拆分数据中心ID与工作节点ID
+func TestParseIDWithDatacenterBits(t *testing.T) {
+	workerBits := snowflake.NodeBits - 3
+	node := int64(6)<<workerBits | 42
+	id := snowflake.ID(int64(12345)<<(snowflake.NodeBits+snowflake.StepBits) | node<<snowflake.StepBits | 7)
+
+	parsed := ParseIDWithDatacenterBits(id, 3)
+	assert.Equal(t, node, parsed.Node)
+	assert.Equal(t, int64(6), parsed.Datacenter)
+	assert.Equal(t, int64(42), parsed.Worker)
+	assert.Equal(t, int64(7), parsed.Step)
+
+	parsed = ParseID(id)
+	assert.Equal(t, int64(0), parsed.Datacenter)
+	assert.Equal(t, node, parsed.Worker)
+}
+
+// TestConfigFromEnv_Datacenter 测试从环境变量加载数据中心配置
+func TestConfigFromEnv_Datacenter(t *testing.T) {
+	setEnv(t, map[string]string{EnvDatacenterBits: "3", EnvDatacenterID: "4"})
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(3), cfg.DatacenterBits)
+	assert.Equal(t, int64(4), cfg.DatacenterID)
+
+	setEnv(t, map[string]string{EnvDatacenterBits: "x"})
+	_, err = ConfigFromEnv()
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+}
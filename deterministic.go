@@ -0,0 +1,23 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 确定性ID
+package snowflake
+
+import (
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// DeterministicID 将业务key哈希为63位非负整数，相同的key总是得到相同的值，可作为幂等请求的稳定代理ID
+// 注意：与Generate生成的雪花ID无关，不包含时间戳与节点ID，不能按时间排序，不同的key存在极小概率的哈希冲突；
+// 与雪花ID写入同一列时可能重复，应使用独立的列
+// @param businessKey
+// @return int64
+func DeterministicID(businessKey string) int64 {
+	return int64(xxhash.Sum64String(businessKey) & math.MaxInt64)
+}
@@ -0,0 +1,32 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 确定性ID测试
+package snowflake
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDeterministicID 测试相同的业务key得到相同的非负ID，不同的key得到不同的ID
+func TestDeterministicID(t *testing.T) {
+	assert.Equal(t, DeterministicID("order:1001"), DeterministicID("order:1001"))
+	assert.NotEqual(t, DeterministicID("order:1001"), DeterministicID("order:1002"))
+
+	seen := make(map[int64]string)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("request-%d", i)
+		id := DeterministicID(key)
+		assert.GreaterOrEqual(t, id, int64(0), key)
+		previous, ok := seen[id]
+		assert.False(t, ok, "%s collides with %s", key, previous)
+		seen[id] = key
+	}
+	assert.GreaterOrEqual(t, DeterministicID(""), int64(0))
+}
@@ -0,0 +1,80 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 重复ID检测
+package snowflake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// recentIDs 进程内所有开启了重复检测的Wrapper共享的最近ID窗口
+// 同一进程中多个Wrapper被错误地配置为相同的节点ID时，也能发现彼此生成的重复ID
+var recentIDs = &idWindow{}
+
+// idWindow 固定容量的最近ID集合，超出容量后淘汰最早的ID
+type idWindow struct {
+	mu   sync.Mutex
+	size int
+	seen map[snowflake.ID]struct{}
+	ring []snowflake.ID
+	next int
+}
+
+// grow 扩大窗口容量，容量只增不减
+func (r *idWindow) grow(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.seen == nil {
+		r.seen = make(map[snowflake.ID]struct{}, size)
+	}
+	if size > r.size {
+		r.size = size
+	}
+}
+
+// observe 记录一个ID
+// @return bool ID是否已在窗口中
+func (r *idWindow) observe(id snowflake.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.seen[id]; ok {
+		return true
+	}
+	r.seen[id] = struct{}{}
+	if len(r.ring) < r.size {
+		r.ring = append(r.ring, id)
+		return false
+	}
+	delete(r.seen, r.ring[r.next])
+	r.ring[r.next] = id
+	r.next = (r.next + 1) % len(r.ring)
+	return false
+}
+
+// OnDuplicate 注册重复ID回调，配置了WithDuplicateDetection时生成的ID已在最近的窗口中出现过时同步调用
+// 未注册回调时发现重复直接panic；回调在Generate返回前执行，不应阻塞，重复注册时覆盖之前的回调
+// @param callback
+func (w *Wrapper) OnDuplicate(callback func(id snowflake.ID)) {
+	w.onDuplicate.Store(callback)
+}
+
+// checkDuplicate 检查ID是否与最近生成的ID重复
+func (w *Wrapper) checkDuplicate(id snowflake.ID) {
+	if !w.detectDuplicate || !recentIDs.observe(id) {
+		return
+	}
+	if callback, _ := w.onDuplicate.Load().(func(id snowflake.ID)); callback != nil {
+		callback(id)
+		return
+	}
+	panic(fmt.Sprintf("snowflake generated a duplicate id %d, node id: %d", id, id.Node()))
+}
@@ -0,0 +1,77 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 重复ID检测测试
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetRecentIDs 使用空的共享窗口，避免之前的测试生成的ID造成误报
+func resetRecentIDs(t *testing.T) {
+	old := recentIDs
+	recentIDs = &idWindow{}
+	t.Cleanup(func() { recentIDs = old })
+}
+
+// TestWrapper_DuplicateDetection 测试两个Wrapper被配置为相同的节点ID时，同一毫秒内生成的重复ID被检测到
+func TestWrapper_DuplicateDetection(t *testing.T) {
+	resetRecentIDs(t)
+	newWrapper := func() *Wrapper {
+		sf, err := NewSnowflake(context.Background(), nil, "test_duplicate", 8080, time.Second, 5*time.Second, logger,
+			WithAllocator(fixedNodeIdAllocator(7)), WithSynchronizer(&memSynchronizer{}), WithDuplicateDetection(1024))
+		require.NoError(t, err)
+		return sf
+	}
+	first, second := newWrapper(), newWrapper()
+	defer first.Close()
+	defer second.Close()
+
+	var duplicates []snowflake.ID
+	first.OnDuplicate(func(id snowflake.ID) { duplicates = append(duplicates, id) })
+	second.OnDuplicate(func(id snowflake.ID) { duplicates = append(duplicates, id) })
+	// 两个节点各自的序列号都从0开始，同一毫秒内交替生成即产生重复ID
+	for i := 0; i < 100 && len(duplicates) == 0; i++ {
+		first.Generate()
+		second.Generate()
+	}
+	require.NotEmpty(t, duplicates)
+	assert.Equal(t, int64(7), duplicates[0].Node())
+}
+
+// TestWrapper_DuplicateDetection_Panic 测试未注册回调时发现重复ID直接panic，未开启检测时不检测
+func TestWrapper_DuplicateDetection_Panic(t *testing.T) {
+	resetRecentIDs(t)
+	sf, err := NewSnowflake(context.Background(), nil, "test_duplicate", 8080, time.Second, 5*time.Second, logger,
+		WithAllocator(fixedNodeIdAllocator(8)), WithSynchronizer(&memSynchronizer{}), WithDuplicateDetection(16))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	id := sf.Generate()
+	assert.Panics(t, func() { sf.checkDuplicate(id) })
+
+	sf.detectDuplicate = false
+	assert.NotPanics(t, func() { sf.checkDuplicate(id) })
+}
+
+// TestIdWindow 测试窗口满后淘汰最早的ID
+func TestIdWindow(t *testing.T) {
+	window := &idWindow{}
+	window.grow(2)
+	assert.False(t, window.observe(1))
+	assert.False(t, window.observe(2))
+	assert.True(t, window.observe(1))
+	assert.False(t, window.observe(3))
+	assert.False(t, window.observe(1))
+	assert.True(t, window.observe(3))
+}
@@ -0,0 +1,69 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake ID编码
+package snowflake
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Encoding 雪花ID的字符串编码
+type Encoding string
+
+const (
+	// EncodingDecimal 十进制，默认编码
+	EncodingDecimal Encoding = "decimal"
+	// EncodingBase2 二进制
+	EncodingBase2 Encoding = "base2"
+	// EncodingBase32 z-base-32
+	EncodingBase32 Encoding = "base32"
+	// EncodingBase36 三十六进制
+	EncodingBase36 Encoding = "base36"
+	// EncodingBase58 base58
+	EncodingBase58 Encoding = "base58"
+	// EncodingBase64 base64
+	EncodingBase64 Encoding = "base64"
+)
+
+// Validate 校验编码名称，空字符串为默认的十进制
+// @return error
+func (e Encoding) Validate() error {
+	switch e {
+	case "", EncodingDecimal, EncodingBase2, EncodingBase32, EncodingBase36, EncodingBase58, EncodingBase64:
+		return nil
+	default:
+		return fmt.Errorf("unknown encoding %q", string(e))
+	}
+}
+
+// Encode 按编码将雪花ID转换为字符串
+// @param id
+// @return string
+func (e Encoding) Encode(id snowflake.ID) string {
+	switch e {
+	case EncodingBase2:
+		return id.Base2()
+	case EncodingBase32:
+		return id.Base32()
+	case EncodingBase36:
+		return id.Base36()
+	case EncodingBase58:
+		return id.Base58()
+	case EncodingBase64:
+		return id.Base64()
+	default:
+		return id.String()
+	}
+}
+
+// GenerateString 生成一个雪花ID，并按配置的编码转换为字符串
+// @return string
+func (w *Wrapper) GenerateString() string {
+	return w.encoding.Encode(w.Generate())
+}
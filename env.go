@@ -0,0 +1,120 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 从环境变量加载配置
+package snowflake
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"gorm.io/gorm"
+)
+
+// 配置对应的环境变量
+const (
+	// EnvName 服务名称
+	EnvName = "SNOWFLAKE_NAME"
+	// EnvPort 服务端口
+	EnvPort = "SNOWFLAKE_PORT"
+	// EnvClockDrift 可接受的时钟回拨容忍时间，Go时长格式，如1s
+	EnvClockDrift = "SNOWFLAKE_CLOCK_DRIFT"
+	// EnvContentionInterval 节点ID抢占时间间隔，Go时长格式，如5s
+	EnvContentionInterval = "SNOWFLAKE_CONTENTION_INTERVAL"
+	// EnvSyncInterval 时间同步器写入数据库的间隔，Go时长格式，如1s
+	EnvSyncInterval = "SNOWFLAKE_SYNC_INTERVAL"
+	// EnvTimeUnit 持久化时间戳的单位 millis/seconds
+	EnvTimeUnit = "SNOWFLAKE_TIME_UNIT"
+	// EnvEncoding GenerateString使用的编码
+	EnvEncoding = "SNOWFLAKE_ENCODING"
+)
+
+const (
+	// DefaultAcceptableClockDrift 未设置SNOWFLAKE_CLOCK_DRIFT时的时钟回拨容忍时间
+	DefaultAcceptableClockDrift = time.Second
+	// DefaultNodeIdContentionInterval 未设置SNOWFLAKE_CONTENTION_INTERVAL时的节点ID抢占时间间隔
+	DefaultNodeIdContentionInterval = 5 * time.Second
+)
+
+// ConfigFromEnv 从环境变量加载配置，未设置的时长使用默认值
+// 只解析环境变量，不校验配置，DB需要手动设置后调用Validate或NewSnowflakeFromConfig
+// @return Config
+// @return error 环境变量无法解析时返回包装了ErrInvalidConfig的错误，列出每一个无法解析的变量
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Name:                     os.Getenv(EnvName),
+		AcceptableClockDrift:     DefaultAcceptableClockDrift,
+		NodeIdContentionInterval: DefaultNodeIdContentionInterval,
+		TimeUnit:                 os.Getenv(EnvTimeUnit),
+		Encoding:                 Encoding(os.Getenv(EnvEncoding)),
+	}
+
+	var errs []string
+	if value, ok := os.LookupEnv(EnvPort); ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s=%q is not a valid port", EnvPort, value))
+		}
+		cfg.Port = port
+	}
+	if value, ok := os.LookupEnv(EnvDatacenterBits); ok {
+		bits, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s=%q is not a valid bit count", EnvDatacenterBits, value))
+		}
+		cfg.DatacenterBits = uint8(bits)
+	}
+	if value, ok := os.LookupEnv(EnvDatacenterID); ok {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s=%q is not a valid datacenter id", EnvDatacenterID, value))
+		}
+		cfg.DatacenterID = id
+	}
+	durations := []struct {
+		key    string
+		target *time.Duration
+	}{
+		{EnvClockDrift, &cfg.AcceptableClockDrift},
+		{EnvContentionInterval, &cfg.NodeIdContentionInterval},
+		{EnvSyncInterval, &cfg.SyncInterval},
+	}
+	for _, d := range durations {
+		value, ok := os.LookupEnv(d.key)
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s=%q is not a valid duration", d.key, value))
+			continue
+		}
+		*d.target = duration
+	}
+
+	if len(errs) > 0 {
+		return cfg, fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(errs, "; "))
+	}
+	return cfg, nil
+}
+
+// NewSnowflakeFromEnv 从环境变量加载配置并创建一个雪花算法
+// @param db
+// @return *Wrapper
+// @return error 环境变量无法解析或配置校验失败时返回包装了ErrInvalidConfig的错误
+func NewSnowflakeFromEnv(ctx context.Context, db *gorm.DB, logger nodeidgorm.Logger, opts ...OptionFn) (*Wrapper, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.DB = db
+	return NewSnowflakeFromConfig(ctx, cfg, logger, opts...)
+}
@@ -0,0 +1,98 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 从环境变量加载配置测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv 设置环境变量，测试结束后恢复
+func setEnv(t *testing.T, values map[string]string) {
+	for _, key := range []string{EnvName, EnvPort, EnvClockDrift, EnvContentionInterval, EnvTimeUnit, EnvEncoding,
+		EnvSyncInterval, EnvDatacenterBits, EnvDatacenterID} {
+		key := key
+		old, ok := os.LookupEnv(key)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+		if value, set := values[key]; set {
+			os.Setenv(key, value)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+// TestConfigFromEnv 测试从环境变量解析时长与默认值
+func TestConfigFromEnv(t *testing.T) {
+	setEnv(t, map[string]string{
+		EnvName:               "test_env",
+		EnvPort:               "8080",
+		EnvClockDrift:         "1500ms",
+		EnvContentionInterval: "1m",
+		EnvTimeUnit:           "seconds",
+		EnvEncoding:           "base58",
+	})
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "test_env", cfg.Name)
+	assert.Equal(t, 8080, cfg.Port)
+	assert.Equal(t, 1500*time.Millisecond, cfg.AcceptableClockDrift)
+	assert.Equal(t, time.Minute, cfg.NodeIdContentionInterval)
+	assert.Equal(t, "seconds", cfg.TimeUnit)
+	assert.Equal(t, EncodingBase58, cfg.Encoding)
+
+	// 未设置时长时使用默认值
+	setEnv(t, map[string]string{EnvName: "test_env", EnvPort: "8080"})
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultAcceptableClockDrift, cfg.AcceptableClockDrift)
+	assert.Equal(t, DefaultNodeIdContentionInterval, cfg.NodeIdContentionInterval)
+
+	sf, err := NewSnowflakeFromEnv(context.Background(), setupTestDB(t), logger)
+	require.NoError(t, err)
+	defer sf.Close()
+	assert.NotZero(t, sf.Generate())
+}
+
+// TestConfigFromEnv_Invalid 测试无法解析的环境变量与校验失败
+func TestConfigFromEnv_Invalid(t *testing.T) {
+	setEnv(t, map[string]string{
+		EnvName:               "test_env",
+		EnvPort:               "http",
+		EnvClockDrift:         "1",
+		EnvContentionInterval: "five seconds",
+	})
+	_, err := ConfigFromEnv()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	assert.Contains(t, err.Error(), `SNOWFLAKE_PORT="http" is not a valid port`)
+	assert.Contains(t, err.Error(), `SNOWFLAKE_CLOCK_DRIFT="1" is not a valid duration`)
+	assert.Contains(t, err.Error(), `SNOWFLAKE_CONTENTION_INTERVAL="five seconds" is not a valid duration`)
+
+	// 可以解析但校验失败
+	setEnv(t, map[string]string{EnvName: "test_env", EnvPort: "8080", EnvClockDrift: "-1s", EnvContentionInterval: "0s"})
+	_, err = ConfigFromEnv()
+	require.NoError(t, err)
+	_, err = NewSnowflakeFromEnv(context.Background(), setupTestDB(t), logger)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	assert.Contains(t, err.Error(), "acceptable clock drift must not be negative")
+	assert.Contains(t, err.Error(), "node id contention interval must be positive")
+}
@@ -0,0 +1,80 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package main 优雅退出示例：收到SIGINT/SIGTERM后停止生成，写入最后的时间并释放节点ID
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm"
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dsn := flag.String("db", filepath.Join(os.TempDir(), "snowflake-graceful.db"), "sqlite database file")
+	name := flag.String("name", "graceful", "service name")
+	port := flag.Int("port", 10000, "service port")
+	interval := flag.Duration("interval", 100*time.Millisecond, "generate interval")
+	flag.Parse()
+
+	if err := run(*dsn, *name, *port, *interval); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dsn, name string, port int, interval time.Duration) error {
+	// 1. 收到退出信号时取消context
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := gorm.Open(sqlite.Open(dsn))
+	if err != nil {
+		return err
+	}
+
+	// 2. 创建雪花算法，WithAutoMigrate在协调数据库上创建snowflake_kv表
+	// 注意：这里使用context.Background()，时间同步器需要在收到信号后继续运行，由Close停止并写入最后的时间
+	sf, err := snowflake.NewSnowflake(context.Background(), db, name, port, time.Second, 5*time.Second,
+		&nodeidgorm.DefaultLogger{}, snowflake.WithAutoMigrate(true))
+	if err != nil {
+		return err
+	}
+	// 3. 退出前停止时间同步器并释放节点ID，节点ID可立即被其他实例使用
+	defer func() {
+		if err := sf.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "close failed:", err)
+			return
+		}
+		fmt.Println("node id released")
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			id, err := sf.GenerateContext(ctx)
+			if err != nil {
+				continue
+			}
+			fmt.Println("generate:", id)
+		case <-ctx.Done():
+			fmt.Println("shutting down")
+			return nil
+		}
+	}
+}
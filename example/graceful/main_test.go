@@ -0,0 +1,88 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package main 优雅退出示例冒烟测试
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestGraceful 构建并运行示例，发送SIGTERM后断言进程正常退出且节点ID已释放
+func TestGraceful(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the example")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGTERM is not supported on windows")
+	}
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "graceful")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	output, err := build.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	dsn := filepath.Join(dir, "graceful.db")
+	cmd := exec.Command(bin, "-db", dsn, "-interval", "10ms")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	// 等待开始生成
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	waitFor := func(prefix string) {
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case line, ok := <-lines:
+				require.True(t, ok, "output closed before %q", prefix)
+				if strings.HasPrefix(line, prefix) {
+					return
+				}
+			case <-timeout:
+				_ = cmd.Process.Kill()
+				t.Fatalf("timeout waiting for %q", prefix)
+			}
+		}
+	}
+	waitFor("generate:")
+
+	db, err := gorm.Open(sqlite.Open(dsn))
+	require.NoError(t, err)
+	var count int64
+	require.NoError(t, db.Model(&model.SnowflakeKv{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	require.NoError(t, cmd.Process.Signal(syscall.SIGTERM))
+	waitFor("node id released")
+	for range lines {
+	}
+	require.NoError(t, cmd.Wait())
+
+	require.NoError(t, db.Model(&model.SnowflakeKv{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
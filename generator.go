@@ -0,0 +1,37 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake ID生成器接口
+package snowflake
+
+import (
+	"context"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+var _ Generator = new(Wrapper)
+
+// Generator ID生成器，下游代码可依赖该接口并在测试中替换为mock实现
+type Generator interface {
+	// Generate 生成一个雪花ID
+	Generate() snowflake.ID
+	// GenerateContext 生成一个雪花ID，context已结束或暂停时返回错误
+	GenerateContext(ctx context.Context) (snowflake.ID, error)
+	// NodeId 当前生效的节点ID
+	NodeId() int64
+	// Close 停止生成并释放资源
+	Close() error
+}
+
+// NodeId 当前生效的节点ID，Refresh切换节点后返回新的节点ID
+// @return int64
+func (w *Wrapper) NodeId() int64 {
+	w.nodeMu.Lock()
+	defer w.nodeMu.Unlock()
+
+	return w.nodeId
+}
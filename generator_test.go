@@ -0,0 +1,65 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake ID生成器接口测试
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockGenerator 按顺序返回递增ID的mock生成器
+type mockGenerator struct {
+	next   snowflake.ID
+	closed bool
+}
+
+func (m *mockGenerator) Generate() snowflake.ID {
+	m.next++
+	return m.next
+}
+
+func (m *mockGenerator) GenerateContext(ctx context.Context) (snowflake.ID, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.Generate(), nil
+}
+
+func (m *mockGenerator) NodeId() int64 {
+	return 1
+}
+
+func (m *mockGenerator) Close() error {
+	m.closed = true
+	return nil
+}
+
+// TestGenerator 测试雪花算法与mock实现均满足Generator接口
+func TestGenerator(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_generator", 8080, time.Second,
+		5*time.Second, logger)
+	require.NoError(t, err)
+
+	generators := []Generator{sf, &mockGenerator{}}
+	for _, generator := range generators {
+		first := generator.Generate()
+		second, err := generator.GenerateContext(context.Background())
+		require.NoError(t, err)
+		assert.Greater(t, second.Int64(), first.Int64())
+		assert.GreaterOrEqual(t, generator.NodeId(), int64(0))
+		require.NoError(t, generator.Close())
+	}
+	assert.Equal(t, sf.allocator.(*nodeidgorm.NodeIdAllocator).NodeId(), sf.NodeId())
+	assert.True(t, generators[1].(*mockGenerator).closed)
+}
@@ -8,6 +8,7 @@ require (
 	github.com/bwmarrin/snowflake v0.3.0
 	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/glebarez/sqlite v1.11.0
+	github.com/oklog/ulid/v2 v2.1.0
 	github.com/stretchr/testify v1.8.0
 	go.uber.org/atomic v1.6.0
 	gorm.io/gen v0.3.26
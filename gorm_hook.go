@@ -0,0 +1,57 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake GORM创建回调
+package snowflake
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// GormCreateCallback 返回一个GORM创建回调，插入记录前为值为零的ID字段填充雪花ID
+// 字段需为整数类型，支持单条与批量插入，注册方式：
+//
+//	db.Callback().Create().Before("gorm:create").Register("snowflake:id", sf.GormCreateCallback("ID"))
+//
+// 暂停生成期间不填充ID，而是为语句添加ErrPaused
+// @param fieldName 结构体字段名或数据库列名
+// @return func(*gorm.DB)
+func (w *Wrapper) GormCreateCallback(fieldName string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Schema == nil {
+			return
+		}
+		field := db.Statement.Schema.LookUpField(fieldName)
+		if field == nil {
+			return
+		}
+
+		ctx := db.Statement.Context
+		fill := func(rv reflect.Value) {
+			if _, isZero := field.ValueOf(ctx, rv); isZero {
+				id, err := w.GenerateContext(ctx)
+				if err != nil {
+					_ = db.AddError(err)
+					return
+				}
+				if err := field.Set(ctx, rv, id.Int64()); err != nil {
+					_ = db.AddError(err)
+				}
+			}
+		}
+
+		switch rv := db.Statement.ReflectValue; rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				fill(rv.Index(i))
+			}
+		case reflect.Struct:
+			fill(rv)
+		}
+	}
+}
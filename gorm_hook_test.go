@@ -0,0 +1,66 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake GORM创建回调测试
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOrder 测试用的业务模型
+type testOrder struct {
+	ID   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name string
+}
+
+// TestWrapper_GormCreateCallback 测试插入时自动填充雪花ID
+func TestWrapper_GormCreateCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := setupTestDB(t)
+	require.NoError(t, db.Migrator().DropTable(&testOrder{}))
+	require.NoError(t, db.AutoMigrate(&testOrder{}))
+
+	sf, err := NewSnowflake(ctx, db, "test_hook", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	require.NoError(t, db.Callback().Create().Before("gorm:create").
+		Register("snowflake:id", sf.GormCreateCallback("ID")))
+	defer func() { _ = db.Callback().Create().Remove("snowflake:id") }()
+
+	// 单条插入：零值ID被填充
+	order := &testOrder{Name: "single"}
+	require.NoError(t, db.Create(order).Error)
+	assert.Greater(t, order.ID, int64(0))
+	id := snowflake.ParseInt64(order.ID)
+	assert.InDelta(t, time.Now().UnixMilli(), id.Time(), float64(time.Minute.Milliseconds()))
+
+	var saved testOrder
+	require.NoError(t, db.First(&saved, order.ID).Error)
+	assert.Equal(t, "single", saved.Name)
+
+	// 批量插入：每条记录都被填充且唯一
+	orders := []*testOrder{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	require.NoError(t, db.Create(&orders).Error)
+	ids := make(map[int64]struct{})
+	for _, o := range orders {
+		assert.Greater(t, o.ID, order.ID)
+		ids[o.ID] = struct{}{}
+	}
+	assert.Len(t, ids, len(orders))
+
+	// 已指定的ID保持不变
+	fixed := &testOrder{ID: 42, Name: "fixed"}
+	require.NoError(t, db.Create(fixed).Error)
+	assert.Equal(t, int64(42), fixed.ID)
+}
@@ -0,0 +1,65 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 注入节点ID分配器与时间同步器
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrNilDB 未传入数据库，且未同时注入节点ID分配器与时间同步器
+var ErrNilDB = errors.New("snowflake db is nil")
+
+// nodeIdRecorder 记录分配器最近一次分配的节点ID，雪花节点不暴露其节点ID
+type nodeIdRecorder struct {
+	snowflake.NodeIdAllocator
+	nodeId int64
+}
+
+// Alloc 分配节点ID并记录
+func (r *nodeIdRecorder) Alloc() (int64, error) {
+	nodeId, err := r.NodeIdAllocator.Alloc()
+	if err == nil {
+		atomic.StoreInt64(&r.nodeId, nodeId)
+	}
+	return nodeId, err
+}
+
+// Migration 漂移节点ID并记录
+func (r *nodeIdRecorder) Migration(nodeId int64) (int64, error) {
+	newNodeId, err := r.NodeIdAllocator.Migration(nodeId)
+	if err == nil {
+		atomic.StoreInt64(&r.nodeId, newNodeId)
+	}
+	return newNodeId, err
+}
+
+// NodeId 最近一次分配的节点ID
+func (r *nodeIdRecorder) NodeId() int64 {
+	return atomic.LoadInt64(&r.nodeId)
+}
+
+// refreshNodeId 重新分配节点ID，分配器未实现Refresh时重新调用Alloc
+// @param ctx
+// @param allocator
+// @return int64
+// @return error
+func refreshNodeId(ctx context.Context, allocator snowflake.NodeIdAllocator) (int64, error) {
+	if refresher, ok := allocator.(interface {
+		Refresh(ctx context.Context) (int64, error)
+	}); ok {
+		return refresher.Refresh(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return allocator.Alloc()
+}
@@ -0,0 +1,82 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 时间戳滞后与超前检测
+package snowflake
+
+import (
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+)
+
+// clockLagHook 时间戳滞后回调
+type clockLagHook struct {
+	threshold time.Duration
+	callback  func(lag time.Duration)
+}
+
+// OnClockLag 注册时间戳滞后回调，生成的ID时间戳落后当前时间超过threshold时同步调用
+// 单个节点持续以每毫秒序列号上限生成时，ID的时间戳会逐渐落后于实际时间，可据此在容量耗尽前告警
+// 当前时间取自节点ID分配器的时钟（WithClock），回调在Generate返回前执行，不应阻塞；重复注册时覆盖之前的回调
+// @param threshold
+// @param callback lag为ID时间戳落后当前时间的时长
+func (w *Wrapper) OnClockLag(threshold time.Duration, callback func(lag time.Duration)) {
+	w.clockLag.Store(&clockLagHook{threshold: threshold, callback: callback})
+}
+
+// checkClockLag 检查ID时间戳相对当前时间的滞后，超过阈值时调用回调
+func (w *Wrapper) checkClockLag(id snowflake.ID) {
+	hook, _ := w.clockLag.Load().(*clockLagHook)
+	if hook == nil {
+		return
+	}
+	clock := nodeidgorm.SystemClock
+	if clocked, ok := w.currentAllocator().(interface{ Clock() nodeidgorm.Clock }); ok {
+		clock = clocked.Clock()
+	}
+	lag := clock.Now().Sub(time.UnixMilli(id.Time()))
+	if lag > hook.threshold {
+		hook.callback(lag)
+	}
+}
+
+// clockAheadHook 时间戳超前回调
+type clockAheadHook struct {
+	threshold time.Duration
+	callback  func(ahead time.Duration)
+}
+
+// OnClockAhead 注册时间戳超前回调，生成的ID时间戳超前时间同步器保存的水位超过threshold时同步调用
+// 系统时钟被设置到遥远的未来时，ID会携带未来的时间戳，时钟校正后新生成的ID反而显得更"旧"，破坏ID的有序性；
+// 水位叠加了节点ID分配器时钟（WithClock）的偏移，系统时钟超前校正后的时钟时即可发现
+// 水位落后最近生成的ID最多一个同步间隔，threshold应明显大于WithSyncInterval；时间同步器尚未写入水位
+// 或注入的时间同步器未实现Watermark时不检查；回调在Generate返回前执行，不应阻塞；重复注册时覆盖之前的回调
+// @param threshold
+// @param callback ahead为ID时间戳超前水位的时长
+func (w *Wrapper) OnClockAhead(threshold time.Duration, callback func(ahead time.Duration)) {
+	w.clockAhead.Store(&clockAheadHook{threshold: threshold, callback: callback})
+}
+
+// checkClockAhead 检查ID时间戳相对时间同步器水位的超前，超过阈值时调用回调
+func (w *Wrapper) checkClockAhead(id snowflake.ID) {
+	hook, _ := w.clockAhead.Load().(*clockAheadHook)
+	if hook == nil {
+		return
+	}
+	reporter, ok := w.synchronizer.(interface{ Watermark() time.Time })
+	if !ok {
+		return
+	}
+	watermark := reporter.Watermark()
+	if watermark.IsZero() {
+		return
+	}
+	if ahead := time.UnixMilli(id.Time()).Sub(watermark); ahead > hook.threshold {
+		hook.callback(ahead)
+	}
+}
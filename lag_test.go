@@ -0,0 +1,120 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 时间戳滞后检测测试
+package snowflake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// racingClock 每次读取前进1ms的时钟，模拟实际时间快于节点生成ID的速度
+type racingClock struct {
+	start time.Time
+	calls atomic.Int64
+}
+
+// Now 每次调用前进1ms
+func (c *racingClock) Now() time.Time {
+	return c.start.Add(time.Duration(c.calls.Inc()) * time.Millisecond)
+}
+
+// TestWrapper_OnClockLag 测试持续超过序列号上限生成时，滞后回调以递增的滞后时长被调用
+func TestWrapper_OnClockLag(t *testing.T) {
+	clock := &racingClock{start: time.Now()}
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_clock_lag", 8080, time.Second, 5*time.Second,
+		logger, WithNodeIdOptions(nodeidgorm.WithClock(clock)))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	var (
+		mu   sync.Mutex
+		lags []time.Duration
+	)
+	threshold := 100 * time.Millisecond
+	sf.OnClockLag(threshold, func(lag time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		lags = append(lags, lag)
+	})
+
+	for i := 0; i < 1000; i++ {
+		sf.Generate()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, lags)
+	assert.Greater(t, lags[0], threshold)
+	assert.Greater(t, lags[len(lags)-1], lags[0])
+}
+
+// TestWrapper_OnClockLag_WithinThreshold 测试时间戳未滞后时不调用回调
+func TestWrapper_OnClockLag_WithinThreshold(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_clock_lag_within", 8080, time.Second,
+		5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	called := atomic.NewBool(false)
+	sf.OnClockLag(time.Second, func(time.Duration) { called.Store(true) })
+	for i := 0; i < 1000; i++ {
+		sf.Generate()
+	}
+	assert.False(t, called.Load())
+}
+
+// TestWrapper_OnClockAhead 测试系统时钟远超校正后的时钟时，超前回调被调用
+func TestWrapper_OnClockAhead(t *testing.T) {
+	// 校正后的时钟比系统时钟慢一小时，即系统时钟被设置到了一小时后
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_clock_ahead", 8080, time.Second, 5*time.Second,
+		logger, WithNodeIdOptions(nodeidgorm.WithClock(nodeidgorm.NewOffsetClock(-time.Hour))),
+		WithSyncInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	aheads := make(chan time.Duration, 1)
+	sf.OnClockAhead(time.Minute, func(ahead time.Duration) {
+		select {
+		case aheads <- ahead:
+		default:
+		}
+	})
+	// 尚未写入水位时不检查
+	sf.Generate()
+	assert.Empty(t, aheads)
+
+	require.Eventually(t, func() bool {
+		sf.Generate()
+		return len(aheads) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.InDelta(t, time.Hour.Seconds(), (<-aheads).Seconds(), 5)
+}
+
+// TestWrapper_OnClockAhead_WithinThreshold 测试时钟正常时不调用超前回调
+func TestWrapper_OnClockAhead_WithinThreshold(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_clock_ahead_within", 8080, time.Second,
+		5*time.Second, logger, WithSyncInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	called := atomic.NewBool(false)
+	sf.OnClockAhead(time.Second, func(time.Duration) { called.Store(true) })
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		sf.Generate()
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, called.Load())
+}
@@ -0,0 +1,102 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 位布局
+package snowflake
+
+import (
+	"errors"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/bwmarrin/snowflake"
+)
+
+// DefaultLayout 默认位布局：twitter纪元、10位节点、12位序列号
+var DefaultLayout = Layout{
+	Epoch:    1288834974657,
+	NodeBits: nodeid.DefaultNodeBits,
+	StepBits: 12,
+}
+
+// Layout 雪花ID的位布局
+type Layout struct {
+	// Epoch 纪元，毫秒时间戳
+	Epoch int64 `json:"epoch" yaml:"epoch" mapstructure:"epoch"`
+	// NodeBits 节点ID位数
+	NodeBits uint8 `json:"node_bits" yaml:"node_bits" mapstructure:"node_bits"`
+	// StepBits 序列号位数
+	StepBits uint8 `json:"step_bits" yaml:"step_bits" mapstructure:"step_bits"`
+}
+
+// IsZero 是否未配置位布局
+func (l Layout) IsZero() bool {
+	return l == Layout{}
+}
+
+// Validate 校验位布局
+// @return error
+func (l Layout) Validate() error {
+	if l.Epoch < 0 {
+		return errors.New("epoch must not be negative")
+	}
+	// 按int比较，避免uint8相加回绕
+	if int(l.NodeBits)+int(l.StepBits) > 22 {
+		return errors.New("node bits and step bits must not exceed a total of 22 bits")
+	}
+	return nil
+}
+
+// resolve 未配置的字段（零值）使用snowflake包当前的全局设置，只配置了位数时不会以纪元0生成ID
+func (l Layout) resolve() Layout {
+	if l.Epoch == 0 {
+		l.Epoch = snowflake.Epoch
+	}
+	if l.NodeBits == 0 {
+		l.NodeBits = snowflake.NodeBits
+	}
+	if l.StepBits == 0 {
+		l.StepBits = snowflake.StepBits
+	}
+	return l
+}
+
+// apply 将位布局写入snowflake包的全局变量
+// 注意：snowflake包的位布局是进程级的，同一进程中的所有节点共享
+func (l Layout) apply() {
+	snowflake.Epoch = l.Epoch
+	snowflake.NodeBits = l.NodeBits
+	snowflake.StepBits = l.StepBits
+}
+
+// Remap 位布局变更前后节点ID Key的哈希节点ID
+type Remap struct {
+	// Key 节点ID Key
+	Key string
+	// OldNodeID 变更前的节点ID
+	OldNodeID int64
+	// NewNodeID 变更后的节点ID
+	NewNodeID int64
+	// Changed 节点ID是否发生变化
+	Changed bool
+}
+
+// SimulateLayoutChange 预演位布局变更，按哈希分配器计算每个节点ID Key在变更前后的节点ID，不读写数据库，不修改全局位布局
+// 修改节点位数会重新分配节点ID，可在变更前据此评估受影响的实例数；结果不考虑冲突探测与漂移，实际分配的节点ID可能不同
+// @param old 变更前的位布局，为空时使用snowflake包当前的全局位布局
+// @param new 变更后的位布局，为空时使用snowflake包当前的全局位布局
+// @param keys 节点ID Key
+// @return []Remap 与keys顺序一致
+func SimulateLayoutChange(old, new Layout, keys []string) []Remap {
+	oldSlots, newSlots := uint64(1)<<old.resolve().NodeBits, uint64(1)<<new.resolve().NodeBits
+	remaps := make([]Remap, 0, len(keys))
+	for _, key := range keys {
+		hash := nodeid.KeyHash(key)
+		remap := Remap{Key: key, OldNodeID: int64(hash % oldSlots), NewNodeID: int64(hash % newSlots)}
+		remap.Changed = remap.OldNodeID != remap.NewNodeID
+		remaps = append(remaps, remap)
+	}
+	return remaps
+}
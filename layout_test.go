@@ -0,0 +1,63 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 位布局测试
+package snowflake
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allocUnderLayout 临时切换全局位布局，直接使用哈希分配器计算节点ID
+func allocUnderLayout(t *testing.T, layout Layout, key string) int64 {
+	previous := Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits}
+	layout.apply()
+	defer previous.apply()
+
+	nodeId, err := nodeid.NewHashNodeIdAllocator(key).Alloc()
+	require.NoError(t, err)
+	return nodeId
+}
+
+// TestSimulateLayoutChange 测试预演结果与分别在两种位布局下直接分配的节点ID一致
+func TestSimulateLayoutChange(t *testing.T) {
+	oldLayout := DefaultLayout
+	newLayout := Layout{Epoch: DefaultLayout.Epoch, NodeBits: 8, StepBits: 14}
+	keys := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		keys = append(keys, fmt.Sprintf("order-service_10.0.0.%d_8080_k8s", i))
+	}
+
+	global := Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits}
+	remaps := SimulateLayoutChange(oldLayout, newLayout, keys)
+	assert.Equal(t, global, Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits})
+	require.Len(t, remaps, len(keys))
+
+	changed := 0
+	for i, remap := range remaps {
+		assert.Equal(t, keys[i], remap.Key)
+		assert.Equal(t, allocUnderLayout(t, oldLayout, remap.Key), remap.OldNodeID)
+		assert.Equal(t, allocUnderLayout(t, newLayout, remap.Key), remap.NewNodeID)
+		assert.Equal(t, remap.OldNodeID != remap.NewNodeID, remap.Changed)
+		if remap.Changed {
+			changed++
+		}
+	}
+	// 缩小节点位数时，节点ID不小于256的key都会变化
+	assert.Greater(t, changed, 0)
+	assert.Less(t, changed, len(keys))
+
+	// 位布局不变时没有变化
+	for _, remap := range SimulateLayoutChange(Layout{}, global, keys) {
+		assert.False(t, remap.Changed)
+	}
+}
@@ -0,0 +1,36 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 最小ID
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrMinimumIDUnreachable 当前时间生成的ID无法大于配置的最小ID
+var ErrMinimumIDUnreachable = errors.New("minimum id is unreachable")
+
+// checkMinimumID 校验当前时间生成的最小ID是否已大于floor
+// 雪花ID随时间递增，此刻可生成的最小ID大于floor，之后生成的ID均大于floor（不考虑时钟回拨）
+// @param floor
+// @return error
+func checkMinimumID(floor int64) error {
+	shift := snowflake.NodeBits + snowflake.StepBits
+	now := time.Now().UnixMilli()
+	if lowest := (now - snowflake.Epoch) << shift; lowest > floor {
+		return nil
+	}
+
+	// floor对应的时间之后才能生成大于floor的ID
+	reachable := time.UnixMilli((floor >> shift) + 1 + snowflake.Epoch)
+	return fmt.Errorf("%w: floor %d, ids exceed it after %s, consider an earlier epoch", ErrMinimumIDUnreachable,
+		floor, reachable.Format(time.RFC3339))
+}
@@ -0,0 +1,46 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 最小ID测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithMinimumID 测试生成的ID均大于最小ID
+func TestWithMinimumID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shift := snowflake.NodeBits + snowflake.StepBits
+	floor := (time.Now().Add(-time.Second).UnixMilli()-snowflake.Epoch)<<shift | 1<<shift - 1
+	sf, err := NewSnowflake(ctx, setupTestDB(t), "test_minimum", 8080, time.Second, 5*time.Second, logger,
+		WithMinimumID(floor))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	for i := 0; i < 10000; i++ {
+		require.Greater(t, sf.Generate().Int64(), floor)
+	}
+}
+
+// TestWithMinimumID_Unreachable 测试最小ID无法满足时创建失败
+func TestWithMinimumID_Unreachable(t *testing.T) {
+	shift := snowflake.NodeBits + snowflake.StepBits
+	floor := (time.Now().Add(time.Hour).UnixMilli() - snowflake.Epoch) << shift
+	_, err := NewSnowflake(context.Background(), setupTestDB(t), "test_minimum", 8080, time.Second, 5*time.Second,
+		logger, WithMinimumID(floor))
+	assert.True(t, errors.Is(err, ErrMinimumIDUnreachable))
+	assert.Contains(t, err.Error(), "consider an earlier epoch")
+}
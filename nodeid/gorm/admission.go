@@ -0,0 +1,133 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 分配准入令牌
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"go.uber.org/atomic"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultAdmissionLease 准入令牌的默认租约，持有者崩溃未归还时，超过租约后其令牌被回收
+const DefaultAdmissionLease = 30 * time.Second
+
+// admissionName 分配准入令牌的记录名称，该记录的in_flight为最近一次获取或归还后的持有数
+const admissionName = "alloc"
+
+// admissionHolderPrefix 令牌持有者记录名称的前缀，每个持有者一条记录，updated为获取令牌的时间
+const admissionHolderPrefix = admissionName + "/"
+
+// admissionPollInterval 令牌耗尽时重试的间隔
+var admissionPollInterval = 50 * time.Millisecond
+
+// admissionSeq 进程内令牌持有者的序号，保证持有者记录名称唯一
+var admissionSeq atomic.Int64
+
+// admissionDB 协调数据库上的新会话
+func (m *NodeIdAllocator) admissionDB(ctx context.Context) *gorm.DB {
+	return m.dao.SnowflakeKv.WithContext(ctx).UnderlyingDB().Session(&gorm.Session{NewDB: true})
+}
+
+// acquireAdmission 获取分配准入令牌，令牌耗尽时每隔admissionPollInterval重试，直到获取成功或ctx结束
+// @return func() 归还令牌
+// @return error
+func (m *NodeIdAllocator) acquireAdmission(ctx context.Context, logger Logger) (func(), error) {
+	// 首次使用时创建令牌记录，已存在时不报错
+	if err := m.admissionDB(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.SnowflakeAdmission{
+		Name: admissionName, Updated: m.clock.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	holder := fmt.Sprintf("%s%s/%d/%d", admissionHolderPrefix, m.nodeIdKey, os.Getpid(), admissionSeq.Inc())
+	waiting := false
+	for {
+		acquired, err := m.tryAcquireAdmission(ctx, holder, logger)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return func() { m.releaseAdmission(holder, logger) }, nil
+		}
+
+		if !waiting {
+			waiting = true
+			logger.Infof("alloc admission is exhausted, waiting. key: %s, limit: %d", m.nodeIdKey, m.admissionLimit)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(admissionPollInterval):
+		}
+	}
+}
+
+// tryAcquireAdmission 在事务中回收超过租约的持有者，持有者未达上限时写入holder
+// 事务先更新令牌记录，以其行锁串行化各实例的计数与写入
+// @return bool 是否获取成功
+// @return error
+func (m *NodeIdAllocator) tryAcquireAdmission(ctx context.Context, holder string, logger Logger) (bool, error) {
+	acquired := false
+	err := m.admissionDB(ctx).Transaction(func(tx *gorm.DB) error {
+		now := m.clock.Now()
+		if err := tx.Model(&model.SnowflakeAdmission{}).Where("name = ?", admissionName).
+			Update("updated", now).Error; err != nil {
+			return err
+		}
+
+		// 持有者崩溃未归还时，按其获取令牌的时间回收，不受其他实例获取与归还的影响
+		result := tx.Where("name LIKE ? AND updated < ?", admissionHolderPrefix+"%", now.Add(-m.admissionLease)).
+			Delete(&model.SnowflakeAdmission{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			logger.Warnf("alloc admission lease expired, reclaiming. key: %s, reclaimed: %d, lease: %s",
+				m.nodeIdKey, result.RowsAffected, m.admissionLease)
+		}
+
+		var holders int64
+		if err := tx.Model(&model.SnowflakeAdmission{}).Where("name LIKE ?", admissionHolderPrefix+"%").
+			Count(&holders).Error; err != nil {
+			return err
+		}
+		if holders < m.admissionLimit {
+			if err := tx.Create(&model.SnowflakeAdmission{Name: holder, InFlight: 1, Updated: now}).Error; err != nil {
+				return err
+			}
+			acquired = true
+			holders++
+		}
+		return tx.Model(&model.SnowflakeAdmission{}).Where("name = ?", admissionName).
+			Update("in_flight", holders).Error
+	})
+	return acquired, err
+}
+
+// releaseAdmission 归还分配准入令牌，不受分配的context影响，失败时等待租约到期后回收
+func (m *NodeIdAllocator) releaseAdmission(holder string, logger Logger) {
+	err := m.admissionDB(context.Background()).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("name = ?", holder).Delete(&model.SnowflakeAdmission{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			// 已超过租约被其他实例回收
+			return result.Error
+		}
+		return tx.Model(&model.SnowflakeAdmission{}).Where("name = ? AND in_flight > 0", admissionName).
+			Update("in_flight", gorm.Expr("in_flight - 1")).Error
+	})
+	if err != nil {
+		logger.Errorf("release alloc admission failed, it is reclaimed after the lease %s. key: %s, error: %v",
+			m.admissionLease, m.nodeIdKey, err)
+	}
+}
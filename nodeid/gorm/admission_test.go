@@ -0,0 +1,174 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 分配准入令牌测试
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"gorm.io/gorm"
+)
+
+// admissionDB 创建包含snowflake_admission表的测试数据库
+func admissionDB(t *testing.T) *gorm.DB {
+	db := testDB(t)
+	require.NoError(t, AutoMigrate(db))
+	return db
+}
+
+// trackInFlight 统计同时执行snowflake_kv语句的最大并发数，每条语句放慢以放大并发
+func trackInFlight(t *testing.T, db *gorm.DB) *atomic.Int64 {
+	var active, peak atomic.Int64
+	before := func(tx *gorm.DB) {
+		if tx.Statement.Table != model.TableNameSnowflakeKv {
+			return
+		}
+		current := active.Inc()
+		for {
+			max := peak.Load()
+			if current <= max || peak.CAS(max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	after := func(tx *gorm.DB) {
+		if tx.Statement.Table == model.TableNameSnowflakeKv {
+			active.Dec()
+		}
+	}
+	callbacks := db.Callback()
+	require.NoError(t, callbacks.Query().Before("gorm:query").Register("test:in_flight_query", before))
+	require.NoError(t, callbacks.Query().After("gorm:query").Register("test:in_flight_query_done", after))
+	require.NoError(t, callbacks.Create().Before("gorm:create").Register("test:in_flight_create", before))
+	require.NoError(t, callbacks.Create().After("gorm:create").Register("test:in_flight_create_done", after))
+	require.NoError(t, callbacks.Update().Before("gorm:update").Register("test:in_flight_update", before))
+	require.NoError(t, callbacks.Update().After("gorm:update").Register("test:in_flight_update_done", after))
+	return &peak
+}
+
+// TestWithAdmissionLimit 测试大量实例同时分配时，同时执行分配的实例数不超过上限
+func TestWithAdmissionLimit(t *testing.T) {
+	oldPoll := admissionPollInterval
+	admissionPollInterval = 5 * time.Millisecond
+	defer func() { admissionPollInterval = oldPoll }()
+
+	db := admissionDB(t)
+	peak := trackInFlight(t, db)
+	ctx := context.Background()
+
+	const (
+		registrants = 16
+		limit       = 2
+	)
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		nodeIds = make([]int64, registrants)
+		errs    = make([]error, registrants)
+	)
+	for i := 0; i < registrants; i++ {
+		allocator := NewNodeIdAllocator(ctx, db, fmt.Sprintf("admission-%d", i), testPort, time.Second,
+			5*time.Second, logger, WithAdmissionLimit(limit, time.Minute))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			nodeIds[i], errs[i] = allocator.Alloc()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	distinct := make(map[int64]struct{}, registrants)
+	for i := 0; i < registrants; i++ {
+		require.NoError(t, errs[i])
+		distinct[nodeIds[i]] = struct{}{}
+	}
+	assert.Len(t, distinct, registrants)
+	assert.LessOrEqual(t, peak.Load(), int64(limit))
+	assert.Greater(t, peak.Load(), int64(0))
+
+	// 所有令牌均已归还
+	var token model.SnowflakeAdmission
+	require.NoError(t, db.Where("name = ?", admissionName).Take(&token).Error)
+	assert.Zero(t, token.InFlight)
+}
+
+// TestWithAdmissionLimit_Lease 测试令牌耗尽时等待直到ctx结束，超过租约的持有者被回收
+func TestWithAdmissionLimit_Lease(t *testing.T) {
+	oldPoll := admissionPollInterval
+	admissionPollInterval = 5 * time.Millisecond
+	defer func() { admissionPollInterval = oldPoll }()
+
+	db := admissionDB(t)
+	lease := time.Minute
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithAdmissionLimit(1, lease))
+
+	// 其他实例持有令牌
+	holder := admissionHolderPrefix + "other"
+	require.NoError(t, db.Create(&model.SnowflakeAdmission{Name: holder, InFlight: 1, Updated: time.Now()}).Error)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := allocator.Refresh(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// 持有者崩溃，超过租约后其令牌被回收
+	require.NoError(t, db.Model(&model.SnowflakeAdmission{}).Where("name = ?", holder).
+		Update("updated", time.Now().Add(-2*lease)).Error)
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	var token model.SnowflakeAdmission
+	require.NoError(t, db.Where("name = ?", admissionName).Take(&token).Error)
+	assert.Zero(t, token.InFlight)
+}
+
+// TestWithAdmissionLimit_LeakedHolder 测试其他实例持续获取与归还令牌时，崩溃的持有者仍在租约到期后被回收
+func TestWithAdmissionLimit_LeakedHolder(t *testing.T) {
+	oldPoll := admissionPollInterval
+	admissionPollInterval = 5 * time.Millisecond
+	defer func() { admissionPollInterval = oldPoll }()
+
+	db := admissionDB(t)
+	lease := 100 * time.Millisecond
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithAdmissionLimit(2, lease))
+
+	// 崩溃的持有者占用一个令牌，其余实例持续获取与归还另一个令牌
+	leaked := admissionHolderPrefix + "leaked"
+	require.NoError(t, db.Create(&model.SnowflakeAdmission{Name: leaked, InFlight: 1, Updated: time.Now()}).Error)
+	deadline := time.Now().Add(3 * lease)
+	for time.Now().Before(deadline) {
+		_, err := allocator.Refresh(context.Background())
+		require.NoError(t, err)
+	}
+	count := int64(-1)
+	require.NoError(t, db.Model(&model.SnowflakeAdmission{}).Where("name = ?", leaked).Count(&count).Error)
+	assert.Zero(t, count)
+
+	// 回收后另一个持有者占用令牌时仍可获取
+	require.NoError(t, db.Create(&model.SnowflakeAdmission{
+		Name: admissionHolderPrefix + "other", InFlight: 1, Updated: time.Now(),
+	}).Error)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := allocator.Refresh(ctx)
+	require.NoError(t, err)
+	var token model.SnowflakeAdmission
+	require.NoError(t, db.Where("name = ?", admissionName).Take(&token).Error)
+	assert.Equal(t, int64(1), token.InFlight)
+}
@@ -0,0 +1,132 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 异步日志
+package gorm
+
+import (
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// DefaultAsyncLogBuffer 异步日志默认的缓冲区大小
+const DefaultAsyncLogBuffer = 1024
+
+// AsyncLogger 异步日志，日志写入缓冲区后由后台goroutine交给被包装的Logger输出，
+// 缓冲区已满时丢弃日志并计数，注入的Logger变慢时不会阻塞时间同步与节点ID分配
+// 参数在输出时才被格式化，调用方不应在记录日志后修改传入的参数
+type AsyncLogger struct {
+	logger  Logger
+	entries chan func(Logger)
+	dropped atomic.Int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAsyncLogger 创建一个异步日志并启动后台goroutine，bufferSize小于等于0时使用DefaultAsyncLogBuffer
+// @param logger 被包装的日志
+// @param bufferSize 缓冲区大小
+// @return *AsyncLogger
+func NewAsyncLogger(logger Logger, bufferSize int) *AsyncLogger {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncLogBuffer
+	}
+	l := &AsyncLogger{
+		logger:  logger,
+		entries: make(chan func(Logger), bufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go l.drain()
+	return l
+}
+
+// drain 依次输出缓冲区中的日志，Close后输出剩余的日志再退出
+func (l *AsyncLogger) drain() {
+	defer close(l.done)
+	for {
+		select {
+		case entry := <-l.entries:
+			entry(l.logger)
+		case <-l.stop:
+			for {
+				select {
+				case entry := <-l.entries:
+					entry(l.logger)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// enqueue 写入缓冲区，缓冲区已满或已Close时丢弃
+func (l *AsyncLogger) enqueue(entry func(Logger)) {
+	select {
+	case <-l.stop:
+		l.dropped.Inc()
+		return
+	default:
+	}
+	select {
+	case l.entries <- entry:
+	default:
+		l.dropped.Inc()
+	}
+}
+
+// Dropped 缓冲区已满或Close后丢弃的日志数
+// 可作为监控指标，持续增长说明注入的Logger跟不上日志的产生速度
+// @return int64
+func (l *AsyncLogger) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Close 输出缓冲区中剩余的日志并停止后台goroutine，等待其退出，之后记录的日志被丢弃
+// @return error
+func (l *AsyncLogger) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+	return nil
+}
+
+func (l *AsyncLogger) Debugf(format string, args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Debugf(format, args...) })
+}
+
+func (l *AsyncLogger) Debug(args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Debug(args...) })
+}
+
+func (l *AsyncLogger) Infof(format string, args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Infof(format, args...) })
+}
+
+func (l *AsyncLogger) Info(args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Info(args...) })
+}
+
+func (l *AsyncLogger) Warnf(format string, args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Warnf(format, args...) })
+}
+
+func (l *AsyncLogger) Warn(args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Warn(args...) })
+}
+
+func (l *AsyncLogger) Errorf(format string, args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Errorf(format, args...) })
+}
+
+func (l *AsyncLogger) Error(args ...interface{}) {
+	l.enqueue(func(logger Logger) { logger.Error(args...) })
+}
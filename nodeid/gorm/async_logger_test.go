@@ -0,0 +1,85 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 异步日志测试
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// slowLogger 每次输出都阻塞一段时间的日志记录器
+type slowLogger struct {
+	DefaultLogger
+	delay  time.Duration
+	logged atomic.Int64
+}
+
+// Errorf 阻塞后记录次数
+func (l *slowLogger) Errorf(format string, args ...interface{}) {
+	time.Sleep(l.delay)
+	l.logged.Inc()
+}
+
+// Infof 阻塞后记录次数
+func (l *slowLogger) Infof(format string, args ...interface{}) {
+	time.Sleep(l.delay)
+	l.logged.Inc()
+}
+
+// TestAsyncLogger_SlowLogger 测试注入的日志变慢时时间同步器的写入节奏不受影响，缓冲区已满的日志被丢弃并计数
+func TestAsyncLogger_SlowLogger(t *testing.T) {
+	const (
+		interval = 10 * time.Millisecond
+		window   = 300 * time.Millisecond
+	)
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slow := &slowLogger{delay: 100 * time.Millisecond}
+	async := NewAsyncLogger(slow, 2)
+	defer async.Close()
+	recorder := &latencyRecorder{}
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, interval, async, WithMetrics(recorder))
+
+	// 删除表后每次写入失败，每个周期都记录一条错误日志
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	synchronizer.Async(time.Now().UnixMilli())
+	synchronizer.Run()
+	time.Sleep(window)
+	synchronizer.Stop()
+
+	// 同步记录日志时每个周期至少阻塞delay，窗口内最多写入window/delay次
+	syncs := len(recorder.get(DBOpSync))
+	assert.GreaterOrEqual(t, syncs, int(window/interval)/3)
+	assert.Greater(t, async.Dropped(), int64(0))
+	assert.Less(t, slow.logged.Load(), int64(syncs))
+}
+
+// TestAsyncLogger_Close 测试Close输出缓冲区中剩余的日志，之后记录的日志被丢弃
+func TestAsyncLogger_Close(t *testing.T) {
+	slow := &slowLogger{delay: time.Millisecond}
+	async := NewAsyncLogger(slow, 0)
+	for i := 0; i < 10; i++ {
+		async.Infof("log %d", i)
+	}
+	require.NoError(t, async.Close())
+	assert.Equal(t, int64(10), slow.logged.Load())
+	assert.Zero(t, async.Dropped())
+
+	async.Errorf("after close")
+	require.NoError(t, async.Close())
+	assert.Equal(t, int64(10), slow.logged.Load())
+	assert.Equal(t, int64(1), async.Dropped())
+}
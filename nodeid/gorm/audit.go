@@ -0,0 +1,54 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 节点ID冲突审计
+package gorm
+
+import (
+	"context"
+)
+
+// Collision 同一个节点ID被多个活跃的key持有
+type Collision struct {
+	// NodeID 冲突的节点ID
+	NodeID int64 `json:"node_id"`
+	// Keys 持有该节点ID的key，按升序排列
+	Keys []string `json:"keys"`
+}
+
+// AuditCollisions 一次性审计整个集群的节点ID唯一性：查询抢占时间间隔内活跃的全部记录，
+// 返回被多个不同key同时持有的节点ID，这些实例生成的ID可能重复
+// 已失效的记录不参与审计，其节点ID可被正常抢占，不属于冲突；
+// 按model/mysql.sql建表时node_id上的唯一索引已阻止冲突，主要用于未创建该索引的已有表
+// @param ctx
+// @return []Collision 按节点ID升序排列，没有冲突时为空
+// @return error
+func (m *NodeIdAllocator) AuditCollisions(ctx context.Context) ([]Collision, error) {
+	active := m.timeUnit.From(m.clock.Now()) - m.timeUnit.Duration(m.nodeIdContentionInterval)
+	tab := m.dao.SnowflakeKv
+	rows, err := tab.WithContext(ctx).Select(tab.Aliased("key", "node_id")...).
+		Where(tab.Time.Gte(active)).Order(tab.NodeID, tab.Key).Find()
+	if err != nil {
+		return nil, err
+	}
+
+	var collisions []Collision
+	for i := 0; i < len(rows); {
+		j := i + 1
+		for j < len(rows) && rows[j].NodeID == rows[i].NodeID {
+			j++
+		}
+		if j-i > 1 {
+			keys := make([]string, 0, j-i)
+			for _, row := range rows[i:j] {
+				keys = append(keys, row.Key)
+			}
+			collisions = append(collisions, Collision{NodeID: rows[i].NodeID, Keys: keys})
+		}
+		i = j
+	}
+	return collisions, nil
+}
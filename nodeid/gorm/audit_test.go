@@ -0,0 +1,59 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点ID冲突审计测试
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeIdAllocator_AuditCollisions 测试多个活跃的key持有同一个节点ID时报告冲突，节点ID互不相同或记录已失效时不报告
+func TestNodeIdAllocator_AuditCollisions(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	auditor := NewNodeIdAllocator(ctx, db, "audit", testPort, time.Second, 5*time.Second, logger)
+	// 模拟未创建node_id唯一索引的已有表
+	require.NoError(t, db.Migrator().DropIndex(&model.SnowflakeKv{}, "snowflake_kv_UN_node_id"))
+
+	now := time.Now()
+	tab := dao.Use(db).SnowflakeKv
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(
+		&model.SnowflakeKv{Key: "audit_10.0.0.1_8080_physical", NodeID: 7, Time: now.UnixMilli(), Created: &now,
+			Updated: now},
+		&model.SnowflakeKv{Key: "audit_10.0.0.2_8080_physical", NodeID: 8, Time: now.UnixMilli(), Created: &now,
+			Updated: now},
+	))
+	collisions, err := auditor.AuditCollisions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, collisions)
+
+	// 已失效的记录持有相同的节点ID不属于冲突
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(
+		&model.SnowflakeKv{Key: "audit_10.0.0.3_8080_physical", NodeID: 7, Time: now.Add(-time.Minute).UnixMilli(),
+			Created: &now, Updated: now},
+	))
+	collisions, err = auditor.AuditCollisions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, collisions)
+
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(
+		&model.SnowflakeKv{Key: "audit_10.0.0.4_8080_physical", NodeID: 7, Time: now.UnixMilli(), Created: &now,
+			Updated: now},
+	))
+	collisions, err = auditor.AuditCollisions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Collision{
+		{NodeID: 7, Keys: []string{"audit_10.0.0.1_8080_physical", "audit_10.0.0.4_8080_physical"}},
+	}, collisions)
+}
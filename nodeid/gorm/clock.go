@@ -0,0 +1,53 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 时钟
+package gorm
+
+import "time"
+
+// Clock 时钟，节点ID分配器与时间同步器通过它获取当前时间
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock 系统时钟
+type systemClock struct{}
+
+// Now 当前系统时间
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock 系统时钟，默认使用
+var SystemClock Clock = systemClock{}
+
+// offsetClock 在系统时间上叠加固定偏移的时钟
+type offsetClock struct {
+	offset time.Duration
+}
+
+// Now 叠加偏移后的当前时间
+func (c offsetClock) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// NewOffsetClock 创建一个在系统时间上叠加固定偏移的时钟，用于补偿已知的NTP偏差
+// 例如本机时钟比NTP慢200ms时使用NewOffsetClock(200 * time.Millisecond)
+// @param offset
+// @return Clock
+func NewOffsetClock(offset time.Duration) Clock {
+	return offsetClock{offset: offset}
+}
+
+// skewMilli 时钟相对系统时间的偏移，毫秒
+// 雪花ID中的时间戳来自系统时间，写入水位前需叠加该偏移，才能与分配器读取的时间保持一致
+func skewMilli(clock Clock) int64 {
+	if c, ok := clock.(offsetClock); ok {
+		return c.offset.Milliseconds()
+	}
+	return clock.Now().Sub(time.Now()).Round(time.Millisecond).Milliseconds()
+}
@@ -0,0 +1,60 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 时钟测试
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOffsetClock 测试偏移时钟
+func TestOffsetClock(t *testing.T) {
+	clock := NewOffsetClock(time.Hour)
+	assert.InDelta(t, time.Now().Add(time.Hour).UnixMilli(), clock.Now().UnixMilli(), 100)
+	assert.Equal(t, time.Hour.Milliseconds(), skewMilli(clock))
+	assert.Zero(t, skewMilli(SystemClock))
+}
+
+// TestOffsetClock_AllocAndSync 测试分配与水位写入均使用校正后的时间
+func TestOffsetClock_AllocAndSync(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	offset := 10 * time.Minute
+	clock := NewOffsetClock(offset)
+
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, 5*time.Second, logger,
+		WithClock(clock))
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	tab := allocator.dao.SnowflakeKv
+	record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).First()
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(offset).UnixMilli(), record.Time, float64(time.Second.Milliseconds()))
+
+	// 保存的时间领先系统时间但未领先校正后的时间，不视为时钟回拨
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+
+	// 水位写入叠加偏移
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 50*time.Millisecond, logger, WithClock(clock))
+	synchronizer.Run()
+	generated := time.Now().Add(time.Second)
+	synchronizer.Async(generated.UnixMilli())
+	time.Sleep(200 * time.Millisecond)
+
+	record, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).First()
+	require.NoError(t, err)
+	assert.Equal(t, generated.Add(offset).UnixMilli(), record.Time)
+}
@@ -0,0 +1,73 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 列名映射
+package gorm
+
+import (
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+// ColumnNames snowflake_kv各列的实际列名，为空的列沿用默认列名
+type ColumnNames struct {
+	// Key 默认为key
+	Key string
+	// NodeID 默认为node_id
+	NodeID string
+	// Time 默认为time
+	Time string
+	// Created 默认为created
+	Created string
+	// Updated 默认为updated
+	Updated string
+	// IP 默认为ip
+	IP string
+	// DeployType 默认为deploy_type
+	DeployType string
+	// LeaseExpiry 默认为lease_expiry
+	LeaseExpiry string
+}
+
+// mapping 默认列名到实际列名的映射，未配置时为nil
+func (c ColumnNames) mapping() map[string]string {
+	if c == (ColumnNames{}) {
+		return nil
+	}
+	return map[string]string{
+		"key":          c.Key,
+		"node_id":      c.NodeID,
+		"time":         c.Time,
+		"created":      c.Created,
+		"updated":      c.Updated,
+		"ip":           c.IP,
+		"deploy_type":  c.DeployType,
+		"lease_expiry": c.LeaseExpiry,
+	}
+}
+
+// useDao 创建查询对象，并应用列名映射
+func useDao(db *gorm.DB, columns ColumnNames) *dao.Query {
+	q := dao.Use(db)
+	if names := columns.mapping(); names != nil {
+		q = q.WithColumnNames(names)
+	}
+	return q
+}
+
+// rowColumns 查询完整记录时选择的列，以默认列名为别名，保证映射列名后结果仍能扫描到model
+// 未开启WithPersistAddress时不选择ip、deploy_type列，未开启WithLease时不选择lease_expiry列，以兼容没有这些列的旧表
+func (m *NodeIdAllocator) rowColumns() []field.Expr {
+	names := []string{"key", "node_id", "time", "created", "updated"}
+	if m.persistAddress {
+		names = append(names, "ip", "deploy_type")
+	}
+	if m.lease > 0 {
+		names = append(names, "lease_expiry")
+	}
+	return m.dao.SnowflakeKv.Aliased(names...)
+}
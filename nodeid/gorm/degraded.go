@@ -0,0 +1,60 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 时间同步器 降级状态
+package gorm
+
+// DefaultDegradedThreshold 默认连续写入失败多少次后进入降级状态
+const DefaultDegradedThreshold = 3
+
+// Degraded 是否处于降级状态
+// 连续写入失败达到阈值（WithDegradedThreshold）后进入降级状态，此时仍可生成ID，但数据库中的时间不再推进，
+// 重启后的时钟回拨保护退化为最近一次成功写入的时间；写入恢复成功后退出降级状态
+// @return bool
+func (m *TimeSynchronizer) Degraded() bool {
+	return m.degraded.Load()
+}
+
+// OnDegradedChange 注册降级状态变化回调，进入与退出降级状态时在同步goroutine中调用，不应阻塞
+// @param callback
+func (m *TimeSynchronizer) OnDegradedChange(callback func(degraded bool)) {
+	m.degradedMu.Lock()
+	defer m.degradedMu.Unlock()
+
+	m.onDegradedChange = append(m.onDegradedChange, callback)
+}
+
+// writeFailed 记录一次写入失败，连续失败达到阈值时进入降级状态
+func (m *TimeSynchronizer) writeFailed() {
+	failures := m.failures.Inc()
+	if failures < m.degradedThreshold || !m.degraded.CAS(false, true) {
+		return
+	}
+	m.logger.Warnf("time synchronizer is degraded, clock rollback protection relies on the last successful write. "+
+		"key: %s, consecutive failures: %d", m.nodeIdKey, failures)
+	m.notifyDegraded(true)
+}
+
+// writeSucceeded 记录一次写入成功，处于降级状态时退出
+func (m *TimeSynchronizer) writeSucceeded() {
+	m.failures.Store(0)
+	if !m.degraded.CAS(true, false) {
+		return
+	}
+	m.logger.Infof("time synchronizer recovered. key: %s", m.nodeIdKey)
+	m.notifyDegraded(false)
+}
+
+// notifyDegraded 调用降级状态变化回调
+func (m *TimeSynchronizer) notifyDegraded(degraded bool) {
+	m.degradedMu.Lock()
+	callbacks := m.onDegradedChange
+	m.degradedMu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(degraded)
+	}
+}
@@ -0,0 +1,64 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 时间同步器降级状态测试
+package gorm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"gorm.io/gorm"
+)
+
+// TestTimeSynchronizer_Degraded 测试数据库持续不可写时进入降级状态，恢复后退出
+func TestTimeSynchronizer_Degraded(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger).Alloc()
+	require.NoError(t, err)
+
+	var down atomic.Bool
+	require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:db_down", func(tx *gorm.DB) {
+		if down.Load() {
+			_ = tx.AddError(errors.New("database is down"))
+		}
+	}))
+	defer func() { _ = db.Callback().Update().Remove("test:db_down") }()
+
+	var mu sync.Mutex
+	var changes []bool
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 10*time.Millisecond, logger,
+		WithDegradedThreshold(2))
+	synchronizer.OnDegradedChange(func(degraded bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, degraded)
+	})
+	synchronizer.Async(time.Now().UnixMilli())
+	synchronizer.Run()
+	defer synchronizer.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, synchronizer.Degraded())
+
+	down.Store(true)
+	assert.Eventually(t, synchronizer.Degraded, time.Second, 5*time.Millisecond)
+
+	down.Store(false)
+	assert.Eventually(t, func() bool { return !synchronizer.Degraded() }, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []bool{true, false}, changes)
+}
@@ -0,0 +1,64 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 错误定义
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNodeIdCollision 严格模式下节点ID已被其他活跃的key持有
+	ErrNodeIdCollision = errors.New("node id is owned by another active key")
+	// ErrKeyInUse 严格模式下节点ID Key已被进程内其他活跃的分配器持有
+	ErrKeyInUse = errors.New("node id key is already active in this process")
+	// ErrContextCancelled 创建或分配时context已结束
+	ErrContextCancelled = errors.New("context is already cancelled")
+	// ErrInvalidPort 端口不在1-65535范围内，无法生成节点ID Key
+	ErrInvalidPort = errors.New("invalid port")
+	// ErrInvalidNodeIdKey 节点ID Key无法解析为服务名称、IP、端口与部署类型
+	ErrInvalidNodeIdKey = errors.New("invalid node id key")
+	// ErrMembersTimeout 超时前注册的成员数量不足
+	ErrMembersTimeout = errors.New("timed out waiting for members")
+	// ErrSchemaMissing 表不存在，需先执行AutoMigrate（或WithAutoMigrate）或按model目录下的SQL手动建表
+	ErrSchemaMissing = errors.New("snowflake table is missing, run AutoMigrate or create it from nodeid/gorm/model/*.sql")
+	// ErrIPMismatch POD_IP与网卡扫描得到的IP不一致
+	ErrIPMismatch = errors.New("POD_IP and the interface address disagree")
+)
+
+// wrapSchemaError 将各数据库表不存在的错误包装为ErrSchemaMissing，其他错误原样返回
+// 按错误信息识别，不依赖具体的数据库驱动：
+// SQLite "no such table"，MySQL 1146 "Table '...' doesn't exist"，
+// PostgreSQL 42P01 "relation ... does not exist"，SQL Server "Invalid object name"
+func wrapSchemaError(err error) error {
+	if err == nil || errors.Is(err, ErrSchemaMissing) {
+		return err
+	}
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "no such table"),
+		strings.Contains(message, "table") && strings.Contains(message, "doesn't exist"),
+		strings.Contains(message, "relation") && strings.Contains(message, "does not exist"),
+		strings.Contains(message, "invalid object name"):
+		return fmt.Errorf("%w: %v", ErrSchemaMissing, err)
+	default:
+		return err
+	}
+}
+
+// CheckContext 检查context是否已结束，已结束时返回包装了ErrContextCancelled的错误
+// @param ctx
+// @return error
+func CheckContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrContextCancelled, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 记录导出与导入
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+)
+
+// NodeRecord snowflake_kv中的一条记录，与列名映射无关
+type NodeRecord struct {
+	Key        string    `json:"key"`
+	NodeID     int64     `json:"node_id"`
+	Time       int64     `json:"time"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+	IP         string    `json:"ip,omitempty"`
+	DeployType string    `json:"deploy_type,omitempty"`
+}
+
+// Export 导出snowflake_kv中的全部记录，用于在测试中构造确定的竞争、回拨场景，或在数据库之间迁移注册表
+// 未开启WithPersistAddress时不导出ip、deploy_type列
+// @param ctx
+// @return []NodeRecord 按节点ID升序排列
+// @return error
+func (m *NodeIdAllocator) Export(ctx context.Context) ([]NodeRecord, error) {
+	tab := m.dao.SnowflakeKv
+	rows, err := tab.WithContext(ctx).Select(m.rowColumns()...).Order(tab.NodeID).Find()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]NodeRecord, 0, len(rows))
+	for _, row := range rows {
+		record := NodeRecord{
+			Key:        row.Key,
+			NodeID:     row.NodeID,
+			Time:       row.Time,
+			Updated:    row.Updated,
+			IP:         row.IP,
+			DeployType: row.DeployType,
+		}
+		if row.Created != nil {
+			record.Created = *row.Created
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Import 在一个事务内写入记录，key或节点ID已存在时返回错误并回滚
+// 未开启WithPersistAddress时不写入ip、deploy_type列
+// @param ctx
+// @param records 通常来自Export
+// @return error
+func (m *NodeIdAllocator) Import(ctx context.Context, records []NodeRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return m.dao.Transaction(func(tx *dao.Query) error {
+		tab := tx.SnowflakeKv
+		// 按列名写入，以支持映射列名
+		for _, record := range records {
+			values := map[string]interface{}{
+				tab.ColumnName("key"):     record.Key,
+				tab.ColumnName("node_id"): record.NodeID,
+				tab.ColumnName("time"):    record.Time,
+				tab.ColumnName("created"): record.Created,
+				tab.ColumnName("updated"): record.Updated,
+			}
+			if m.persistAddress {
+				values[tab.ColumnName("ip")] = record.IP
+				values[tab.ColumnName("deploy_type")] = record.DeployType
+			}
+			if err := tab.WithContext(ctx).UnderlyingDB().Table(tab.TableName()).Create(values).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 记录导出与导入测试
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeIdAllocator_ExportImport 测试从一个数据库导出的记录导入另一个数据库后保持一致
+func TestNodeIdAllocator_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := testDB(t)
+	for port := 8000; port < 8005; port++ {
+		allocator := NewNodeIdAllocator(ctx, source, testName, port, time.Second, 5*time.Second, logger,
+			WithPersistAddress(true))
+		_, err := allocator.Alloc()
+		require.NoError(t, err)
+	}
+
+	exporter := NewNodeIdAllocator(ctx, source, testName, testPort, time.Second, 5*time.Second, logger,
+		WithPersistAddress(true))
+	records, err := exporter.Export(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	for i := 1; i < len(records); i++ {
+		assert.Less(t, records[i-1].NodeID, records[i].NodeID)
+	}
+
+	target := testDB(t)
+	importer := NewNodeIdAllocator(ctx, target, testName, testPort, time.Second, 5*time.Second, logger,
+		WithPersistAddress(true))
+	require.NoError(t, importer.Import(ctx, records))
+	imported, err := importer.Export(ctx)
+	require.NoError(t, err)
+	require.Len(t, imported, len(records))
+	for i := range records {
+		assert.Equal(t, records[i].Key, imported[i].Key)
+		assert.Equal(t, records[i].NodeID, imported[i].NodeID)
+		assert.Equal(t, records[i].Time, imported[i].Time)
+		assert.True(t, records[i].Created.Equal(imported[i].Created))
+		assert.True(t, records[i].Updated.Equal(imported[i].Updated))
+		assert.Equal(t, records[i].IP, imported[i].IP)
+		assert.Equal(t, records[i].DeployType, imported[i].DeployType)
+	}
+
+	// 重复导入时回滚，不写入任何记录
+	other := testDB(t)
+	otherImporter := NewNodeIdAllocator(ctx, other, testName, testPort, time.Second, 5*time.Second, logger)
+	duplicated := append(records[:1:1], NodeRecord{Key: fmt.Sprintf("%s-copy", records[0].Key),
+		NodeID: records[0].NodeID, Time: records[0].Time, Created: records[0].Created, Updated: records[0].Updated})
+	assert.Error(t, otherImporter.Import(ctx, duplicated))
+	left, err := otherImporter.Export(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, left)
+}
@@ -0,0 +1,35 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 集群时间下限
+package gorm
+
+import (
+	"context"
+)
+
+// ClusterFloor 查询抢占时间间隔内活跃的记录中保存的最大时间，作为集群已生成ID的时间下限
+// 各实例的时间同步器持续写入最近生成的ID的时间，因此结果最多落后其他实例一个同步间隔；
+// 开启WithClock偏移的实例写入的时间包含其偏移
+// @param ctx
+// @return int64 毫秒时间戳，没有活跃的记录时为0
+// @return error
+func (m *NodeIdAllocator) ClusterFloor(ctx context.Context) (int64, error) {
+	active := m.timeUnit.From(m.clock.Now()) - m.timeUnit.Duration(m.nodeIdContentionInterval)
+	var times []int64
+	tab := m.dao.SnowflakeKv
+	if err := tab.WithContext(ctx).Where(tab.Time.Gte(active)).Pluck(tab.Time, &times); err != nil {
+		return 0, err
+	}
+
+	floor := int64(0)
+	for _, saved := range times {
+		if milli := m.timeUnit.Time(saved).UnixMilli(); milli > floor {
+			floor = milli
+		}
+	}
+	return floor, nil
+}
@@ -0,0 +1,45 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 集群时间下限测试
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNodeIdAllocator_ClusterFloor 测试集群时间下限为活跃记录中的最大时间，已失效的记录不计入
+func TestNodeIdAllocator_ClusterFloor(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithTimeUnit(TimeUnitSeconds))
+	floor, err := allocator.ClusterFloor(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, floor)
+
+	now := time.Now()
+	ahead := now.Add(3 * time.Second)
+	tab := dao.Use(db).SnowflakeKv
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType, tab.LeaseExpiry).Create(
+		&model.SnowflakeKv{Key: "floor_10.0.0.1_8080_physical", NodeID: 1, Time: now.Unix(), Created: &now,
+			Updated: now},
+		&model.SnowflakeKv{Key: "floor_10.0.0.2_8080_physical", NodeID: 2, Time: ahead.Unix(), Created: &now,
+			Updated: now},
+		&model.SnowflakeKv{Key: "floor_10.0.0.3_8080_physical", NodeID: 3, Time: now.Add(-time.Minute).Unix(),
+			Created: &now, Updated: now},
+	))
+	floor, err = allocator.ClusterFloor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ahead.Unix()*1000, floor)
+}
@@ -10,6 +10,9 @@ package gorm
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/GuoxinL/snowflake-gorm/nodeid"
@@ -17,113 +20,919 @@ import (
 	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
 	"github.com/bwmarrin/snowflake"
 	"go.uber.org/atomic"
+	"gorm.io/gen/field"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var _ snowflake.TimeSynchronizer = new(TimeSynchronizer)
 var _ snowflake.NodeIdAllocator = new(NodeIdAllocator)
 
+// competingWriteTolerance 判断其他实例写入时容忍的更新时间误差，覆盖数据库时间精度的截断与进位
+var competingWriteTolerance = time.Second
+
 // NodeIdAllocator gorm节点ID分配器
 type NodeIdAllocator struct {
 	ctx context.Context
 	dao *dao.Query
 	// nodeIdKey 节点id key
 	nodeIdKey string
+	// ip 构造时解析出的IP
+	ip string
+	// deployType 构造时检测到或通过WithDeployType指定的部署类型
+	deployType DeployType
+	// addressFamily 节点ID Key使用的IP地址族
+	addressFamily AddressFamily
+	// ipPrecedence POD_IP与网卡扫描得到的IP不一致时的选择规则
+	ipPrecedence IPPrecedence
+	// podIPFile 提供Pod IP的文件路径
+	podIPFile string
+	// onIPChange Refresh时检测到IP变化的回调
+	onIPChange func(stored, current string)
+	// ipErr 构造时要求POD_IP与网卡IP一致而二者不一致，非nil时拒绝分配
+	ipErr error
+	// keyErr 构造时节点ID Key的标识、端口或部署类型包含分隔符，非nil时拒绝分配
+	keyErr error
+	// sticky 首次分配时是否优先沿用key记录中的节点ID
+	sticky bool
+	// nodeRange 生效的节点ID范围，nil表示不限定
+	nodeRange *nodeid.NodeIdRange
+	// reservedNodeIds 分配时跳过的保留节点ID
+	reservedNodeIds []int64
+	// persistAddress 是否将IP与部署类型写入独立的列
+	persistAddress bool
+	// strictUniqueness 节点ID被其他活跃的key持有时是否直接报错
+	strictUniqueness bool
 
 	// 时钟回拨容忍时间
 	acceptableClockDrift time.Duration
 	// 节点id抢占时间间隔
 	nodeIdContentionInterval time.Duration
+	// 小幅时钟回拨时轮询时钟的间隔
+	rollbackPollInterval time.Duration
+	// 小幅时钟回拨时最长等待时间，0表示等待完整的容忍时间
+	maxRollbackWait time.Duration
+	// 自身记录的最大有效时长
+	staleIdentityThreshold time.Duration
+	// 节点ID的外部持久化，nil表示使用数据库
+	store NodeIdStore
 	// 节点id分配器
 	snowflake.NodeIdAllocator
+	// 持久化时间戳的单位
+	timeUnit TimeUnit
+	// 获取当前时间的时钟
+	clock Clock
+	// 最近一次分配的结果
+	lastOutcome atomic.Int32
+	// metrics 监控指标钩子
+	metrics Metrics
+	// admissionLimit 同时进行的分配数上限，0表示不限制
+	admissionLimit int64
+	// admissionLease 准入令牌的租约
+	admissionLease time.Duration
+	// lease 节点ID租约时长，0表示不使用租约
+	lease time.Duration
+	// leaseRenewInterval 续约间隔
+	leaseRenewInterval time.Duration
+	// leaseOnce 首次分配成功后启动续约goroutine，leaseStop 停止续约
+	leaseOnce     sync.Once
+	leaseStop     chan struct{}
+	leaseStopOnce sync.Once
+
+	mu sync.Mutex
+	// nodeId 当前生效的节点ID，allocated为false时无效
+	nodeId    int64
+	allocated bool
+	// onNodeIdChange 节点ID变化回调
+	onNodeIdChange []func(old, new int64)
+	// history 节点ID变化历史
+	history allocHistory
+	// startupOnce 首次分配成功后输出一次启动日志
+	startupOnce sync.Once
 
 	logger Logger
 }
 
 // NewNodeIdAllocator 创建一个新的节点ID分配器
+// acceptableClockDrift为0（或换算为持久化单位后为0）表示不容忍时钟回拨：保存的时间晚于当前时间即漂移到新的节点ID，
+// 负数按0处理
 func NewNodeIdAllocator(ctx context.Context, db *gorm.DB, name string, port int,
-	acceptableClockDrift, nodeIdContentionInterval time.Duration, logger Logger) *NodeIdAllocator {
+	acceptableClockDrift, nodeIdContentionInterval time.Duration, logger Logger, opts ...OptionFn) *NodeIdAllocator {
+	op := newOption(opts...)
 	// 1. 查询当前节点ID
-	nodeIdKey := GetNodeIdKey(name, port)
+	ip, ipErr := keyIP(op, logger)
+	deployType := keyDeployType(op, true)
+	identity := keyIdentity(op.identityFile, ip)
+	if identity != ip {
+		// 使用标识文件时IP不参与节点ID Key
+		ipErr = nil
+	}
+	nodeIdKey := formatNodeIdKey(name, identity, port, deployType, op.keySeparator)
+	if err := ValidatePort(port); err != nil {
+		logger.Errorf("node id key contains an invalid port, the key may not match the intended identity. key: %s, error: %v",
+			nodeIdKey, err)
+	}
+	keyErr := checkNodeIdKey(nodeIdKey, name, identity, port, deployType, op.keySeparator)
+	if keyErr != nil {
+		logger.Errorf("node id key is ambiguous, alloc is refused until the key separator is changed. error: %v",
+			keyErr)
+	}
+	var allocator snowflake.NodeIdAllocator = nodeid.NewHashNodeIdAllocator(nodeIdKey)
+	nodeRange := op.nodeRange
+	if nodeRange == nil {
+		if partition, ok := op.partitions.rangeOf(deployType); ok {
+			nodeRange = &partition
+		}
+	}
+	if nodeRange != nil {
+		allocator = nodeid.NewRangeNodeIdAllocator(allocator, *nodeRange)
+	}
+	if len(op.reservedNodeIds) > 0 {
+		allocator = nodeid.NewReservedNodeIdAllocator(allocator, op.reservedNodeIds)
+	}
+	if acceptableClockDrift < 0 {
+		acceptableClockDrift = 0
+	}
+	if op.timeUnit.Duration(acceptableClockDrift) == 0 {
+		logger.Warnf("acceptable clock drift %s is zero in %s, any clock rollback migrates the node id. key: %s",
+			acceptableClockDrift, op.timeUnit, nodeIdKey)
+	}
 
 	return &NodeIdAllocator{
 		ctx:                      ctx,
-		dao:                      dao.Use(db),
+		dao:                      useDao(db, op.columns),
 		logger:                   logger,
 		nodeIdKey:                nodeIdKey,
 		acceptableClockDrift:     acceptableClockDrift,
 		nodeIdContentionInterval: nodeIdContentionInterval,
-		NodeIdAllocator:          nodeid.NewHashNodeIdAllocator(nodeIdKey),
+		NodeIdAllocator:          allocator,
+		timeUnit:                 op.timeUnit,
+		clock:                    op.clock,
+		ip:                       ip,
+		deployType:               deployType,
+		addressFamily:            op.addressFamily,
+		ipPrecedence:             op.ipPrecedence,
+		podIPFile:                op.podIPFile,
+		onIPChange:               op.onIPChange,
+		ipErr:                    ipErr,
+		keyErr:                   keyErr,
+		sticky:                   op.sticky,
+		nodeRange:                nodeRange,
+		reservedNodeIds:          op.reservedNodeIds,
+		persistAddress:           op.persistAddress,
+		strictUniqueness:         op.strictUniqueness,
+		rollbackPollInterval:     op.rollbackPollInterval,
+		maxRollbackWait:          op.maxRollbackWait,
+		staleIdentityThreshold:   op.staleIdentityThreshold,
+		store:                    op.store,
+		metrics:                  op.metrics,
+		admissionLimit:           op.admissionLimit,
+		admissionLease:           op.admissionLease,
+		lease:                    op.lease,
+		leaseRenewInterval:       op.leaseRenewInterval,
+		leaseStop:                make(chan struct{}),
 	}
 }
 
 // Alloc 分配一个新的节点ID
-func (m NodeIdAllocator) Alloc() (int64, error) {
-	now := time.Now()
-	nowMilli := now.UnixMilli()
+// 每次调用都会将记录中保存的时间推进到当前时间；不运行时间同步器的部署可定期调用Alloc（或Wrapper.Refresh）
+// 作为轻量的替代，重启后的时钟回拨检测以最近一次调用的时间为准，调用间隔内生成的ID不受保护
+func (m *NodeIdAllocator) Alloc() (int64, error) {
+	result, err := m.AllocDetailed()
+	return result.NodeID, err
+}
+
+// AllocDetailed 分配一个新的节点ID并返回分配结果，行为与Alloc一致
+// 调用方可借此得知本次分配是否新建、抢占或漂移了节点ID，而无需依赖监控指标钩子
+// @return AllocResult
+// @return error
+func (m *NodeIdAllocator) AllocDetailed() (AllocResult, error) {
+	return m.allocate(m.ctx, m.dao, m.logger)
+}
+
+// AllocTx 在调用方提供的事务中分配节点ID，记录的查询、创建与更新均在该事务中执行
+// 适用于需要与其他注册记录原子地初始化的场景；事务回滚后记录不会保留，但分配器内存中的节点ID已更新，需重新调用Alloc
+// @param tx 调用方开启的gorm事务
+// @return int64
+// @return error
+func (m *NodeIdAllocator) AllocTx(tx *gorm.DB) (int64, error) {
+	result, err := m.allocate(m.ctx, m.dao.WithTx(tx), m.logger)
+	return result.NodeID, err
+}
+
+// AllocWithLogger 分配一个新的节点ID，本次分配的日志输出到指定的日志记录器
+// 适用于在请求级的启动流程中分配，使日志携带该请求的上下文
+// @param logger 为nil时使用创建时的日志记录器
+// @return int64
+// @return error
+func (m *NodeIdAllocator) AllocWithLogger(logger Logger) (int64, error) {
+	if logger == nil {
+		logger = m.logger
+	}
+	result, err := m.allocate(m.ctx, m.dao, logger)
+	return result.NodeID, err
+}
 
-	nodeId, err := m.NodeIdAllocator.Alloc()
+// Refresh 重新执行分配与抢占逻辑，返回当前应使用的节点ID
+// 用于挂起恢复（单调时钟出现较大间隔）后，节点ID可能已在挂起期间被回收或被其他实例持有的场景；
+// 开启WithPersistAddress时随后检查IP是否变化
+// @param ctx
+// @return int64
+// @return error
+func (m *NodeIdAllocator) Refresh(ctx context.Context) (int64, error) {
+	result, err := m.allocate(ctx, m.dao, m.logger)
+	if err == nil {
+		m.checkIPChange()
+	}
+	return result.NodeID, err
+}
+
+// checkIPChange 开启WithPersistAddress时比较记录中的IP与当前解析出的IP，不一致时输出告警并调用WithOnIPChange设置的回调
+func (m *NodeIdAllocator) checkIPChange() {
+	if !m.persistAddress {
+		return
+	}
+	stored, current, changed, err := m.IPChanged()
 	if err != nil {
-		return 0, err
+		m.logger.Warnf("check ip change failed. key: %s, error: %v", m.nodeIdKey, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	m.logger.Warnf("ip changed, the node id key no longer identifies this instance and the allocator should be "+
+		"recreated. key: %s, stored: %s, current: %s", m.nodeIdKey, stored, current)
+	if m.onIPChange != nil {
+		m.onIPChange(stored, current)
+	}
+}
+
+// NodeId 当前生效的节点ID，尚未分配时返回-1
+// @return int64
+func (m *NodeIdAllocator) NodeId() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.allocated {
+		return -1
+	}
+	return m.nodeId
+}
+
+// NodeIdKey 节点ID Key
+// @return string
+func (m *NodeIdAllocator) NodeIdKey() string {
+	return m.nodeIdKey
+}
+
+// DeployType 构造时检测到或通过WithDeployType指定的部署类型
+// @return DeployType
+func (m *NodeIdAllocator) DeployType() DeployType {
+	return m.deployType
+}
+
+// IP 构造时解析出的IP，使用WithIdentityFile时同样返回解析出的IP，而不是key中的标识
+// @return string
+func (m *NodeIdAllocator) IP() string {
+	return m.ip
+}
+
+// allocate 分配节点ID，记录结果并触发节点ID变化回调
+func (m *NodeIdAllocator) allocate(ctx context.Context, q *dao.Query, logger Logger) (AllocResult, error) {
+	if err := CheckContext(ctx); err != nil {
+		return AllocResult{}, err
+	}
+	if m.ipErr != nil {
+		return AllocResult{}, m.ipErr
+	}
+	if m.keyErr != nil {
+		return AllocResult{}, m.keyErr
+	}
+	if err := m.checkActive(logger); err != nil {
+		return AllocResult{}, err
+	}
+	// 准入令牌不在调用方的事务中获取，否则其他实例在事务提交前无法看到计数
+	if m.admissionLimit > 0 && m.store == nil {
+		release, err := m.acquireAdmission(ctx, logger)
+		if err != nil {
+			return AllocResult{}, wrapSchemaError(err)
+		}
+		defer release()
+	}
+	// 节点ID变化回调在锁外执行，回调中可以再次Alloc
+	unlock := lockKey(m.nodeIdKey)
+	result, err := m.alloc(ctx, q, logger)
+	unlock()
+	if err != nil {
+		return AllocResult{}, wrapSchemaError(err)
+	}
+	nodeId, previous, outcome := result.NodeID, result.PreviousNodeID, result.Outcome
+	m.markActive()
+	if m.store == nil {
+		recordOwnWrite(m.nodeIdKey, m.clock.Now())
+		if m.lease > 0 {
+			m.leaseOnce.Do(func() { go m.renewLoop() })
+		}
+	}
+
+	m.lastOutcome.Store(int32(outcome))
+	logger.Infof("node id allocated. key: %s, node id: %d, outcome: %s", m.nodeIdKey, nodeId, outcome)
+	m.startupOnce.Do(func() {
+		logger.Infof("snowflake instance started. deploy type: %s, ip: %s, key: %s, node id: %d",
+			m.deployType, m.ip, m.nodeIdKey, nodeId)
+	})
+
+	m.mu.Lock()
+	// 本进程已分配过时以上次分配的节点ID为准，否则以key此前持有的节点ID为准
+	first := !m.allocated
+	if m.allocated {
+		previous = m.nodeId
+	}
+	if first || previous != nodeId {
+		m.history.add(AllocEvent{Time: m.clock.Now(), Old: previous, New: nodeId, Reason: outcome})
+	}
+	m.nodeId, m.allocated = nodeId, true
+	callbacks := m.onNodeIdChange
+	m.mu.Unlock()
+
+	if previous >= 0 && previous != nodeId {
+		for _, callback := range callbacks {
+			callback(previous, nodeId)
+		}
+	}
+	result.PreviousNodeID = previous
+	return result, nil
+}
+
+// OnNodeIdChange 注册节点ID变化回调，在生效的节点ID发生变化（时钟回拨漂移、重新分配等）时同步调用
+// 回调在Alloc返回前执行，不应阻塞
+// @param callback
+func (m *NodeIdAllocator) OnNodeIdChange(callback func(old, new int64)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onNodeIdChange = append(m.onNodeIdChange, callback)
+}
+
+// LastOutcome 最近一次分配的结果
+// @return AllocOutcome
+func (m *NodeIdAllocator) LastOutcome() AllocOutcome {
+	return AllocOutcome(m.lastOutcome.Load())
+}
+
+// Clock 分配器获取当前时间的时钟，即WithClock配置的时钟
+// @return Clock
+func (m *NodeIdAllocator) Clock() Clock {
+	return m.clock
+}
+
+// Dao 分配器使用的gorm gen查询对象，可基于类型安全的字段构建自定义查询
+// 注意：通过它写入snowflake_kv会绕过分配器的一致性保证，建议仅用于查询
+// @return *dao.Query
+func (m *NodeIdAllocator) Dao() *dao.Query {
+	return m.dao
+}
+
+// Close 删除当前key持有节点ID的记录并注销进程内注册，节点ID可立即被其他实例使用
+// 注意：记录中的时间同时用于时钟回拨检测，删除后以相同身份在时钟回拨的机器上重启将无法检测回拨
+// 使用外部持久化时仅注销进程内注册，不修改store
+// @return error
+func (m *NodeIdAllocator) Close() error {
+	m.mu.Lock()
+	nodeId, allocated := m.nodeId, m.allocated
+	m.mu.Unlock()
+	m.stopLease()
+	defer m.Release()
+	if !allocated || m.store != nil {
+		return nil
+	}
+
+	tab := m.dao.SnowflakeKv
+	_, err := tab.WithContext(m.ctx).Where(tab.Key.Eq(m.nodeIdKey), tab.NodeID.Eq(nodeId)).Delete()
+	return err
+}
+
+// Explain 诊断信息，描述节点ID Key、哈希值、哈希槽位、最近一次分配的结果以及记录中保存的时间
+// 只读，不修改任何状态
+// @return string
+func (m *NodeIdAllocator) Explain() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "key: %s\n", m.nodeIdKey)
+	fmt.Fprintf(&b, "hash: %d\n", nodeid.KeyHash(m.nodeIdKey))
+	if slot, err := m.NodeIdAllocator.Alloc(); err != nil {
+		fmt.Fprintf(&b, "slot: error: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "slot: %d\n", slot)
+	}
+
+	m.mu.Lock()
+	nodeId, allocated := m.nodeId, m.allocated
+	m.mu.Unlock()
+	if !allocated {
+		b.WriteString("node id: not allocated\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "node id: %d\n", nodeId)
+	fmt.Fprintf(&b, "outcome: %s\n", m.LastOutcome())
+
+	tab := m.dao.SnowflakeKv
+	saved, err := tab.WithContext(m.ctx).Select(m.rowColumns()...).Where(tab.Key.Eq(m.nodeIdKey)).Take()
+	if err != nil {
+		fmt.Fprintf(&b, "stored: error: %v\n", err)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "stored: node id %d, time %d (%s), updated %s\n", saved.NodeID, saved.Time,
+		m.timeUnit.Time(saved.Time).Format(time.RFC3339Nano), saved.Updated.Format(time.RFC3339))
+	return b.String()
+}
+
+// FreeNodeIds 列出当前空闲的节点ID，即未被抢占时间间隔内活跃过的记录持有的节点ID
+// 超过抢占时间间隔未更新的记录持有的节点ID同样视为空闲
+// @param ctx
+// @return []int64 升序排列
+// @return error
+func (m *NodeIdAllocator) FreeNodeIds(ctx context.Context) ([]int64, error) {
+	active := m.timeUnit.From(m.clock.Now()) - m.timeUnit.Duration(m.nodeIdContentionInterval)
+	var held []int64
+	tab := m.dao.SnowflakeKv
+	if err := tab.WithContext(ctx).Where(tab.Time.Gte(active)).Pluck(tab.NodeID, &held); err != nil {
+		return nil, err
+	}
+	taken := make(map[int64]struct{}, len(held))
+	for _, nodeId := range held {
+		taken[nodeId] = struct{}{}
+	}
+
+	slots := nodeid.NodeIDSpace()
+	free := make([]int64, 0, slots-int64(len(taken)))
+	for nodeId := int64(0); nodeId < slots; nodeId++ {
+		if _, ok := taken[nodeId]; !ok {
+			free = append(free, nodeId)
+		}
 	}
+	return free, nil
+}
 
+// IPChanged 比较记录中保存的IP与当前解析出的IP是否不一致
+// 仅在开启WithPersistAddress后有效，IP变化意味着节点ID Key已不再代表当前实例，调用方需据此重新创建分配器；
+// Refresh成功后会自动比较并调用WithOnIPChange设置的回调，网卡扫描结果缓存ipCacheTTL，变化最多延迟一个缓存周期被发现
+// @return stored 记录中保存的IP
+// @return current 当前解析出的IP
+// @return changed 是否发生变化
+// @return err
+func (m *NodeIdAllocator) IPChanged() (stored, current string, changed bool, err error) {
 	tab := m.dao.SnowflakeKv
+	saved, err := tab.WithContext(m.ctx).Select(m.rowColumns()...).Where(tab.Key.Eq(m.nodeIdKey)).Take()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	current, _ = resolveIP(podIP(m.podIPFile, m.addressFamily), m.addressFamily, m.ipPrecedence)
+	return saved.IP, current, saved.IP != "" && saved.IP != current, nil
+}
+
+// Migration 节点ID漂移，沿内部分配器的漂移序列探测，跳过已被其他key持有的节点ID
+// 漂移序列不一定覆盖所有节点ID，序列出现重复后在生效范围内线性查找未保留的空闲节点ID，
+// 均已被占用时返回nodeid.ErrMigrationExhausted
+// @param nodeId
+// @return int64
+// @return error
+func (m *NodeIdAllocator) Migration(nodeId int64) (int64, error) {
+	return m.migration(m.ctx, m.dao, nodeId)
+}
+
+// migration 节点ID漂移
+func (m *NodeIdAllocator) migration(ctx context.Context, q *dao.Query, nodeId int64) (int64, error) {
+	var held []int64
+	tab := q.SnowflakeKv
+	if err := tab.WithContext(ctx).Where(tab.Key.Neq(m.nodeIdKey)).Pluck(tab.NodeID, &held); err != nil {
+		return 0, err
+	}
+	taken := make(map[int64]struct{}, len(held))
+	for _, id := range held {
+		taken[id] = struct{}{}
+	}
+
+	free := func(candidate int64) bool {
+		_, ok := taken[candidate]
+		return !ok
+	}
+	if candidate, ok, err := m.probeMigration(nodeId, free); err != nil || ok {
+		return candidate, err
+	}
+	if candidate, ok := m.scanSlots(nodeId, free); ok {
+		return candidate, nil
+	}
+	return 0, fmt.Errorf("%w. key: %s, node id: %d", nodeid.ErrMigrationExhausted, m.nodeIdKey, nodeId)
+}
+
+// probeMigration 沿内部分配器的漂移序列探测，返回第一个满足accept的节点ID
+// 漂移序列是确定的，出现重复或内部分配器返回nodeid.ErrMigrationExhausted即说明已遍历完整个序列，此时返回false
+// @return int64
+// @return bool 是否找到
+// @return error
+func (m *NodeIdAllocator) probeMigration(nodeId int64, accept func(candidate int64) bool) (int64, bool, error) {
+	visited := map[int64]struct{}{nodeId: {}}
+	for candidate := nodeId; ; {
+		var err error
+		if candidate, err = m.NodeIdAllocator.Migration(candidate); err != nil {
+			if errors.Is(err, nodeid.ErrMigrationExhausted) {
+				return 0, false, nil
+			}
+			return 0, false, err
+		}
+		if _, ok := visited[candidate]; ok {
+			return 0, false, nil
+		}
+		visited[candidate] = struct{}{}
+		if accept(candidate) {
+			return candidate, true, nil
+		}
+	}
+}
+
+// scanSlots 从nodeId的下一个节点ID开始，在生效范围内线性遍历未保留的节点ID，返回第一个满足accept的节点ID
+// 未限定范围时遍历当前位布局下的全部节点ID，nodeId本身不参与遍历
+// @return int64
+// @return bool 是否找到
+func (m *NodeIdAllocator) scanSlots(nodeId int64, accept func(candidate int64) bool) (int64, bool) {
+	nodeRange := nodeid.NodeIdRange{Min: 0, Max: nodeid.NodeIDSpace() - 1}
+	if m.nodeRange != nil {
+		nodeRange = *m.nodeRange
+	}
+	reserved := make(map[int64]struct{}, len(m.reservedNodeIds))
+	for _, id := range m.reservedNodeIds {
+		reserved[id] = struct{}{}
+	}
+	size := nodeRange.Size()
+	if size <= 0 {
+		return 0, false
+	}
+	offset := (nodeId - nodeRange.Min) % size
+	if offset < 0 {
+		offset += size
+	}
+	for i := int64(1); i <= size; i++ {
+		candidate := nodeRange.Min + (offset+i)%size
+		if candidate == nodeId {
+			continue
+		}
+		if _, ok := reserved[candidate]; ok {
+			continue
+		}
+		if accept(candidate) {
+			return candidate, true
+		}
+	}
+	return 0, false
+}
+
+// rollbackMigration 时钟回拨时的节点ID漂移，沿漂移序列（出现重复后在生效范围内线性查找）优先选择数据库中空闲的节点ID，
+// 其次是失效记录持有的节点ID
+// 选中失效记录持有的节点ID时删除该记录（持有者在查询之后刷新了记录时不删除）；
+// 两者都不存在时回退到内部分配器的漂移，由后续的冲突检测继续探测
+func (m *NodeIdAllocator) rollbackMigration(ctx context.Context, q *dao.Query, nodeId int64,
+	logger Logger) (int64, error) {
+	tab := q.SnowflakeKv
+	others, err := tab.WithContext(ctx).Select(tab.Aliased("key", "node_id", "time")...).
+		Where(tab.Key.Neq(m.nodeIdKey)).Find()
+	if err != nil {
+		return 0, err
+	}
+	owners := make(map[int64]*model.SnowflakeKv, len(others))
+	for _, other := range others {
+		owners[other.NodeID] = other
+	}
+
+	// 先沿漂移序列，序列出现重复后在生效范围内线性查找
+	active := m.timeUnit.From(m.clock.Now()) - m.timeUnit.Duration(m.nodeIdContentionInterval)
+	var stale *model.SnowflakeKv
+	free := func(candidate int64) bool {
+		owner, ok := owners[candidate]
+		if !ok {
+			return true
+		}
+		if stale == nil && owner.Time < active {
+			stale = owner
+		}
+		return false
+	}
+	if candidate, ok, err := m.probeMigration(nodeId, free); err != nil || ok {
+		return candidate, err
+	}
+	if candidate, ok := m.scanSlots(nodeId, free); ok {
+		return candidate, nil
+	}
+
+	if stale != nil {
+		result, err := tab.WithContext(ctx).
+			Where(tab.Key.Eq(stale.Key), tab.NodeID.Eq(stale.NodeID), tab.Time.Eq(stale.Time)).Delete()
+		if err != nil {
+			return 0, err
+		}
+		if result.RowsAffected > 0 {
+			logger.Warnf("migrating onto a node id held by a stale key. key: %s, node id: %d, owner: %s",
+				m.nodeIdKey, stale.NodeID, stale.Key)
+			return stale.NodeID, nil
+		}
+	}
+	logger.Warnf("no free node id in the node id space, falling back to rehash. key: %s, node id: %d",
+		m.nodeIdKey, nodeId)
+	return m.NodeIdAllocator.Migration(nodeId)
+}
+
+// alloc 分配节点ID并返回分配结果，PreviousNodeID为key此前持有的节点ID，不存在时为-1
+// @return AllocResult
+// @return error
+func (m *NodeIdAllocator) alloc(ctx context.Context, q *dao.Query, logger Logger) (AllocResult, error) {
+	if m.store != nil {
+		return m.allocFromStore(logger)
+	}
+
+	now := m.clock.Now()
+	nowTime := m.timeUnit.From(now)
+	previous := int64(-1)
+
+	// 本进程已分配过时从当前节点ID开始，避免重复分配时无故切换节点ID
+	var err error
+	nodeId := m.NodeId()
+	if nodeId < 0 && m.sticky {
+		// 粘性模式下首次分配优先沿用key记录中的节点ID
+		if nodeId, err = m.stickyNodeId(ctx, q); err != nil {
+			return AllocResult{}, err
+		}
+	}
+	if nodeId < 0 {
+		if nodeId, err = m.NodeIdAllocator.Alloc(); err != nil {
+			return AllocResult{}, err
+		}
+	}
+
+	tab := q.SnowflakeKv
 	for {
 		// 1. 查询当前节点ID是否存在
 		var saved *model.SnowflakeKv
-		saved, err = tab.WithContext(m.ctx).Where(tab.Key.Eq(m.nodeIdKey), tab.NodeID.Eq(nodeId)).First()
+		start := time.Now()
+		saved, err = tab.WithContext(ctx).Select(m.rowColumns()...).
+			Where(tab.Key.Eq(m.nodeIdKey), tab.NodeID.Eq(nodeId)).Take()
+		observeSince(m.metrics, DBOpQuery, start)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				// 2. 如果不存在，则创建一个新的节点ID
-				saved = &model.SnowflakeKv{
-					Key:     m.nodeIdKey,
-					NodeID:  nodeId,
-					Time:    nowMilli,
-					Created: &now,
-					Updated: now,
+				// 2. 节点ID已被其他key持有时，严格模式下对活跃的持有者报错，否则探测下一个节点ID
+				var owner *model.SnowflakeKv
+				owner, err = tab.WithContext(ctx).Select(m.rowColumns()...).Where(tab.NodeID.Eq(nodeId)).Take()
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return AllocResult{}, err
+				}
+				if err == nil && owner.Key == m.nodeIdKey {
+					// 两次查询之间当前key的记录被并发创建，重新查询
+					continue
+				}
+				if err == nil && isWatermarkKey(owner.Key) {
+					// 漂移遗留的水位记录过期后删除，重新查询后按空闲的节点ID处理
+					if !m.ownerActive(owner, now, nowTime) {
+						var released bool
+						if released, err = m.releaseWatermark(ctx, q, owner, logger); err != nil {
+							return AllocResult{}, err
+						}
+						if released {
+							continue
+						}
+					} else if m.ownWatermark(owner) {
+						// 当前key因时钟回拨从该节点ID漂移，水位过期前不移回，沿用key记录中的节点ID
+						var held int64
+						if held, err = m.stickyNodeId(ctx, q); err != nil {
+							return AllocResult{}, err
+						}
+						if held >= 0 {
+							logger.Warnf("node id holds a watermark of the key, keeping the migrated node id. "+
+								"key: %s, node id: %d, migrated: %d", m.nodeIdKey, nodeId, held)
+							nodeId = held
+							continue
+						}
+					}
+				}
+				if err == nil {
+					m.checkTimeUnit(owner, now, logger)
+					// 持有者的租约已到期时回收其节点ID，重新查询后按空闲的节点ID处理
+					if m.leaseExpired(owner, now) {
+						var reclaimed bool
+						if reclaimed, err = m.reclaimLease(ctx, q, owner, now, logger); err != nil {
+							return AllocResult{}, err
+						}
+						if reclaimed {
+							continue
+						}
+					}
+					active := m.ownerActive(owner, now, nowTime)
+					if m.strictUniqueness && active {
+						return AllocResult{}, fmt.Errorf("%w. node id: %d, owner: %s",
+							ErrNodeIdCollision, nodeId, owner.Key)
+					}
+					logger.Warnf("node id collision, probing. key: %s, node id: %d, owner: %s",
+						m.nodeIdKey, nodeId, owner.Key)
+					nodeId, err = m.migration(ctx, q, nodeId)
+					if err != nil {
+						return AllocResult{}, err
+					}
+					continue
 				}
 
-				if err = tab.WithContext(m.ctx).Create(saved); err != nil {
-					return 0, err
+				// 3. 如果当前key已持有其他节点ID（如发生过漂移），则将其移动到新的节点ID
+				// key为主键，每个key只有一条记录，原地更新后旧节点ID随即释放；因时钟回拨漂移时在旧节点ID上遗留水位记录
+				var held *model.SnowflakeKv
+				held, err = tab.WithContext(ctx).Select(tab.Aliased("node_id", "time")...).
+					Where(tab.Key.Eq(m.nodeIdKey)).Take()
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return AllocResult{}, err
+				}
+				if err == nil && held.NodeID == nodeId {
+					// 同上，当前key的记录已被并发创建
+					continue
 				}
-				return saved.NodeID, nil
+				if err == nil {
+					if previous < 0 {
+						previous = held.NodeID
+					}
+					columns := []field.AssignExpr{tab.NodeID.Value(nodeId), tab.Time.Value(nowTime), tab.Updated.Value(now)}
+					if m.persistAddress {
+						columns = append(columns, tab.IP.Value(m.ip), tab.DeployType.Value(string(m.deployType)))
+					}
+					if m.lease > 0 {
+						columns = append(columns, tab.LeaseExpiry.Value(now.Add(m.lease)))
+					}
+					if err = m.moveNodeId(ctx, q, held, nodeId, columns, now, nowTime); err != nil {
+						return AllocResult{}, err
+					}
+					return m.result(nodeId, previous, AllocOutcomeMigrated, nowTime), nil
+				}
+
+				// 4. 如果不存在，则创建一个新的节点ID
+				// 按列名写入，以支持映射列名；未开启WithPersistAddress时不写入ip、deploy_type列
+				values := map[string]interface{}{
+					tab.ColumnName("key"):     m.nodeIdKey,
+					tab.ColumnName("node_id"): nodeId,
+					tab.ColumnName("time"):    nowTime,
+					tab.ColumnName("created"): now,
+					tab.ColumnName("updated"): now,
+				}
+				if m.persistAddress {
+					values[tab.ColumnName("ip")] = m.ip
+					values[tab.ColumnName("deploy_type")] = string(m.deployType)
+				}
+				if m.lease > 0 {
+					values[tab.ColumnName("lease_expiry")] = now.Add(m.lease)
+				}
+				// 冲突时不报错：查询与创建之间其他实例（或并发的Alloc）已写入相同的key或节点ID，重新查询后按已有记录处理
+				start = time.Now()
+				result := tab.WithContext(ctx).UnderlyingDB().Table(tab.TableName()).
+					Clauses(clause.OnConflict{DoNothing: true}).Create(values)
+				observeSince(m.metrics, DBOpCreate, start)
+				if result.Error != nil {
+					return AllocResult{}, result.Error
+				}
+				if result.RowsAffected == 0 {
+					logger.Infof("node id record was created concurrently, retrying. key: %s, node id: %d",
+						m.nodeIdKey, nodeId)
+					continue
+				}
+				return m.result(nodeId, previous, AllocOutcomeCreated, nowTime), nil
 			}
-			return 0, err
+			return AllocResult{}, err
 		}
 
+		m.checkTimeUnit(saved, now, logger)
 		// 2. 判断保存的时间是否大于当前时间
-		if saved.Time > nowMilli {
-			// 2.1 如果回拨小于N秒则等待
-			if nowMilli-m.acceptableClockDrift.Microseconds() <= saved.Time {
-				time.Sleep(m.acceptableClockDrift)
-				return saved.NodeID, nil
+		if saved.Time > nowTime {
+			// 2.1 如果回拨小于N秒则等待，容忍时间为0时不等待
+			if tolerance := m.timeUnit.Duration(m.acceptableClockDrift); tolerance > 0 && saved.Time-nowTime <= tolerance {
+				if m.waitFor(saved.Time) {
+					return m.result(saved.NodeID, previous, AllocOutcomeReused, saved.Time), nil
+				}
+				logger.Warnf("clock did not catch up within the max rollback wait %s, migrating. key: %s, node id: %d",
+					m.maxRollbackWait, m.nodeIdKey, saved.NodeID)
 			}
 
 			// 2.2 如果保存的时间大于当前时间，则返回时钟回拨报错
-			m.logger.Errorf("time is rollback, please check the local clock!!! current: %s, saved: %s",
-				now.Format(time.RFC3339), time.UnixMilli(saved.Time).Format(time.RFC3339))
+			logger.Errorf("time is rollback, please check the local clock!!! current: %s, saved: %s",
+				now.Format(time.RFC3339), m.timeUnit.Time(saved.Time).Format(time.RFC3339))
 			// 2.3 节点id漂移
-			nodeId, err = m.NodeIdAllocator.Migration(nodeId)
+			if previous < 0 {
+				previous = saved.NodeID
+			}
+			nodeId, err = m.rollbackMigration(ctx, q, nodeId, logger)
 			if err != nil {
-				return 0, err
+				return AllocResult{}, err
+			}
+			continue
+		}
+
+		// 3. 自身记录过旧时视为失效的前一个实例，删除后从哈希节点ID重新分配
+		if m.staleIdentityThreshold > 0 && nowTime-m.timeUnit.Duration(m.staleIdentityThreshold) > saved.Time {
+			logger.Warnf("node id record is stale, reallocating. key: %s, node id: %d, saved: %s", m.nodeIdKey,
+				saved.NodeID, m.timeUnit.Time(saved.Time).Format(time.RFC3339))
+			if previous < 0 {
+				previous = saved.NodeID
+			}
+			if _, err = tab.WithContext(ctx).Where(tab.Key.Eq(m.nodeIdKey)).Delete(); err != nil {
+				return AllocResult{}, err
+			}
+			if nodeId, err = m.NodeIdAllocator.Alloc(); err != nil {
+				return AllocResult{}, err
 			}
 			continue
 		}
 
-		// 3. 如果当前时间 - 节点id抢占时间间隔还是大于保存的时间 则抢占节点id
-		if nowMilli-m.nodeIdContentionInterval.Milliseconds() > saved.Time {
+		// 4. 如果当前时间 - 节点id抢占时间间隔还是大于保存的时间 则抢占节点id
+		outcome := AllocOutcomeReused
+		if nowTime-m.timeUnit.Duration(m.nodeIdContentionInterval) > saved.Time {
 			saved.NodeID = nodeId
+			outcome = AllocOutcomeContention
 		}
 
-		// 4. 如果保存的时间小于当前时间，则更新保存时间
-		saved.Time = nowMilli
-		saved.Created = nil
-		saved.Updated = now
-		if _, err = tab.WithContext(m.ctx).Where(tab.Key.Eq(m.nodeIdKey), tab.NodeID.Eq(nodeId)).
-			Updates(saved); err != nil {
-			return 0, err
+		// 5. 如果保存的时间小于当前时间，则更新保存时间
+		// 查询之后时间同步器可能已写入更新的时间，仅在保存的时间不大于当前时间时更新，保证时间不回退
+		columns := []field.AssignExpr{tab.Time.Value(nowTime), tab.Updated.Value(now)}
+		if m.persistAddress {
+			columns = append(columns, tab.IP.Value(m.ip), tab.DeployType.Value(string(m.deployType)))
+		}
+		if m.lease > 0 {
+			columns = append(columns, tab.LeaseExpiry.Value(now.Add(m.lease)))
+		}
+		start = time.Now()
+		_, err = tab.WithContext(ctx).Where(tab.Key.Eq(m.nodeIdKey), tab.NodeID.Eq(nodeId), tab.Time.Lte(nowTime)).
+			UpdateSimple(columns...)
+		observeSince(m.metrics, DBOpUpdate, start)
+		if err != nil {
+			return AllocResult{}, err
+		}
+		if previous < 0 {
+			previous = saved.NodeID
+		}
+		return m.result(saved.NodeID, previous, outcome, nowTime), nil
+	}
+}
+
+// checkTimeUnit 记录保存的时间更像以另一个单位写入时输出告警
+// 共享同一张表的实例配置了不同的WithTimeUnit时，回拨与抢占判断会静默失效
+func (m *NodeIdAllocator) checkTimeUnit(row *model.SnowflakeKv, now time.Time, logger Logger) {
+	if unit, mismatch := m.timeUnit.mismatch(row.Time, now); mismatch {
+		logger.Warnf("saved time looks like %s but the time unit is %s, check WithTimeUnit of every instance "+
+			"sharing the table. key: %s, row: %s, node id: %d, saved: %d", unit, m.timeUnit, m.nodeIdKey, row.Key,
+			row.NodeID, row.Time)
+	}
+}
+
+// stickyNodeId key记录中仍可使用的节点ID，记录不存在或节点ID已不在当前的节点ID空间、范围内或被保留时返回-1
+func (m *NodeIdAllocator) stickyNodeId(ctx context.Context, q *dao.Query) (int64, error) {
+	tab := q.SnowflakeKv
+	held, err := tab.WithContext(ctx).Select(tab.Aliased("node_id")...).Where(tab.Key.Eq(m.nodeIdKey)).Take()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	if held.NodeID < 0 || held.NodeID >= nodeid.NodeIDSpace() || (m.nodeRange != nil && !m.nodeRange.Contains(held.NodeID)) {
+		return -1, nil
+	}
+	for _, reserved := range m.reservedNodeIds {
+		if held.NodeID == reserved {
+			return -1, nil
 		}
-		return saved.NodeID, nil
+	}
+	return held.NodeID, nil
+}
+
+// result 构造分配结果
+// @param stored 分配后记录中保存的时间，为持久化单位的时间戳
+func (m *NodeIdAllocator) result(nodeId, previous int64, outcome AllocOutcome, stored int64) AllocResult {
+	return AllocResult{NodeID: nodeId, Outcome: outcome, StoredTime: m.timeUnit.Time(stored), PreviousNodeID: previous}
+}
+
+// waitFor 等待时钟追上保存的时间，最长等待时钟回拨容忍时间，配置了maxRollbackWait时最长等待maxRollbackWait
+// @param saved 保存的时间
+// @return bool 时钟是否已追上保存的时间，未配置maxRollbackWait时回拨量不超过容忍时间，总是返回true
+func (m *NodeIdAllocator) waitFor(saved int64) bool {
+	if m.maxRollbackWait <= 0 || m.maxRollbackWait >= m.acceptableClockDrift {
+		m.poll(saved, m.acceptableClockDrift)
+		return true
+	}
+
+	m.poll(saved, m.maxRollbackWait)
+	return m.timeUnit.From(m.clock.Now()) >= saved
+}
+
+// poll 轮询时钟直到追上保存的时间，最长等待wait，未配置轮询间隔时直接等待wait
+func (m *NodeIdAllocator) poll(saved int64, wait time.Duration) {
+	if m.rollbackPollInterval <= 0 {
+		time.Sleep(wait)
+		return
+	}
+
+	deadline := time.Now().Add(wait)
+	for m.timeUnit.From(m.clock.Now()) < saved && time.Now().Before(deadline) {
+		time.Sleep(m.rollbackPollInterval)
 	}
 }
 
@@ -134,26 +943,67 @@ type TimeSynchronizer struct {
 	ticker    *time.Ticker
 	nodeIdKey string
 	logger    Logger
+	// 持久化时间戳的单位
+	timeUnit TimeUnit
+	// 获取当前时间的时钟
+	clock Clock
+	// stop 停止信号，done 同步goroutine退出信号
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	running  atomic.Bool
+	// paused 暂停期间不写入数据库
+	paused atomic.Bool
+	// lastSync 最近一次成功写入数据库的时间（UnixNano），尚未成功写入时为Run的时间
+	lastSync atomic.Int64
+	// watermark 最近一次成功写入数据库的水位，叠加时钟偏移后的毫秒时间戳
+	watermark atomic.Int64
+	// detectCompetingWriter 写入前是否检查记录被其他实例刷新
+	detectCompetingWriter bool
+	// competingWrites 检测到其他实例刷新记录的次数
+	competingWrites atomic.Int64
+	// degradedThreshold 连续写入失败多少次后进入降级状态
+	degradedThreshold int64
+	// failures 连续写入失败的次数，degraded 是否处于降级状态
+	failures atomic.Int64
+	degraded atomic.Bool
+	// degradedMu 保护onDegradedChange
+	degradedMu sync.Mutex
+	// onDegradedChange 降级状态变化回调
+	onDegradedChange []func(degraded bool)
+	// metrics 监控指标钩子
+	metrics Metrics
 
-	// 填充前缀，避免与前面字段发生伪共享
-	_pad0 [56]byte
-
-	// curr 独占整个缓存行
-	curr atomic.Int64
-
-	// 填充后缀，防止后续字段干扰
-	_pad1 [56]byte
+	// curr 内存时间，默认独占整个缓存行，WithCachePadding(false)时不填充
+	curr *atomic.Int64
 }
 
-func NewTimeSynchronizer(ctx context.Context, db *gorm.DB, name string, port int, interval time.Duration, logger Logger) *TimeSynchronizer {
-	nodeIdKey := GetNodeIdKey(name, port)
+func NewTimeSynchronizer(ctx context.Context, db *gorm.DB, name string, port int, interval time.Duration, logger Logger,
+	opts ...OptionFn) *TimeSynchronizer {
+	op := newOption(opts...)
+	ip, _ := keyIP(op, logger)
+	nodeIdKey := formatNodeIdKey(name, keyIdentity(op.identityFile, ip), port,
+		keyDeployType(op, false), op.keySeparator)
+	if err := ValidatePort(port); err != nil {
+		logger.Errorf("node id key contains an invalid port, the key may not match the intended identity. key: %s, error: %v",
+			nodeIdKey, err)
+	}
 
 	return &TimeSynchronizer{
 		ctx:       ctx,
-		dao:       dao.Use(db),
+		dao:       useDao(db, op.columns),
 		nodeIdKey: nodeIdKey,
 		ticker:    time.NewTicker(interval),
 		logger:    logger,
+		timeUnit:  op.timeUnit,
+		clock:     op.clock,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		curr:      newTimeCell(op.cachePadding),
+
+		detectCompetingWriter: op.detectCompetingWriter,
+		degradedThreshold:     op.degradedThreshold,
+		metrics:               op.metrics,
 	}
 }
 func (m *TimeSynchronizer) Async(t int64) {
@@ -165,11 +1015,22 @@ func (m *TimeSynchronizer) Async(t int64) {
 }
 
 func (m *TimeSynchronizer) Run() {
+	if !m.running.CAS(false, true) {
+		return
+	}
+	m.lastSync.Store(time.Now().UnixNano())
 	go func(m *TimeSynchronizer) {
+		defer close(m.done)
+		defer m.ticker.Stop()
 		for {
 			select {
 			case <-m.ticker.C:
-				m.updateDB()
+				m.safeUpdateDB()
+			case <-m.stop:
+				// 停止前写入最后的时间
+				m.safeUpdateDB()
+				m.logger.Info("time synchronizer is stopped")
+				return
 			case <-m.ctx.Done():
 				m.logger.Info("time synchronizer is done")
 				return
@@ -178,23 +1039,122 @@ func (m *TimeSynchronizer) Run() {
 	}(m)
 }
 
+// Stop 写入最后的时间并停止同步goroutine，等待其退出
+func (m *TimeSynchronizer) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	if m.running.Load() {
+		<-m.done
+	}
+}
+
+// Pause 暂停向数据库写入时间，同步goroutine继续运行
+func (m *TimeSynchronizer) Pause() {
+	m.paused.Store(true)
+}
+
+// Resume 恢复向数据库写入时间
+func (m *TimeSynchronizer) Resume() {
+	m.paused.Store(false)
+}
+
 // updateDB 将当前时间同步到数据库
 func (m *TimeSynchronizer) updateDB() {
-	currentTime := m.curr.Load()
-	if currentTime == 0 {
-		return
+	_ = m.flush(m.ctx)
+}
+
+// FlushNow 立即将当前时间写入数据库，不等待下一次tick，返回写入的错误
+// 用于Alloc后需要读取到最新时间的场景；尚未生成过ID或已暂停时不写入，返回nil
+// @param ctx
+// @return error
+func (m *TimeSynchronizer) FlushNow(ctx context.Context) error {
+	if err := CheckContext(ctx); err != nil {
+		return err
 	}
+	return m.flush(ctx)
+}
 
-	snowflakeKv := model.SnowflakeKv{
-		Key:     m.nodeIdKey,
-		Time:    currentTime,
-		Updated: time.Now(),
+// flush 将当前时间同步到数据库
+func (m *TimeSynchronizer) flush(ctx context.Context) error {
+	currentTime := m.curr.Load()
+	if currentTime == 0 || m.paused.Load() {
+		return nil
 	}
+
+	// Async接收的是系统时间的毫秒时间戳，写入时叠加时钟偏移并转换为配置的单位
 	tab := m.dao.SnowflakeKv
-	// 保存
-	if _, err := tab.WithContext(m.ctx).Where().Updates(snowflakeKv); err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			m.logger.Errorf("update time failed. error: %v", err)
+	if m.detectCompetingWriter {
+		m.checkCompetingWriter(ctx)
+	}
+	// 保存，仅在保存的时间不大于写入的时间时更新，避免覆盖分配器并发写入的更新的时间
+	now := m.clock.Now()
+	watermark := currentTime + skewMilli(m.clock)
+	saved := m.timeUnit.FromMilli(watermark)
+	start := time.Now()
+	_, err := tab.WithContext(ctx).Where(tab.Key.Eq(m.nodeIdKey), tab.Time.Lte(saved)).
+		UpdateSimple(tab.Time.Value(saved), tab.Updated.Value(now))
+	observeSince(m.metrics, DBOpSync, start)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
 		}
+		m.logger.Errorf("update time failed. error: %v", err)
+		m.writeFailed()
+		return err
+	}
+	recordOwnWrite(m.nodeIdKey, now)
+	m.lastSync.Store(time.Now().UnixNano())
+	m.watermark.Store(watermark)
+	m.writeSucceeded()
+	return nil
+}
+
+// checkCompetingWriter 检查记录的更新时间是否晚于本进程最近一次写入，晚于说明有其他实例以相同的key刷新记录
+// 数据库的时间精度可能低于time.Time，仅在超出competingWriteTolerance时视为其他实例的写入
+func (m *TimeSynchronizer) checkCompetingWriter(ctx context.Context) {
+	last := lastOwnWrite(m.nodeIdKey)
+	if last.IsZero() {
+		return
+	}
+	tab := m.dao.SnowflakeKv
+	saved, err := tab.WithContext(ctx).Select(tab.Aliased("updated")...).Where(tab.Key.Eq(m.nodeIdKey)).Take()
+	if err != nil {
+		return
+	}
+	if saved.Updated.After(last.Add(competingWriteTolerance)) {
+		m.competingWrites.Inc()
+		m.logger.Errorf("node id record was refreshed by another writer, multiple instances may share the key "+
+			"and generated ids will collide!!! key: %s, updated: %s, last own write: %s", m.nodeIdKey,
+			saved.Updated.Format(time.RFC3339Nano), last.Format(time.RFC3339Nano))
+	}
+}
+
+// CompetingWrites 检测到其他实例以相同的key刷新记录的次数，需开启WithCompetingWriterDetection
+// 可作为监控指标，大于0说明多个副本共享了节点ID Key
+// @return int64
+func (m *TimeSynchronizer) CompetingWrites() int64 {
+	return m.competingWrites.Load()
+}
+
+// Watermark 最近一次成功写入数据库的水位，已叠加时钟偏移（WithClock），尚未成功写入时返回零值
+// 水位落后最近生成的ID最多一个同步间隔
+// @return time.Time
+func (m *TimeSynchronizer) Watermark() time.Time {
+	watermark := m.watermark.Load()
+	if watermark == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(watermark)
+}
+
+// LastSyncAge 距最近一次成功写入数据库的时长，尚未成功写入时从Run开始计算，尚未Run时返回0
+// 可作为监控指标，持续增长说明时间同步器无法写入数据库
+// @return time.Duration
+func (m *TimeSynchronizer) LastSyncAge() time.Duration {
+	last := m.lastSync.Load()
+	if last == 0 {
+		return 0
 	}
+	return time.Since(time.Unix(0, last))
 }
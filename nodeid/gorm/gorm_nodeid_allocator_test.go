@@ -9,17 +9,23 @@ package gorm
 
 import (
 	"context"
-	"math/rand/v2"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
 	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
 	"github.com/glebarez/sqlite"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 	"gorm.io/gorm"
 	//commonsnowflake "github.com/GuoxinL/snowflake-gorm"
 	"github.com/bwmarrin/snowflake"
@@ -31,15 +37,25 @@ const testName = "testname"
 var logger = &DefaultLogger{}
 
 // testDB 创建测试数据库连接
-func testDB(t *testing.T) *gorm.DB {
+func testDB(t testing.TB) *gorm.DB {
 
-	db, err := gorm.Open(sqlite.Open(filepath.Join(os.TempDir(), strconv.Itoa(rand.IntN(32))+"-sqlite.db")))
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sqlite.db")))
 	require.NoError(t, err)
 
 	// 自动迁移表结构
 	err = db.AutoMigrate(&model.SnowflakeKv{})
 	require.NoError(t, err)
 
+	// 每个测试使用独立的数据库，进程内注册表同样不跨测试共享
+	t.Cleanup(func() {
+		activeKeys.Lock()
+		defer activeKeys.Unlock()
+		activeKeys.holders = make(map[string]*NodeIdAllocator)
+
+		ownWrites.Lock()
+		defer ownWrites.Unlock()
+		ownWrites.updated = make(map[string]time.Time)
+	})
 	return db
 }
 
@@ -65,7 +81,7 @@ func TestNodeIdAllocator_Alloc_FirstTime(t *testing.T) {
 	nodeId, err := allocator.Alloc()
 	require.NoError(t, err)
 	assert.GreaterOrEqual(t, nodeId, int64(0))
-	assert.Less(t, nodeId, int64(1024))
+	assert.Less(t, nodeId, int64(nodeid.MaxNodes))
 
 	// 验证记录已创建
 	tab := allocator.dao.SnowflakeKv
@@ -165,10 +181,10 @@ func TestNodeIdAllocator_Alloc_TimeRollback_LargeDrift(t *testing.T) {
 	// 再次分配，应该触发节点ID漂移
 	newNodeId, err := allocator.Alloc()
 	require.NoError(t, err)
-	// 由于使用了哈希分配器，Migration会根据oldNodeId计算新的nodeId
-	// 可能相同也可能不同，取决于哈希结果
+	// 由于使用了哈希分配器，Migration会根据oldNodeId计算新的nodeId，且保证与原节点ID不同
+	assert.NotEqual(t, oldNodeId, newNodeId)
 	assert.GreaterOrEqual(t, newNodeId, int64(0))
-	assert.Less(t, newNodeId, int64(1024))
+	assert.Less(t, newNodeId, int64(nodeid.MaxNodes))
 }
 
 // TestNodeIdAllocator_Alloc_NodeIdContention 测试节点ID抢占
@@ -470,3 +486,1513 @@ func TestNodeIdAllocator_Alloc_MultipleTimes(t *testing.T) {
 		previousNodeId = nodeId
 	}
 }
+
+// TestNodeIdAllocator_Alloc_TimeUnit_RollbackThreshold 测试毫秒与秒两种时间单位下时钟回拨阈值判断一致
+func TestNodeIdAllocator_Alloc_TimeUnit_RollbackThreshold(t *testing.T) {
+	units := []TimeUnit{TimeUnitMillis, TimeUnitSeconds}
+	acceptableClockDrift := time.Second
+
+	for _, unit := range units {
+		t.Run(unit.String(), func(t *testing.T) {
+			ctx := context.Background()
+			db := testDB(t)
+			allocator := NewNodeIdAllocator(ctx, db, testName, testPort, acceptableClockDrift, 5*time.Second, logger,
+				WithTimeUnit(unit))
+			tab := allocator.dao.SnowflakeKv
+
+			// rollback 将保存的时间设置为未来时间后重新分配
+			rollback := func(future time.Duration) int64 {
+				_, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+					UpdateSimple(tab.Time.Value(unit.From(time.Now().Add(future))))
+				require.NoError(t, err)
+
+				nodeId, err := allocator.Alloc()
+				require.NoError(t, err)
+				return nodeId
+			}
+
+			nodeId, err := allocator.Alloc()
+			require.NoError(t, err)
+
+			// 回拨在容忍范围内：保持原节点ID
+			assert.Equal(t, nodeId, rollback(acceptableClockDrift))
+
+			// 回拨超出容忍范围：漂移到新的节点ID
+			expected, err := allocator.NodeIdAllocator.Migration(nodeId)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rollback(time.Hour))
+
+			// 数据库中的记录已移动到新的节点ID，且时间使用配置的单位
+			record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).First()
+			require.NoError(t, err)
+			assert.Equal(t, expected, record.NodeID)
+			assert.InDelta(t, unit.From(time.Now()), record.Time, float64(unit.Duration(time.Second)))
+		})
+	}
+}
+
+// TestNodeIdAllocator_Alloc_RollbackRestart 测试因时钟回拨漂移后两次重启，均不以当前时间移回哈希节点ID
+func TestNodeIdAllocator_Alloc_RollbackRestart(t *testing.T) {
+	for _, unit := range []TimeUnit{TimeUnitMillis, TimeUnitSeconds} {
+		t.Run(unit.String(), func(t *testing.T) {
+			ctx := context.Background()
+			db := testDB(t)
+			restart := func() *NodeIdAllocator {
+				return NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+					WithTimeUnit(unit))
+			}
+			allocator := restart()
+			tab := allocator.dao.SnowflakeKv
+			hashed, err := allocator.Alloc()
+			require.NoError(t, err)
+
+			// 进程退出前哈希节点ID已生成过一小时后的ID
+			watermark := unit.From(time.Now().Add(time.Hour))
+			_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+				UpdateSimple(tab.Time.Value(watermark))
+			require.NoError(t, err)
+
+			// 第一次重启：漂移到其他节点ID，哈希节点ID上保留水位
+			first := restart()
+			migrated, err := first.Alloc()
+			require.NoError(t, err)
+			assert.NotEqual(t, hashed, migrated)
+			assert.Equal(t, AllocOutcomeMigrated, first.LastOutcome())
+
+			// 第二次重启：水位过期前沿用漂移后的节点ID
+			second := restart()
+			nodeId, err := second.Alloc()
+			require.NoError(t, err)
+			assert.Equal(t, migrated, nodeId)
+			assert.Equal(t, AllocOutcomeReused, second.LastOutcome())
+			owner, err := tab.WithContext(ctx).Where(tab.NodeID.Eq(hashed)).Take()
+			require.NoError(t, err)
+			assert.Equal(t, second.watermarkKey(hashed), owner.Key)
+			assert.Equal(t, watermark, owner.Time)
+		})
+	}
+}
+
+// TestNodeIdAllocator_Alloc_TimeUnitSeconds_Truncation 测试秒为单位时保存的时间截断到整秒，
+// 同一秒内不足1秒的回拨在不容忍回拨时也检测不到，毫秒为单位时可以检测到
+func TestNodeIdAllocator_Alloc_TimeUnitSeconds_Truncation(t *testing.T) {
+	// 将时钟对齐到某一秒的100ms处，回拨800ms仍在同一秒内
+	base := time.Now().Truncate(time.Second).Add(time.Second + 100*time.Millisecond)
+	clock := WithClock(NewOffsetClock(time.Until(base)))
+	expected := map[TimeUnit]bool{TimeUnitMillis: true, TimeUnitSeconds: false}
+
+	for unit, detected := range expected {
+		t.Run(unit.String(), func(t *testing.T) {
+			ctx := context.Background()
+			allocator := NewNodeIdAllocator(ctx, testDB(t), testName, testPort, 0, 5*time.Second, logger,
+				WithTimeUnit(unit), clock)
+			nodeId, err := allocator.Alloc()
+			require.NoError(t, err)
+
+			tab := allocator.dao.SnowflakeKv
+			_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+				UpdateSimple(tab.Time.Value(unit.From(base.Add(800 * time.Millisecond))))
+			require.NoError(t, err)
+			reallocated, err := allocator.Alloc()
+			require.NoError(t, err)
+			assert.Equal(t, detected, reallocated != nodeId)
+		})
+	}
+}
+
+// TestTimeSynchronizer_Run_TimeUnit 测试时间同步器按配置的单位写入时间
+func TestTimeSynchronizer_Run_TimeUnit(t *testing.T) {
+	units := []TimeUnit{TimeUnitMillis, TimeUnitSeconds}
+
+	for _, unit := range units {
+		t.Run(unit.String(), func(t *testing.T) {
+			db := testDB(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+				WithTimeUnit(unit))
+			_, err := allocator.Alloc()
+			require.NoError(t, err)
+
+			synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 50*time.Millisecond, logger,
+				WithTimeUnit(unit))
+			synchronizer.Run()
+
+			testTime := time.Now().Add(time.Minute)
+			synchronizer.Async(testTime.UnixMilli())
+			time.Sleep(200 * time.Millisecond)
+
+			tab := synchronizer.dao.SnowflakeKv
+			record, err := tab.WithContext(ctx).Where(tab.Key.Eq(synchronizer.nodeIdKey)).First()
+			require.NoError(t, err)
+			assert.Equal(t, unit.From(testTime), record.Time)
+		})
+	}
+}
+
+// TestNodeIdAllocator_LastOutcome 测试分配结果与数据库中的变化一致
+func TestNodeIdAllocator_LastOutcome(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	contentionInterval := time.Second
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, contentionInterval, logger)
+	tab := allocator.dao.SnowflakeKv
+	assert.Equal(t, AllocOutcomeNone, allocator.LastOutcome())
+
+	// setTime 修改保存的时间
+	setTime := func(at time.Time) {
+		_, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Time.Value(at.UnixMilli()))
+		require.NoError(t, err)
+	}
+	// load 查询当前key的记录
+	load := func() *model.SnowflakeKv {
+		records, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Find()
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		return records[0]
+	}
+
+	// 1. 首次分配：新建记录
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+	created := load()
+	assert.Equal(t, nodeId, created.NodeID)
+	assert.NotNil(t, created.Created)
+
+	// 2. 再次分配：复用记录，时间被更新
+	setTime(time.Now().Add(-contentionInterval / 2))
+	before := load().Time
+	reusedId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+	assert.Equal(t, nodeId, reusedId)
+	assert.Greater(t, load().Time, before)
+
+	// 3. 记录超过抢占时间间隔未更新：抢占
+	setTime(time.Now().Add(-2 * contentionInterval))
+	contentionId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeContention, allocator.LastOutcome())
+	assert.Equal(t, nodeId, contentionId)
+	assert.InDelta(t, time.Now().UnixMilli(), load().Time, float64(time.Second.Milliseconds()))
+
+	// 4. 大幅时钟回拨：漂移，记录移动到新的节点ID
+	setTime(time.Now().Add(time.Hour))
+	migratedId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeMigrated, allocator.LastOutcome())
+	expected, err := allocator.NodeIdAllocator.Migration(nodeId)
+	require.NoError(t, err)
+	assert.Equal(t, expected, migratedId)
+	assert.Equal(t, migratedId, load().NodeID)
+}
+
+// TestNodeIdAllocator_AllocDetailed 测试分配结果的各字段与数据库中的记录一致
+func TestNodeIdAllocator_AllocDetailed(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	contentionInterval := time.Second
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, contentionInterval, logger)
+	tab := allocator.dao.SnowflakeKv
+
+	// setTime 修改保存的时间
+	setTime := func(at time.Time) {
+		_, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Time.Value(at.UnixMilli()))
+		require.NoError(t, err)
+	}
+	// load 查询当前key的记录
+	load := func() *model.SnowflakeKv {
+		record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Take()
+		require.NoError(t, err)
+		return record
+	}
+
+	// 1. 首次分配：新建记录，此前没有节点ID
+	created, err := allocator.AllocDetailed()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, created.Outcome)
+	assert.Equal(t, int64(-1), created.PreviousNodeID)
+	assert.Equal(t, load().NodeID, created.NodeID)
+	assert.Equal(t, load().Time, created.StoredTime.UnixMilli())
+
+	// 2. 复用记录：保存的时间被推进
+	setTime(time.Now().Add(-contentionInterval / 2))
+	reused, err := allocator.AllocDetailed()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeReused, reused.Outcome)
+	assert.Equal(t, created.NodeID, reused.NodeID)
+	assert.Equal(t, created.NodeID, reused.PreviousNodeID)
+	assert.Equal(t, load().Time, reused.StoredTime.UnixMilli())
+
+	// 3. 记录超过抢占时间间隔未更新：抢占
+	setTime(time.Now().Add(-2 * contentionInterval))
+	contention, err := allocator.AllocDetailed()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeContention, contention.Outcome)
+	assert.Equal(t, created.NodeID, contention.NodeID)
+	assert.Equal(t, created.NodeID, contention.PreviousNodeID)
+	assert.Equal(t, load().Time, contention.StoredTime.UnixMilli())
+
+	// 4. 大幅时钟回拨：漂移到新的节点ID，PreviousNodeID为漂移前的节点ID
+	setTime(time.Now().Add(time.Hour))
+	migrated, err := allocator.AllocDetailed()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeMigrated, migrated.Outcome)
+	assert.NotEqual(t, created.NodeID, migrated.NodeID)
+	assert.Equal(t, created.NodeID, migrated.PreviousNodeID)
+	assert.Equal(t, load().NodeID, migrated.NodeID)
+	assert.Equal(t, load().Time, migrated.StoredTime.UnixMilli())
+	assert.Equal(t, allocator.LastOutcome(), migrated.Outcome)
+
+	// Alloc返回与AllocDetailed相同的节点ID
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, migrated.NodeID, nodeId)
+}
+
+// TestNodeIdAllocator_OnNodeIdChange 测试漂移时回调收到新旧节点ID
+func TestNodeIdAllocator_OnNodeIdChange(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, time.Second, logger)
+	tab := allocator.dao.SnowflakeKv
+
+	type change struct{ old, new int64 }
+	var changes []change
+	allocator.OnNodeIdChange(func(old, new int64) {
+		changes = append(changes, change{old: old, new: new})
+	})
+
+	// 首次分配与复用不触发回调
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// 大幅时钟回拨触发漂移
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+		UpdateSimple(tab.Time.Value(time.Now().Add(time.Hour).UnixMilli()))
+	require.NoError(t, err)
+	migratedId, err := allocator.Alloc()
+	require.NoError(t, err)
+	require.Equal(t, AllocOutcomeMigrated, allocator.LastOutcome())
+	assert.Equal(t, []change{{old: nodeId, new: migratedId}}, changes)
+
+	// 新进程启动时key已持有其他节点ID，移回哈希节点ID时同样触发回调
+	// 哈希节点ID上的水位记录过期前沿用漂移后的节点ID，不触发回调
+	restarted := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, time.Second, logger)
+	var restartedChanges []change
+	restarted.OnNodeIdChange(func(old, new int64) {
+		restartedChanges = append(restartedChanges, change{old: old, new: new})
+	})
+	restartedId, err := restarted.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, migratedId, restartedId)
+	assert.Empty(t, restartedChanges)
+
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(restarted.watermarkKey(nodeId))).
+		UpdateSimple(tab.Time.Value(time.Now().Add(-time.Minute).UnixMilli()))
+	require.NoError(t, err)
+	restarted = NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, time.Second, logger)
+	restarted.OnNodeIdChange(func(old, new int64) {
+		restartedChanges = append(restartedChanges, change{old: old, new: new})
+	})
+	restartedId, err = restarted.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, nodeId, restartedId)
+	assert.Equal(t, []change{{old: migratedId, new: nodeId}}, restartedChanges)
+}
+
+// TestNodeIdAllocator_StrictUniqueness 测试哈希得到的节点ID被其他活跃key持有
+func TestNodeIdAllocator_StrictUniqueness(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+
+	// 预置一条持有相同节点ID的活跃记录
+	hashed, err := nodeid.NewHashNodeIdAllocator(GetNodeIdKey(testName, testPort)).Alloc()
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(&model.SnowflakeKv{
+		Key: "other-service", NodeID: hashed, Time: now.UnixMilli(), Created: &now, Updated: now,
+	}))
+
+	// 严格模式：报错
+	strict := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStrictUniqueness(true))
+	_, err = strict.Alloc()
+	assert.True(t, errors.Is(err, ErrNodeIdCollision))
+	count, err := tab.WithContext(ctx).Where(tab.Key.Eq(strict.nodeIdKey)).Count()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// 默认模式：探测下一个节点ID
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	expected, err := allocator.NodeIdAllocator.Migration(hashed)
+	require.NoError(t, err)
+	assert.Equal(t, expected, nodeId)
+
+	// 持有者已不活跃时，严格模式同样探测
+	allocator.Release()
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Delete()
+	require.NoError(t, err)
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq("other-service")).
+		UpdateSimple(tab.Time.Value(now.Add(-time.Minute).UnixMilli()))
+	require.NoError(t, err)
+	nodeId, err = strict.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, expected, nodeId)
+}
+
+// TestNodeIdAllocator_Migration_ShortCycle 测试漂移序列是短循环且循环内的节点ID均被占用时，线性查找空闲节点ID
+func TestNodeIdAllocator_Migration_ShortCycle(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+
+	// 默认位布局下93与922互为漂移目标
+	other, err := allocator.NodeIdAllocator.Migration(93)
+	require.NoError(t, err)
+	require.Equal(t, int64(922), other)
+	back, err := allocator.NodeIdAllocator.Migration(other)
+	require.NoError(t, err)
+	require.Equal(t, int64(93), back)
+
+	now := time.Now()
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(&model.SnowflakeKv{
+		Key: "other-service", NodeID: other, Time: now.UnixMilli(), Created: &now, Updated: now,
+	}))
+	migrated, err := allocator.Migration(93)
+	require.NoError(t, err)
+	assert.Equal(t, int64(94), migrated)
+
+	// 时钟回拨漂移同样线性查找，不回退到重新哈希
+	recorder := &recordLogger{}
+	migrated, err = allocator.rollbackMigration(ctx, allocator.dao, 93, recorder)
+	require.NoError(t, err)
+	assert.Equal(t, int64(94), migrated)
+	assert.False(t, recorder.contains("falling back to rehash"))
+
+	// 线性查找遵循节点ID范围与保留节点ID
+	ranged := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithNodeIdRange(nodeid.NodeIdRange{Min: 0, Max: 1023}), WithReservedNodeIds([]int64{94, 95}))
+	migrated, err = ranged.Migration(93)
+	require.NoError(t, err)
+	assert.Equal(t, int64(96), migrated)
+}
+
+// TestNodeIdAllocator_Migration_Exhausted 测试所有节点ID均被占用时漂移返回耗尽错误
+func TestNodeIdAllocator_Migration_Exhausted(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+
+	now := time.Now()
+	records := make([]*model.SnowflakeKv, 0, nodeid.MaxNodes)
+	for nodeId := int64(0); nodeId < nodeid.MaxNodes; nodeId++ {
+		records = append(records, &model.SnowflakeKv{
+			Key: fmt.Sprintf("other-%d", nodeId), NodeID: nodeId, Time: now.UnixMilli(), Created: &now, Updated: now,
+		})
+	}
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).CreateInBatches(records, 100))
+
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	hashed, err := allocator.NodeIdAllocator.Alloc()
+	require.NoError(t, err)
+	_, err = allocator.Migration(hashed)
+	assert.True(t, errors.Is(err, nodeid.ErrMigrationExhausted))
+	_, err = allocator.Alloc()
+	assert.True(t, errors.Is(err, nodeid.ErrMigrationExhausted))
+
+	// 释放漂移序列中的一个节点ID后可以漂移过去
+	next, err := allocator.NodeIdAllocator.Migration(hashed)
+	require.NoError(t, err)
+	free, err := allocator.NodeIdAllocator.Migration(next)
+	require.NoError(t, err)
+	_, err = tab.WithContext(ctx).Where(tab.NodeID.Eq(free)).Delete()
+	require.NoError(t, err)
+	migrated, err := allocator.Migration(hashed)
+	require.NoError(t, err)
+	assert.Equal(t, free, migrated)
+}
+
+// TestNodeIdAllocator_RollbackPollInterval 测试轮询模式在时钟追上后立即返回
+func TestNodeIdAllocator_RollbackPollInterval(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	drift := 3 * time.Second
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, drift, 5*time.Second, logger,
+		WithRollbackPollInterval(5*time.Millisecond))
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	tab := allocator.dao.SnowflakeKv
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+		UpdateSimple(tab.Time.Value(time.Now().Add(50 * time.Millisecond).UnixMilli()))
+	require.NoError(t, err)
+
+	start := time.Now()
+	reusedId, err := allocator.Alloc()
+	require.NoError(t, err)
+	elapsed := time.Since(start)
+	assert.Equal(t, nodeId, reusedId)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+	assert.GreaterOrEqual(t, elapsed, 40*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
+
+// TestNodeIdAllocator_MaxRollbackWait 测试容忍时间很大时，小幅回拨的等待时间受maxRollbackWait限制
+func TestNodeIdAllocator_MaxRollbackWait(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Hour, 5*time.Second, logger,
+		WithMaxRollbackWait(200*time.Millisecond))
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	// 保存的时间在等待上限之内，时钟追上后复用原节点ID
+	tab := allocator.dao.SnowflakeKv
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+		UpdateSimple(tab.Time.Value(time.Now().Add(50 * time.Millisecond).UnixMilli()))
+	require.NoError(t, err)
+
+	start := time.Now()
+	reusedId, err := allocator.Alloc()
+	require.NoError(t, err)
+	elapsed := time.Since(start)
+	assert.Equal(t, nodeId, reusedId)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+	assert.GreaterOrEqual(t, elapsed, 40*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+
+	// 保存的时间超出等待上限，等待上限后漂移到新的节点ID
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+		UpdateSimple(tab.Time.Value(time.Now().Add(10 * time.Second).UnixMilli()))
+	require.NoError(t, err)
+
+	start = time.Now()
+	migratedId, err := allocator.Alloc()
+	require.NoError(t, err)
+	elapsed = time.Since(start)
+	assert.NotEqual(t, nodeId, migratedId)
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+}
+
+// TestNodeIdAllocator_FreeNodeIds 测试空闲节点ID包含空槽位与不活跃记录持有的节点ID
+func TestNodeIdAllocator_FreeNodeIds(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+	contentionInterval := 5 * time.Second
+
+	now := time.Now()
+	seed := func(key string, nodeId int64, at time.Time) {
+		require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(&model.SnowflakeKv{
+			Key: key, NodeID: nodeId, Time: at.UnixMilli(), Created: &now, Updated: now,
+		}))
+	}
+	seed("active-1", 1, now)
+	seed("active-2", 2, now.Add(-contentionInterval/2))
+	seed("stale-3", 3, now.Add(-2*contentionInterval))
+	seed("active-1000", 1000, now)
+
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, contentionInterval, logger)
+	free, err := allocator.FreeNodeIds(ctx)
+	require.NoError(t, err)
+	assert.Len(t, free, nodeid.MaxNodes-3)
+	assert.Equal(t, []int64{0, 3, 4}, free[:3])
+	assert.NotContains(t, free, int64(1000))
+	assert.Equal(t, int64(nodeid.MaxNodes-1), free[len(free)-1])
+}
+
+// TestTimeSynchronizer_Stop 测试停止时写入最后的时间并退出goroutine
+func TestTimeSynchronizer_Stop(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, time.Hour, logger)
+	synchronizer.Run()
+	last := time.Now().Add(time.Minute).UnixMilli()
+	synchronizer.Async(last)
+	synchronizer.Stop()
+
+	select {
+	case <-synchronizer.done:
+	default:
+		t.Fatal("time synchronizer is not stopped")
+	}
+	tab := synchronizer.dao.SnowflakeKv
+	record, err := tab.WithContext(ctx).Where(tab.Key.Eq(synchronizer.nodeIdKey)).First()
+	require.NoError(t, err)
+	assert.Equal(t, last, record.Time)
+
+	// 重复停止与未启动时停止
+	synchronizer.Stop()
+	NewTimeSynchronizer(ctx, db, testName, testPort, time.Hour, logger).Stop()
+}
+
+// TestNodeIdAllocator_Close 测试关闭后删除记录
+func TestNodeIdAllocator_Close(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	// 未分配时关闭
+	require.NoError(t, allocator.Close())
+
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+	require.NoError(t, allocator.Close())
+	tab := allocator.dao.SnowflakeKv
+	count, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Count()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// 关闭后以相同身份的严格模式分配器不再报错
+	strict := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStrictUniqueness(true))
+	_, err = strict.Alloc()
+	assert.NoError(t, err)
+}
+
+// TestNodeIdAllocator_Explain 测试诊断信息包含节点ID与分配结果
+func TestNodeIdAllocator_Explain(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	assert.Contains(t, allocator.Explain(), "node id: not allocated")
+
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	explain := allocator.Explain()
+	assert.Contains(t, explain, "key: "+allocator.nodeIdKey)
+	assert.Contains(t, explain, fmt.Sprintf("hash: %d", nodeid.KeyHash(allocator.nodeIdKey)))
+	assert.Contains(t, explain, fmt.Sprintf("slot: %d", nodeId))
+	assert.Contains(t, explain, fmt.Sprintf("node id: %d", nodeId))
+	assert.Contains(t, explain, "outcome: created")
+	assert.Contains(t, explain, fmt.Sprintf("stored: node id %d", nodeId))
+
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.Contains(t, allocator.Explain(), "outcome: reused")
+}
+
+// TestNodeIdAllocator_ContextCancelled 测试context已结束时分配返回明确的错误
+func TestNodeIdAllocator_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	allocator := NewNodeIdAllocator(ctx, testDB(t), testName, testPort, time.Second, 5*time.Second, logger)
+	_, err := allocator.Alloc()
+	assert.True(t, errors.Is(err, ErrContextCancelled))
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+}
+
+// TestNodeIdAllocator_AllocWithLogger 测试单次分配的日志输出到指定的日志记录器
+func TestNodeIdAllocator_AllocWithLogger(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	static := &recordLogger{}
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, 5*time.Second, static)
+
+	// 时钟回拨的日志同样输出到指定的日志记录器
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+	tab := allocator.dao.SnowflakeKv
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+		UpdateSimple(tab.Time.Value(time.Now().Add(time.Hour).UnixMilli()))
+	require.NoError(t, err)
+	static.logs = nil
+
+	request := &recordLogger{}
+	nodeId, err := allocator.AllocWithLogger(request)
+	require.NoError(t, err)
+	assert.True(t, request.contains("time is rollback"))
+	assert.True(t, request.contains(fmt.Sprintf("node id allocated. key: %s, node id: %d, outcome: migrated",
+		allocator.nodeIdKey, nodeId)))
+	assert.Empty(t, static.logs)
+
+	// 默认使用创建时的日志记录器
+	_, err = allocator.AllocWithLogger(nil)
+	require.NoError(t, err)
+	assert.True(t, static.contains("node id allocated"))
+}
+
+// TestNodeIdAllocator_ZeroDrift 测试容忍时间为0时任何时钟回拨都立即漂移
+func TestNodeIdAllocator_ZeroDrift(t *testing.T) {
+	cases := []struct {
+		name  string
+		drift time.Duration
+		unit  TimeUnit
+		ahead time.Duration
+	}{
+		{"zero", 0, TimeUnitMillis, 5 * time.Millisecond},
+		{"negative", -time.Second, TimeUnitMillis, 5 * time.Millisecond},
+		{"below unit", 500 * time.Millisecond, TimeUnitSeconds, 2 * time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := testDB(t)
+			ctx := context.Background()
+			recorder := &recordLogger{}
+			allocator := NewNodeIdAllocator(ctx, db, testName, testPort, c.drift, 5*time.Second, recorder,
+				WithTimeUnit(c.unit))
+			assert.True(t, recorder.contains("any clock rollback migrates the node id"))
+			nodeId, err := allocator.Alloc()
+			require.NoError(t, err)
+
+			tab := allocator.dao.SnowflakeKv
+			_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+				UpdateSimple(tab.Time.Value(c.unit.From(time.Now().Add(c.ahead))))
+			require.NoError(t, err)
+
+			start := time.Now()
+			migratedId, err := allocator.Alloc()
+			require.NoError(t, err)
+			assert.Less(t, time.Since(start), 100*time.Millisecond)
+			assert.Equal(t, AllocOutcomeMigrated, allocator.LastOutcome())
+			assert.NotEqual(t, nodeId, migratedId)
+		})
+	}
+}
+
+// TestNodeIdAllocator_History 测试分配历史记录首次分配与每次漂移
+func TestNodeIdAllocator_History(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 0, 5*time.Second, logger)
+	assert.Empty(t, allocator.History())
+
+	first, err := allocator.Alloc()
+	require.NoError(t, err)
+	// 复用同一个节点ID不产生记录
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+
+	tab := allocator.dao.SnowflakeKv
+	expected := []int64{first}
+	for i := 0; i < 2; i++ {
+		_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Time.Value(time.Now().Add(time.Minute).UnixMilli()))
+		require.NoError(t, err)
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		expected = append(expected, nodeId)
+	}
+
+	history := allocator.History()
+	require.Len(t, history, 3)
+	assert.Equal(t, int64(-1), history[0].Old)
+	assert.Equal(t, AllocOutcomeCreated, history[0].Reason)
+	for i, event := range history {
+		assert.Equal(t, expected[i], event.New)
+		if i > 0 {
+			assert.Equal(t, expected[i-1], event.Old)
+			assert.Equal(t, AllocOutcomeMigrated, event.Reason)
+			assert.False(t, event.Time.Before(history[i-1].Time))
+		}
+	}
+}
+
+// TestWithStickyNodeId 测试重启后粘性模式沿用key记录中探测得到的节点ID，默认模式移回哈希节点ID
+func TestWithStickyNodeId(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	first := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger, WithStickyNodeId(true))
+	hashed, err := first.NodeIdAllocator.Alloc()
+	require.NoError(t, err)
+
+	// 哈希节点ID被其他实例持有，探测到下一个节点ID
+	now := time.Now()
+	blocker := &model.SnowflakeKv{Key: "blocker", NodeID: hashed, Time: now.UnixMilli(), Created: &now, Updated: now}
+	require.NoError(t, db.Create(blocker).Error)
+	probed, err := first.Alloc()
+	require.NoError(t, err)
+	require.NotEqual(t, hashed, probed)
+	first.Release()
+
+	// 其他实例退出后重启，粘性模式沿用探测得到的节点ID
+	require.NoError(t, db.Delete(blocker).Error)
+	restarted := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStickyNodeId(true))
+	result, err := restarted.AllocDetailed()
+	require.NoError(t, err)
+	assert.Equal(t, probed, result.NodeID)
+	assert.Equal(t, AllocOutcomeReused, result.Outcome)
+	restarted.Release()
+
+	// 节点ID已不在范围内时重新分配
+	other := (probed + 1) % nodeid.NodeIDSpace()
+	ranged := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStickyNodeId(true), WithNodeIdRange(nodeid.NodeIdRange{Min: other, Max: other}))
+	nodeId, err := ranged.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, other, nodeId)
+	ranged.Release()
+
+	// 默认模式从哈希节点ID开始
+	churned := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	nodeId, err = churned.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, hashed, nodeId)
+}
+
+// TestNodeIdAllocator_NodeHistory 测试多次漂移后数据库中只保留当前持有的节点ID，完整的变化见History
+func TestNodeIdAllocator_NodeHistory(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 0, 5*time.Second, logger)
+	nodeIds, err := allocator.NodeHistory(ctx, allocator.nodeIdKey)
+	require.NoError(t, err)
+	assert.Empty(t, nodeIds)
+
+	first, err := allocator.Alloc()
+	require.NoError(t, err)
+	held := []int64{first}
+	tab := allocator.dao.SnowflakeKv
+	for i := 0; i < 2; i++ {
+		_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Time.Value(time.Now().Add(time.Minute).UnixMilli()))
+		require.NoError(t, err)
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		held = append(held, nodeId)
+	}
+
+	nodeIds, err = allocator.NodeHistory(ctx, allocator.nodeIdKey)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{held[len(held)-1]}, nodeIds)
+	history := allocator.History()
+	require.Len(t, history, len(held))
+	for i, event := range history {
+		assert.Equal(t, held[i], event.New)
+	}
+}
+
+// TestAllocHistory_Wrap 测试分配历史超出容量后覆盖最早的记录
+func TestAllocHistory_Wrap(t *testing.T) {
+	var h allocHistory
+	for i := 0; i < historyCapacity+3; i++ {
+		h.add(AllocEvent{New: int64(i)})
+	}
+	events := h.list()
+	require.Len(t, events, historyCapacity)
+	assert.Equal(t, int64(3), events[0].New)
+	assert.Equal(t, int64(historyCapacity+2), events[historyCapacity-1].New)
+}
+
+// TestNodeIdAllocator_Dao 测试通过暴露的dao按节点ID范围查询
+func TestNodeIdAllocator_Dao(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	var nodeIds []int64
+	for port := 9000; port < 9010; port++ {
+		allocator := NewNodeIdAllocator(ctx, db, testName, port, time.Second, 5*time.Second, logger)
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		nodeIds = append(nodeIds, nodeId)
+	}
+
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	tab := allocator.Dao().SnowflakeKv
+	records, err := tab.WithContext(ctx).Where(tab.NodeID.Between(0, 511)).Order(tab.NodeID).Find()
+	require.NoError(t, err)
+
+	var expected []int64
+	for _, nodeId := range nodeIds {
+		if nodeId <= 511 {
+			expected = append(expected, nodeId)
+		}
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	actual := make([]int64, 0, len(records))
+	for _, record := range records {
+		actual = append(actual, record.NodeID)
+	}
+	assert.Equal(t, expected, actual)
+}
+
+// TestNodeIdAllocator_PersistAddress 测试IP与部署类型写入独立的列
+func TestNodeIdAllocator_PersistAddress(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+
+	// 默认不写入
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+	record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).First()
+	require.NoError(t, err)
+	assert.Empty(t, record.IP)
+	assert.Empty(t, record.DeployType)
+	_, _, changed, err := allocator.IPChanged()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// 开启后更新已有记录时写入
+	allocator = NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithPersistAddress(true))
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	record, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).First()
+	require.NoError(t, err)
+	assert.Equal(t, GetIP(), record.IP)
+	assert.Equal(t, string(GetDeployType()), record.DeployType)
+
+	stored, current, changed, err := allocator.IPChanged()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, stored, current)
+
+	// 记录中的IP与当前IP不一致时可被检测到
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).UpdateSimple(tab.IP.Value("203.0.113.9"))
+	require.NoError(t, err)
+	stored, current, changed, err = allocator.IPChanged()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "203.0.113.9", stored)
+	assert.Equal(t, GetIP(), current)
+}
+
+// TestNodeIdAllocator_OnIPChange 测试Refresh时检测到解析出的IP变化并调用回调
+func TestNodeIdAllocator_OnIPChange(t *testing.T) {
+	db := testDB(t)
+	path := filepath.Join(t.TempDir(), "pod_ip")
+	require.NoError(t, os.WriteFile(path, []byte("198.51.100.1"), 0o600))
+	type change struct{ stored, current string }
+	var changes []change
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithPersistAddress(true), WithPodIPFile(path, 0), WithOnIPChange(func(stored, current string) {
+			changes = append(changes, change{stored: stored, current: current})
+		}))
+	_, err := allocator.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Pod IP变化后Refresh调用回调，节点ID Key不随之变化
+	key := allocator.NodeIdKey()
+	require.NoError(t, os.WriteFile(path, []byte("198.51.100.2"), 0o600))
+	_, err = allocator.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []change{{stored: "198.51.100.1", current: "198.51.100.2"}}, changes)
+	assert.Equal(t, key, allocator.NodeIdKey())
+}
+
+// TestNodeIdAllocator_PersistAddress_Create 测试新建记录时写入IP与部署类型
+func TestNodeIdAllocator_PersistAddress_Create(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithPersistAddress(true))
+
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+
+	tab := allocator.dao.SnowflakeKv
+	record, err := tab.WithContext(ctx).Where(tab.NodeID.Eq(nodeId)).First()
+	require.NoError(t, err)
+	assert.Equal(t, GetIP(), record.IP)
+	assert.Equal(t, string(GetDeployType()), record.DeployType)
+}
+
+// TestNodeIdAllocator_LegacyTable 测试未开启WithPersistAddress时兼容没有ip、deploy_type列的旧表
+func TestNodeIdAllocator_LegacyTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sqlite.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE `snowflake_kv` (`key` text,`node_id` integer NOT NULL UNIQUE,"+
+		"`time` integer NOT NULL,`created` datetime NOT NULL,`updated` datetime NOT NULL,PRIMARY KEY (`key`))").Error)
+
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+
+	secondNodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, nodeId, secondNodeId)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+}
+
+// TestNodeIdAllocator_ColumnNames 测试在列名不同的已有表上分配节点ID与同步时间
+func TestNodeIdAllocator_ColumnNames(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sqlite.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE `snowflake_kv` (`k` text,`nid` integer NOT NULL UNIQUE,"+
+		"`ts` integer NOT NULL,`created` datetime NOT NULL,`mtime` datetime NOT NULL,PRIMARY KEY (`k`))").Error)
+	t.Cleanup(func() { activeKeys.holders = nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	columns := WithColumnNames(ColumnNames{Key: "k", NodeID: "nid", Time: "ts", Updated: "mtime"})
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger, columns)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+
+	secondNodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, nodeId, secondNodeId)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+	assert.Contains(t, allocator.Explain(), fmt.Sprintf("stored: node id %d", nodeId))
+
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 10*time.Millisecond, logger, columns)
+	watermark := time.Now().Add(time.Hour).UnixMilli()
+	synchronizer.Async(watermark)
+	synchronizer.Run()
+	synchronizer.Stop()
+
+	var stored struct {
+		Nid int64
+		Ts  int64
+	}
+	require.NoError(t, db.Table("snowflake_kv").Select("nid", "ts").Where("k = ?", allocator.nodeIdKey).
+		Take(&stored).Error)
+	assert.Equal(t, nodeId, stored.Nid)
+	assert.Equal(t, watermark, stored.Ts)
+}
+
+// TestTimeSynchronizer_LastSyncAge 测试距最近一次成功写入的时长，写入失败后持续增长
+func TestTimeSynchronizer_LastSyncAge(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 20*time.Millisecond, logger)
+	assert.Zero(t, synchronizer.LastSyncAge())
+	synchronizer.Async(time.Now().UnixMilli())
+	synchronizer.Run()
+	defer synchronizer.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Less(t, synchronizer.LastSyncAge(), 80*time.Millisecond)
+
+	// 删除表后写入失败，时长不再被重置
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	time.Sleep(50 * time.Millisecond)
+	failing := synchronizer.LastSyncAge()
+	time.Sleep(100 * time.Millisecond)
+	assert.GreaterOrEqual(t, synchronizer.LastSyncAge()-failing, 100*time.Millisecond)
+}
+
+// TestTimeSynchronizer_Watermark 测试水位为最近一次写入的时间并叠加时钟偏移
+func TestTimeSynchronizer_Watermark(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	offset := -time.Hour
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 20*time.Millisecond, logger,
+		WithClock(NewOffsetClock(offset)))
+	assert.True(t, synchronizer.Watermark().IsZero())
+	generated := time.Now()
+	synchronizer.Async(generated.UnixMilli())
+	synchronizer.Run()
+	defer synchronizer.Stop()
+
+	require.Eventually(t, func() bool { return !synchronizer.Watermark().IsZero() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, generated.Add(offset).UnixMilli(), synchronizer.Watermark().UnixMilli())
+}
+
+// TestTimeSynchronizer_FlushNow 测试Alloc后立即写入，无需等待tick即可读到最新的时间
+func TestTimeSynchronizer_FlushNow(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, time.Hour, logger)
+	synchronizer.Run()
+	defer synchronizer.Stop()
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	// 尚未生成过ID时不写入
+	require.NoError(t, synchronizer.FlushNow(ctx))
+	latest := time.Now().Add(time.Second).UnixMilli()
+	synchronizer.Async(latest)
+	require.NoError(t, synchronizer.FlushNow(ctx))
+
+	var saved model.SnowflakeKv
+	require.NoError(t, db.Where("key = ?", allocator.nodeIdKey).Take(&saved).Error)
+	assert.Equal(t, latest, saved.Time)
+	assert.Equal(t, latest, synchronizer.Watermark().UnixMilli())
+
+	// 写入失败时返回错误
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	synchronizer.Async(latest + 100)
+	assert.Error(t, synchronizer.FlushNow(ctx))
+	cancelled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	assert.True(t, errors.Is(synchronizer.FlushNow(cancelled), ErrContextCancelled))
+}
+
+// TestNodeIdAllocator_SchemaMissing 测试未迁移表结构时Alloc返回ErrSchemaMissing
+func TestNodeIdAllocator_SchemaMissing(t *testing.T) {
+	db := testDB(t)
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger)
+
+	_, err := allocator.Alloc()
+	assert.True(t, errors.Is(err, ErrSchemaMissing))
+	assert.Contains(t, err.Error(), "no such table")
+
+	// 开启准入令牌但未创建snowflake_admission表
+	require.NoError(t, db.AutoMigrate(&model.SnowflakeKv{}))
+	allocator = NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithAdmissionLimit(1, 0))
+	_, err = allocator.Alloc()
+	assert.True(t, errors.Is(err, ErrSchemaMissing))
+
+	// 其他错误不包装
+	assert.False(t, errors.Is(wrapSchemaError(errors.New("connection refused")), ErrSchemaMissing))
+	assert.True(t, errors.Is(wrapSchemaError(errors.New(`ERROR: relation "snowflake_kv" does not exist (SQLSTATE 42P01)`)),
+		ErrSchemaMissing))
+	assert.True(t, errors.Is(wrapSchemaError(errors.New("Error 1146 (42S02): Table 'app.snowflake_kv' doesn't exist")),
+		ErrSchemaMissing))
+}
+
+// TestNodeIdAllocator_AllocTx 测试在调用方的事务中分配，事务回滚后不保留记录
+func TestNodeIdAllocator_AllocTx(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+
+	errRollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		nodeId, err := allocator.AllocTx(tx)
+		require.NoError(t, err)
+		assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+
+		var count int64
+		require.NoError(t, tx.Model(&model.SnowflakeKv{}).Where("node_id = ?", nodeId).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	var count int64
+	require.NoError(t, db.Model(&model.SnowflakeKv{}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	// 回滚后重新分配会重新创建记录
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+}
+
+// TestNodeIdAllocator_StaleIdentityThreshold 测试自身记录过旧时重新分配而不是直接刷新
+func TestNodeIdAllocator_StaleIdentityThreshold(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStaleIdentityThreshold(24*time.Hour))
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	// 未超过阈值时直接刷新
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+
+	// 模拟一个月前退出的前一个实例留下的记录
+	tab := allocator.dao.SnowflakeKv
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+		UpdateSimple(tab.Time.Value(old.UnixMilli()), tab.Created.Value(old))
+	require.NoError(t, err)
+
+	allocator.Release()
+	restarted := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStaleIdentityThreshold(24*time.Hour))
+	reallocated, err := restarted.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, nodeId, reallocated)
+	assert.Equal(t, AllocOutcomeCreated, restarted.LastOutcome())
+	saved, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Take()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), *saved.Created, time.Minute)
+}
+
+// TestNodeIdAllocator_ConcurrentCreate 测试相同key并发首次分配时只创建一条记录，且返回相同的节点ID
+func TestNodeIdAllocator_ConcurrentCreate(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	const goroutines = 16
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		nodeIds = make([]int64, goroutines)
+		errs    = make([]error, goroutines)
+	)
+	for i := 0; i < goroutines; i++ {
+		allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			nodeIds[i], errs[i] = allocator.Alloc()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < goroutines; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, nodeIds[0], nodeIds[i])
+	}
+	var count int64
+	require.NoError(t, db.Model(&model.SnowflakeKv{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+// TestNodeIdAllocator_CreateRace 测试查询与创建之间其他实例写入相同记录时，重新查询并复用该记录
+func TestNodeIdAllocator_CreateRace(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	hashed, err := allocator.NodeIdAllocator.Alloc()
+	require.NoError(t, err)
+
+	// 在第一次创建前模拟另一个实例抢先写入相同的key与节点ID
+	raced := false
+	require.NoError(t, db.Callback().Create().Before("gorm:begin_transaction").Register("test:race",
+		func(tx *gorm.DB) {
+			if raced {
+				return
+			}
+			raced = true
+			now := time.Now()
+			require.NoError(t, db.Session(&gorm.Session{NewDB: true}).Create(&model.SnowflakeKv{
+				Key: allocator.nodeIdKey, NodeID: hashed, Time: now.UnixMilli(), Created: &now, Updated: now,
+			}).Error)
+		}))
+
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, hashed, nodeId)
+	assert.Equal(t, AllocOutcomeReused, allocator.LastOutcome())
+}
+
+// countStatements 统计db执行的SQL语句数
+func countStatements(tb testing.TB, db *gorm.DB) *atomic.Int64 {
+	var count atomic.Int64
+	inc := func(*gorm.DB) { count.Inc() }
+	callbacks := db.Callback()
+	require.NoError(tb, callbacks.Query().After("gorm:query").Register("test:count_query", inc))
+	require.NoError(tb, callbacks.Create().After("gorm:create").Register("test:count_create", inc))
+	require.NoError(tb, callbacks.Update().After("gorm:update").Register("test:count_update", inc))
+	require.NoError(tb, callbacks.Delete().After("gorm:delete").Register("test:count_delete", inc))
+	return &count
+}
+
+// BenchmarkAlloc_Concurrent 测试大量实例同时重启时首次分配的延迟与SQL语句数
+func BenchmarkAlloc_Concurrent(b *testing.B) {
+	const registrants = 64
+	ctx := context.Background()
+
+	latencies := make([]time.Duration, 0, b.N*registrants)
+	var statements int64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db := testDB(b)
+		count := countStatements(b, db)
+		allocators := make([]*NodeIdAllocator, registrants)
+		for r := range allocators {
+			allocators[r] = NewNodeIdAllocator(ctx, db, fmt.Sprintf("bench-%d", r), testPort, time.Second,
+				5*time.Second, logger)
+		}
+		nodeIds := make([]int64, registrants)
+		elapsed := make([]time.Duration, registrants)
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for r, allocator := range allocators {
+			wg.Add(1)
+			go func(r int, allocator *NodeIdAllocator) {
+				defer wg.Done()
+				<-start
+				begin := time.Now()
+				nodeId, err := allocator.Alloc()
+				elapsed[r] = time.Since(begin)
+				if err != nil {
+					b.Error(err)
+				}
+				nodeIds[r] = nodeId
+			}(r, allocator)
+		}
+		b.StartTimer()
+		close(start)
+		wg.Wait()
+		b.StopTimer()
+
+		// 所有实例的节点ID互不相同
+		distinct := make(map[int64]struct{}, registrants)
+		for _, nodeId := range nodeIds {
+			distinct[nodeId] = struct{}{}
+		}
+		require.Len(b, distinct, registrants)
+		latencies = append(latencies, elapsed...)
+		statements += count.Load()
+		b.StartTimer()
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)/2].Nanoseconds()), "p50-ns/alloc")
+	b.ReportMetric(float64(latencies[(len(latencies)-1)*99/100].Nanoseconds()), "p99-ns/alloc")
+	b.ReportMetric(float64(statements)/float64(b.N*registrants), "stmts/alloc")
+}
+
+// TestNodeIdAllocator_StartupLog 测试首次分配成功后输出一次包含部署类型、IP、key与节点ID的启动日志
+func TestNodeIdAllocator_StartupLog(t *testing.T) {
+	db := testDB(t)
+	recorder := &recordLogger{}
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, recorder)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+
+	expected := fmt.Sprintf("snowflake instance started. deploy type: %s, ip: %s, key: %s, node id: %d",
+		GetDeployType(), GetIP(), allocator.nodeIdKey, nodeId)
+	var lines int
+	for _, log := range recorder.logs {
+		if strings.Contains(log, "snowflake instance started") {
+			lines++
+			assert.Equal(t, expected, log)
+		}
+	}
+	assert.Equal(t, 1, lines)
+}
+
+// TestTimeSynchronizer_CompetingWriter 测试其他实例以相同的key刷新记录时被检测到
+func TestTimeSynchronizer_CompetingWriter(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+	defer allocator.Release()
+
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 20*time.Millisecond, logger,
+		WithCompetingWriterDetection(true))
+	synchronizer.Async(time.Now().UnixMilli())
+	synchronizer.Run()
+	defer synchronizer.Stop()
+
+	// 只有本进程写入时不告警
+	time.Sleep(100 * time.Millisecond)
+	assert.Zero(t, synchronizer.CompetingWrites())
+
+	// 其他实例持续刷新记录
+	tab := allocator.dao.SnowflakeKv
+	assert.Eventually(t, func() bool {
+		_, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Updated.Value(time.Now().Add(time.Hour)))
+		require.NoError(t, err)
+		return synchronizer.CompetingWrites() > 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestNodeIdAllocator_Alloc_AdvancesTime 测试不运行时间同步器时，重复调用Alloc推进记录中保存的时间
+func TestNodeIdAllocator_Alloc_AdvancesTime(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	tab := allocator.dao.SnowflakeKv
+	saved := func() int64 {
+		record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Take()
+		require.NoError(t, err)
+		return record.Time
+	}
+	last := saved()
+	for i := 0; i < 3; i++ {
+		time.Sleep(20 * time.Millisecond)
+		again, err := allocator.Alloc()
+		require.NoError(t, err)
+		assert.Equal(t, nodeId, again)
+
+		current := saved()
+		assert.Greater(t, current, last)
+		last = current
+	}
+}
+
+// TestNodeIdAllocator_RollbackMigration 测试时钟回拨漂移时优先选择空闲的节点ID，其次是失效记录持有的节点ID
+func TestNodeIdAllocator_RollbackMigration(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, 5*time.Second, logger)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	// 漂移序列
+	sequence := []int64{nodeId}
+	for len(sequence) < 6 {
+		next, err := allocator.NodeIdAllocator.Migration(sequence[len(sequence)-1])
+		require.NoError(t, err)
+		sequence = append(sequence, next)
+	}
+	now := time.Now()
+	seed := func(nodeId int64, at time.Time) {
+		require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(&model.SnowflakeKv{
+			Key: fmt.Sprintf("other-%d", nodeId), NodeID: nodeId, Time: at.UnixMilli(), Created: &now, Updated: now,
+		}))
+	}
+	rollback := func() {
+		_, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Time.Value(time.Now().Add(time.Hour).UnixMilli()))
+		require.NoError(t, err)
+	}
+
+	// 序列中前两个节点ID被活跃的key持有，漂移到第一个空闲的节点ID
+	seed(sequence[1], now)
+	seed(sequence[2], now)
+	rollback()
+	migrated, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, sequence[3], migrated)
+
+	// 其余节点ID均被活跃的key持有，只有一个失效记录时漂移到失效记录持有的节点ID
+	var records []*model.SnowflakeKv
+	for id := int64(0); id < nodeid.MaxNodes; id++ {
+		// 最初的节点ID上遗留了当前key的水位记录
+		if id == migrated || id == sequence[0] || id == sequence[1] || id == sequence[2] {
+			continue
+		}
+		at := now
+		if id == sequence[5] {
+			at = now.Add(-time.Minute)
+		}
+		records = append(records, &model.SnowflakeKv{
+			Key: fmt.Sprintf("other-%d", id), NodeID: id, Time: at.UnixMilli(), Created: &now, Updated: now,
+		})
+	}
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).CreateInBatches(records, 100))
+	rollback()
+	migrated, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, sequence[5], migrated)
+	assert.Equal(t, AllocOutcomeMigrated, allocator.LastOutcome())
+	owner, err := tab.WithContext(ctx).Where(tab.NodeID.Eq(sequence[5])).Take()
+	require.NoError(t, err)
+	assert.Equal(t, allocator.nodeIdKey, owner.Key)
+}
+
+// TestNodeIdAllocator_MigrationReleasesPrevious 测试多次漂移后当前key只有一条持有当前节点ID的记录，之前的节点ID只遗留水位记录
+func TestNodeIdAllocator_MigrationReleasesPrevious(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	tab := dao.Use(db).SnowflakeKv
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, 100*time.Millisecond, 5*time.Second, logger)
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	held := []int64{nodeId}
+	for i := 0; i < 5; i++ {
+		_, err = tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).
+			UpdateSimple(tab.Time.Value(time.Now().Add(time.Hour).UnixMilli()))
+		require.NoError(t, err)
+		nodeId, err = allocator.Alloc()
+		require.NoError(t, err)
+		assert.Equal(t, AllocOutcomeMigrated, allocator.LastOutcome())
+		assert.NotContains(t, held, nodeId)
+		held = append(held, nodeId)
+
+		rows, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Find()
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, nodeId, rows[0].NodeID)
+		// 之前的节点ID只保留因时钟回拨漂移遗留的水位记录
+		previous, err := tab.WithContext(ctx).Where(tab.NodeID.In(held[:len(held)-1]...)).Find()
+		require.NoError(t, err)
+		require.Len(t, previous, len(held)-1)
+		for _, row := range previous {
+			assert.Equal(t, allocator.watermarkKey(row.NodeID), row.Key)
+		}
+	}
+}
+
+// TestNodeIdAllocator_SynchronizerRace 测试分配器与时间同步器并发写入同一条记录时，数据库中的时间不回退
+func TestNodeIdAllocator_SynchronizerRace(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithRollbackPollInterval(time.Millisecond))
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, time.Millisecond, logger)
+
+	// 时间同步器持有的时间早于分配器刚写入的时间时不覆盖
+	tab := allocator.dao.SnowflakeKv
+	record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Take()
+	require.NoError(t, err)
+	synchronizer.Async(record.Time - 100)
+	synchronizer.updateDB()
+	current, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Take()
+	require.NoError(t, err)
+	assert.Equal(t, record.Time, current.Time)
+
+	synchronizer.Run()
+	defer synchronizer.Stop()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				synchronizer.Async(time.Now().UnixMilli())
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				_, err := allocator.Alloc()
+				assert.NoError(t, err)
+			}
+		}
+	}()
+
+	var last int64
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		record, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocator.nodeIdKey)).Take()
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, record.Time, last)
+		last = record.Time
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,77 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 分配历史
+package gorm
+
+import (
+	"context"
+	"time"
+)
+
+// historyCapacity 分配历史最多保留的条数，超出后覆盖最早的记录
+const historyCapacity = 64
+
+// AllocEvent 一次节点ID变化的记录
+type AllocEvent struct {
+	// Time 分配完成的时间
+	Time time.Time
+	// Old 变化前的节点ID，首次分配时为key此前持有的节点ID，不存在时为-1
+	Old int64
+	// New 变化后的节点ID
+	New int64
+	// Reason 本次分配的结果
+	Reason AllocOutcome
+}
+
+// allocHistory 固定容量的分配历史环形缓冲区，由调用方加锁
+type allocHistory struct {
+	events []AllocEvent
+	next   int
+}
+
+// add 追加一条记录，缓冲区已满时覆盖最早的记录
+func (h *allocHistory) add(event AllocEvent) {
+	if len(h.events) < historyCapacity {
+		h.events = append(h.events, event)
+		return
+	}
+	h.events[h.next] = event
+	h.next = (h.next + 1) % historyCapacity
+}
+
+// list 按时间先后返回记录的副本
+func (h *allocHistory) list() []AllocEvent {
+	events := make([]AllocEvent, 0, len(h.events))
+	events = append(events, h.events[h.next:]...)
+	return append(events, h.events[:h.next]...)
+}
+
+// History 本实例生命周期内持有过的节点ID，包括首次分配与之后的每次变化，按时间先后排列
+// 仅保留最近的64条记录，用于事后分析节点ID的变动
+// @return []AllocEvent
+func (m *NodeIdAllocator) History() []AllocEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.history.list()
+}
+
+// NodeHistory 数据库中key的记录持有的节点ID，按更新时间先后排列
+// 注意：key为snowflake_kv的主键，漂移时原地更新记录，表中不保留历史，结果最多只有当前持有的一个节点ID；
+// 本进程内持有过的全部节点ID见History
+// @param ctx
+// @param key 节点ID Key
+// @return []int64 key不存在时为空
+// @return error
+func (m *NodeIdAllocator) NodeHistory(ctx context.Context, key string) ([]int64, error) {
+	var nodeIds []int64
+	tab := m.dao.SnowflakeKv
+	if err := tab.WithContext(ctx).Where(tab.Key.Eq(key)).Order(tab.Updated).Pluck(tab.NodeID, &nodeIds); err != nil {
+		return nil, err
+	}
+	return nodeIds, nil
+}
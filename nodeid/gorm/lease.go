@@ -0,0 +1,108 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 节点ID租约
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+)
+
+// leaseExpired 记录的租约是否已到期，未开启WithLease或记录未写入过租约时返回false
+func (m *NodeIdAllocator) leaseExpired(owner *model.SnowflakeKv, now time.Time) bool {
+	return m.lease > 0 && owner.LeaseExpiry != nil && !owner.LeaseExpiry.After(now)
+}
+
+// ownerActive 节点ID的持有者是否活跃，记录写入过租约时以租约为准，否则以节点ID抢占时间间隔内是否更新过为准
+func (m *NodeIdAllocator) ownerActive(owner *model.SnowflakeKv, now time.Time, nowTime int64) bool {
+	if m.lease > 0 && owner.LeaseExpiry != nil {
+		return owner.LeaseExpiry.After(now)
+	}
+	return nowTime-m.timeUnit.Duration(m.nodeIdContentionInterval) <= owner.Time
+}
+
+// reclaimLease 删除租约已到期的记录，回收其节点ID
+// 持有者在查询之后续约时租约不再到期，不删除
+// @return bool 是否回收成功
+// @return error
+func (m *NodeIdAllocator) reclaimLease(ctx context.Context, q *dao.Query, owner *model.SnowflakeKv, now time.Time,
+	logger Logger) (bool, error) {
+	tab := q.SnowflakeKv
+	result, err := tab.WithContext(ctx).
+		Where(tab.Key.Eq(owner.Key), tab.NodeID.Eq(owner.NodeID), tab.LeaseExpiry.Lte(now)).Delete()
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	logger.Warnf("node id lease expired, reclaiming. key: %s, node id: %d, owner: %s, expiry: %s",
+		m.nodeIdKey, owner.NodeID, owner.Key, owner.LeaseExpiry.Format(time.RFC3339Nano))
+	return true, nil
+}
+
+// renewLoop 每隔续约间隔续约，直到Close或分配器的context结束
+func (m *NodeIdAllocator) renewLoop() {
+	ticker := time.NewTicker(m.leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.safeRenewLease()
+		case <-m.leaseStop:
+			return
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// safeRenewLease 续约，恢复其中的panic
+func (m *NodeIdAllocator) safeRenewLease() {
+	defer recoverSync(m.logger, "lease renewal")
+	m.renewLease()
+}
+
+// renewLease 将当前节点ID的租约延长到当前时间加租约时长
+// 记录已不存在或已持有其他节点ID时，说明租约到期后被其他实例回收，重新分配节点ID
+func (m *NodeIdAllocator) renewLease() {
+	m.mu.Lock()
+	nodeId, allocated := m.nodeId, m.allocated
+	m.mu.Unlock()
+	if !allocated {
+		return
+	}
+
+	now := m.clock.Now()
+	tab := m.dao.SnowflakeKv
+	result, err := tab.WithContext(m.ctx).Where(tab.Key.Eq(m.nodeIdKey), tab.NodeID.Eq(nodeId)).
+		UpdateSimple(tab.LeaseExpiry.Value(now.Add(m.lease)), tab.Updated.Value(now))
+	if err != nil {
+		m.logger.Errorf("renew node id lease failed. key: %s, node id: %d, error: %v", m.nodeIdKey, nodeId, err)
+		return
+	}
+	if result.RowsAffected > 0 {
+		recordOwnWrite(m.nodeIdKey, now)
+		return
+	}
+
+	m.logger.Errorf("node id lease was reclaimed by another instance, reallocating!!! key: %s, node id: %d",
+		m.nodeIdKey, nodeId)
+	if _, err = m.allocate(m.ctx, m.dao, m.logger); err != nil {
+		m.logger.Errorf("reallocate node id after losing the lease failed. key: %s, error: %v", m.nodeIdKey, err)
+	}
+}
+
+// stopLease 停止续约
+func (m *NodeIdAllocator) stopLease() {
+	m.leaseStopOnce.Do(func() {
+		close(m.leaseStop)
+	})
+}
@@ -0,0 +1,120 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点ID租约测试
+package gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"gorm.io/gorm"
+)
+
+// leaseExpiry 查询key记录中的租约到期时间
+func leaseExpiry(t *testing.T, db *gorm.DB, key string) time.Time {
+	var saved model.SnowflakeKv
+	require.NoError(t, db.Where("key = ?", key).Take(&saved).Error)
+	require.NotNil(t, saved.LeaseExpiry)
+	return *saved.LeaseExpiry
+}
+
+// TestWithLease_Renewal 测试续约使租约保持有效，其他实例无法回收节点ID
+func TestWithLease_Renewal(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const lease = 100 * time.Millisecond
+	nodeRange := WithNodeIdRange(nodeid.NodeIdRange{Min: 5, Max: 5})
+	holder := NewNodeIdAllocator(ctx, db, "lease-holder", testPort, time.Second, 5*time.Second, logger,
+		nodeRange, WithLease(lease, 20*time.Millisecond))
+	nodeId, err := holder.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), nodeId)
+
+	// 超过租约时长后仍未到期
+	time.Sleep(3 * lease)
+	assert.True(t, leaseExpiry(t, db, holder.nodeIdKey).After(time.Now()))
+
+	claimer := NewNodeIdAllocator(ctx, db, "lease-claimer", testPort, time.Second, 5*time.Second, logger,
+		nodeRange, WithLease(lease, 0))
+	_, err = claimer.Alloc()
+	assert.True(t, errors.Is(err, nodeid.ErrMigrationExhausted))
+	assert.Equal(t, int64(5), holder.NodeId())
+}
+
+// TestWithLease_Expiry 测试持有者停止续约后租约到期，其他实例回收节点ID
+func TestWithLease_Expiry(t *testing.T) {
+	db := testDB(t)
+	const lease = 100 * time.Millisecond
+	nodeRange := WithNodeIdRange(nodeid.NodeIdRange{Min: 5, Max: 5})
+
+	// 持有者崩溃，context结束后不再续约
+	holderCtx, crash := context.WithCancel(context.Background())
+	holder := NewNodeIdAllocator(holderCtx, db, "lease-holder", testPort, time.Second, 5*time.Second, logger,
+		nodeRange, WithLease(lease, 20*time.Millisecond))
+	_, err := holder.Alloc()
+	require.NoError(t, err)
+	crash()
+	time.Sleep(lease + 50*time.Millisecond)
+
+	claimer := NewNodeIdAllocator(context.Background(), db, "lease-claimer", testPort, time.Second, 5*time.Second,
+		logger, nodeRange, WithLease(lease, 0))
+	result, err := claimer.AllocDetailed()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.NodeID)
+	assert.Equal(t, AllocOutcomeCreated, result.Outcome)
+	assert.True(t, leaseExpiry(t, db, claimer.nodeIdKey).After(time.Now()))
+
+	var count int64
+	require.NoError(t, db.Model(&model.SnowflakeKv{}).Where("key = ?", holder.nodeIdKey).Count(&count).Error)
+	assert.Zero(t, count)
+	require.NoError(t, claimer.Close())
+}
+
+// TestWithLease_Reclaimed 测试续约时发现节点ID已被回收，重新分配并触发节点ID变化回调
+func TestWithLease_Reclaimed(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 持有者的时钟落后一小时，写入的租约对其他实例而言已到期
+	holder := NewNodeIdAllocator(ctx, db, "lease-holder", testPort, time.Second, 5*time.Second, logger,
+		WithNodeIdRange(nodeid.NodeIdRange{Min: 5, Max: 6}), WithClock(NewOffsetClock(-time.Hour)),
+		WithLease(100*time.Millisecond, 20*time.Millisecond))
+	var changed atomic.Int64
+	holder.OnNodeIdChange(func(old, new int64) { changed.Store(new) })
+	nodeId, err := holder.Alloc()
+	require.NoError(t, err)
+
+	claimer := NewNodeIdAllocator(ctx, db, "lease-claimer", testPort, time.Second, 5*time.Second, logger,
+		WithNodeIdRange(nodeid.NodeIdRange{Min: nodeId, Max: nodeId}), WithLease(time.Hour, 0))
+	claimed, err := claimer.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, nodeId, claimed)
+
+	// 持有者续约失败后漂移到范围内的另一个节点ID
+	other := 11 - nodeId
+	require.Eventually(t, func() bool { return holder.NodeId() == other }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, other, changed.Load())
+	assert.Equal(t, claimed, claimer.NodeId())
+}
+
+// TestWithLease_Default 测试续约间隔的默认值
+func TestWithLease_Default(t *testing.T) {
+	assert.Equal(t, 10*time.Second, newOption(WithLease(30*time.Second, 0)).leaseRenewInterval)
+	assert.Equal(t, 10*time.Second, newOption(WithLease(30*time.Second, time.Minute)).leaseRenewInterval)
+	assert.Equal(t, 5*time.Second, newOption(WithLease(30*time.Second, 5*time.Second)).leaseRenewInterval)
+	assert.Zero(t, newOption().lease)
+}
@@ -0,0 +1,87 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 等待成员注册
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// membersPollInterval WaitForMembers轮询注册表的间隔
+const membersPollInterval = 100 * time.Millisecond
+
+// NodeInfo 注册表中一个活跃成员的信息
+type NodeInfo struct {
+	// Key 节点ID Key
+	Key string `json:"key"`
+	// NodeID 持有的节点ID
+	NodeID int64 `json:"node_id"`
+	// Time 记录中保存的时间
+	Time time.Time `json:"time"`
+}
+
+// WaitForMembers 轮询注册表，直到以keyPrefix开头的活跃key达到count个，用于集群协同启动时等待所有实例完成注册
+// 活跃指记录在抢占时间间隔内更新过，已失效的记录不计入
+// @param ctx
+// @param keyPrefix 如服务名称加分隔符，为空时匹配全部key
+// @param count
+// @param timeout 小于等于0时只受ctx限制
+// @return []NodeInfo 最近一次轮询到的活跃成员，按key升序排列，超时或ctx结束时同样返回
+// @return error 超时返回包装了ErrMembersTimeout的错误，ctx结束返回包装了ErrContextCancelled的错误
+func (m *NodeIdAllocator) WaitForMembers(ctx context.Context, keyPrefix string, count int,
+	timeout time.Duration) ([]NodeInfo, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(membersPollInterval)
+	defer ticker.Stop()
+	for {
+		members, err := m.members(ctx, keyPrefix)
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		if err == nil && len(members) >= count {
+			return members, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+				return members, fmt.Errorf("%w. prefix: %s, members: %d, expected: %d", ErrMembersTimeout, keyPrefix,
+					len(members), count)
+			}
+			return members, CheckContext(ctx)
+		}
+	}
+}
+
+// members 查询以keyPrefix开头的活跃成员
+// 分隔符"_"是LIKE的通配符，在查询结果中按前缀过滤，而不是使用LIKE
+func (m *NodeIdAllocator) members(ctx context.Context, keyPrefix string) ([]NodeInfo, error) {
+	active := m.timeUnit.From(m.clock.Now()) - m.timeUnit.Duration(m.nodeIdContentionInterval)
+	tab := m.dao.SnowflakeKv
+	rows, err := tab.WithContext(ctx).Select(tab.Aliased("key", "node_id", "time")...).
+		Where(tab.Time.Gte(active)).Order(tab.Key).Find()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]NodeInfo, 0, len(rows))
+	for _, row := range rows {
+		if strings.HasPrefix(row.Key, keyPrefix) {
+			members = append(members, NodeInfo{Key: row.Key, NodeID: row.NodeID, Time: m.timeUnit.Time(row.Time)})
+		}
+	}
+	return members, nil
+}
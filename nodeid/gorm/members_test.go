@@ -0,0 +1,70 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 等待成员注册测试
+package gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// TestNodeIdAllocator_WaitForMembers 测试成员逐个注册时，WaitForMembers在活跃成员达到数量时返回
+func TestNodeIdAllocator_WaitForMembers(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	leader := NewNodeIdAllocator(ctx, db, "cluster", 9000, time.Second, 5*time.Second, logger)
+	prefix := "cluster" + DefaultKeySeparator
+
+	// 其他服务的记录与已失效的记录不计入
+	now := time.Now()
+	tab := dao.Use(db).SnowflakeKv
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(
+		&model.SnowflakeKv{Key: "other_10.0.0.1_8080_physical", NodeID: 1000, Time: now.UnixMilli(), Created: &now,
+			Updated: now},
+		&model.SnowflakeKv{Key: prefix + "10.0.0.2_8080_physical", NodeID: 1001,
+			Time: now.Add(-time.Minute).UnixMilli(), Created: &now, Updated: now},
+	))
+
+	var registered atomic.Int32
+	done := make(chan struct{})
+	defer func() { <-done }()
+	go func() {
+		defer close(done)
+		for port := 9001; port <= 9005; port++ {
+			time.Sleep(300 * time.Millisecond)
+			_, err := NewNodeIdAllocator(ctx, db, "cluster", port, time.Second, 5*time.Second, logger).Alloc()
+			assert.NoError(t, err)
+			registered.Inc()
+		}
+	}()
+
+	members, err := leader.WaitForMembers(ctx, prefix, 3, 5*time.Second)
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+	assert.Equal(t, int32(3), registered.Load())
+	for _, member := range members {
+		assert.Contains(t, member.Key, prefix)
+		assert.NotEqual(t, prefix+"10.0.0.2_8080_physical", member.Key)
+	}
+
+	members, err = leader.WaitForMembers(ctx, prefix, 10, 200*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrMembersTimeout))
+	assert.GreaterOrEqual(t, len(members), 3)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = leader.WaitForMembers(cancelled, prefix, 10, 0)
+	assert.True(t, errors.Is(err, ErrContextCancelled))
+}
@@ -0,0 +1,39 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 监控指标
+package gorm
+
+import "time"
+
+// 数据库操作名称，作为Metrics.ObserveDBLatency的op
+const (
+	// DBOpQuery 分配时查询当前key的记录是否存在
+	DBOpQuery = "query"
+	// DBOpCreate 分配时创建记录
+	DBOpCreate = "create"
+	// DBOpUpdate 分配时更新记录，包括刷新时间与漂移到新的节点ID
+	DBOpUpdate = "update"
+	// DBOpSync 时间同步器的一次写入，多key时间同步器为一次批量写入的事务
+	DBOpSync = "sync"
+)
+
+// Metrics 监控指标钩子，实现需并发安全且不应阻塞
+type Metrics interface {
+	// ObserveDBLatency 记录一次数据库操作的耗时，失败的操作同样记录
+	ObserveDBLatency(op string, d time.Duration)
+}
+
+// nopMetrics 不记录任何指标
+type nopMetrics struct{}
+
+// ObserveDBLatency 不记录
+func (nopMetrics) ObserveDBLatency(string, time.Duration) {}
+
+// observeSince 记录从start开始的数据库操作耗时
+func observeSince(metrics Metrics, op string, start time.Time) {
+	metrics.ObserveDBLatency(op, time.Since(start))
+}
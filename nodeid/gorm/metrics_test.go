@@ -0,0 +1,81 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 监控指标测试
+package gorm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// latencyRecorder 记录各数据库操作的耗时
+type latencyRecorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+}
+
+// ObserveDBLatency 记录耗时
+func (r *latencyRecorder) ObserveDBLatency(op string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.latencies == nil {
+		r.latencies = make(map[string][]time.Duration)
+	}
+	r.latencies[op] = append(r.latencies[op], d)
+}
+
+// get 获取操作的耗时记录
+func (r *latencyRecorder) get(op string) []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]time.Duration(nil), r.latencies[op]...)
+}
+
+// TestWithMetrics 测试数据库写入变慢时，记录的创建、更新与同步耗时不小于注入的延迟
+func TestWithMetrics(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sleep := func(*gorm.DB) { time.Sleep(delay) }
+	require.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:slow_create", sleep))
+	require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:slow_update", sleep))
+	defer func() {
+		_ = db.Callback().Create().Remove("test:slow_create")
+		_ = db.Callback().Update().Remove("test:slow_update")
+	}()
+
+	metrics := &latencyRecorder{}
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithMetrics(metrics))
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+
+	require.Len(t, metrics.get(DBOpQuery), 2)
+	require.Len(t, metrics.get(DBOpCreate), 1)
+	require.Len(t, metrics.get(DBOpUpdate), 1)
+	assert.GreaterOrEqual(t, metrics.get(DBOpCreate)[0], delay)
+	assert.GreaterOrEqual(t, metrics.get(DBOpUpdate)[0], delay)
+	assert.Less(t, metrics.get(DBOpQuery)[0], delay)
+
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 10*time.Millisecond, logger, WithMetrics(metrics))
+	synchronizer.Async(time.Now().UnixMilli())
+	synchronizer.updateDB()
+	require.Len(t, metrics.get(DBOpSync), 1)
+	assert.GreaterOrEqual(t, metrics.get(DBOpSync)[0], delay)
+}
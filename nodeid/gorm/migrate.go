@@ -0,0 +1,21 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 表结构迁移
+package gorm
+
+import (
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"gorm.io/gorm"
+)
+
+// AutoMigrate 在协调数据库上创建或更新snowflake_kv与snowflake_admission表结构
+// 使用WithColumnNames映射到已有表时无需迁移snowflake_kv；snowflake_admission仅在开启WithAdmissionLimit时使用
+// @param db 节点ID注册表所在的协调数据库
+// @return error
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&model.SnowflakeKv{}, &model.SnowflakeAdmission{})
+}
@@ -0,0 +1,62 @@
+package dao
+
+import (
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+// 本文件为手写代码，重新生成dao时保留
+
+// WithColumnNames 返回将字段映射到自定义列名的查询对象
+func (q *Query) WithColumnNames(names map[string]string) *Query {
+	return &Query{
+		db:          q.db,
+		SnowflakeKv: q.SnowflakeKv.WithColumnNames(names),
+	}
+}
+
+// WithTx 返回在指定事务中执行的查询对象，保留列名映射等配置
+func (q *Query) WithTx(tx *gorm.DB) *Query {
+	return q.clone(tx)
+}
+
+// WithColumnNames 将字段映射到自定义的列名，用于兼容列名不同的旧表
+// names的key为默认列名，未出现的列沿用默认列名；调用Table、As后恢复为默认列名
+func (s snowflakeKv) WithColumnNames(names map[string]string) snowflakeKv {
+	column := func(name string) string {
+		if column, ok := names[name]; ok && column != "" {
+			return column
+		}
+		return name
+	}
+	table := s.snowflakeKvDo.TableName()
+	s.Key = field.NewString(table, column("key"))
+	s.NodeID = field.NewInt64(table, column("node_id"))
+	s.Time = field.NewInt64(table, column("time"))
+	s.Created = field.NewTime(table, column("created"))
+	s.Updated = field.NewTime(table, column("updated"))
+	s.IP = field.NewString(table, column("ip"))
+	s.DeployType = field.NewString(table, column("deploy_type"))
+	s.LeaseExpiry = field.NewTime(table, column("lease_expiry"))
+	s.fillFieldMap()
+	return s
+}
+
+// ColumnName 默认列名对应的实际列名
+func (s snowflakeKv) ColumnName(name string) string {
+	if f, ok := s.fieldMap[name]; ok {
+		return string(f.ColumnName())
+	}
+	return name
+}
+
+// Aliased 以默认列名为别名的字段，映射列名后查询需使用它们以便将结果扫描到model
+func (s snowflakeKv) Aliased(names ...string) []field.Expr {
+	exprs := make([]field.Expr, 0, len(names))
+	for _, name := range names {
+		if f, ok := s.fieldMap[name]; ok {
+			exprs = append(exprs, f.As(name))
+		}
+	}
+	return exprs
+}
@@ -1,6 +1,4 @@
 // Code generated by gorm.io/gen. DO NOT EDIT.
-// Code generated by gorm.io/gen. DO NOT EDIT.
-// Code generated by gorm.io/gen. DO NOT EDIT.
 
 package dao
 
@@ -32,6 +30,9 @@ func newSnowflakeKv(db *gorm.DB, opts ...gen.DOOption) snowflakeKv {
 	_snowflakeKv.Time = field.NewInt64(tableName, "time")
 	_snowflakeKv.Created = field.NewTime(tableName, "created")
 	_snowflakeKv.Updated = field.NewTime(tableName, "updated")
+	_snowflakeKv.IP = field.NewString(tableName, "ip")
+	_snowflakeKv.DeployType = field.NewString(tableName, "deploy_type")
+	_snowflakeKv.LeaseExpiry = field.NewTime(tableName, "lease_expiry")
 
 	_snowflakeKv.fillFieldMap()
 
@@ -41,12 +42,15 @@ func newSnowflakeKv(db *gorm.DB, opts ...gen.DOOption) snowflakeKv {
 type snowflakeKv struct {
 	snowflakeKvDo snowflakeKvDo
 
-	ALL     field.Asterisk
-	Key     field.String // Key
-	NodeID  field.Int64  // Node ID
-	Time    field.Int64  // time
-	Created field.Time   // 创建时间
-	Updated field.Time   // 更新时间
+	ALL         field.Asterisk
+	Key         field.String // Key
+	NodeID      field.Int64  // Node ID
+	Time        field.Int64  // time
+	Created     field.Time   // 创建时间
+	Updated     field.Time   // 更新时间
+	IP          field.String // IP
+	DeployType  field.String // 部署类型
+	LeaseExpiry field.Time   // 租约到期时间
 
 	fieldMap map[string]field.Expr
 }
@@ -68,6 +72,9 @@ func (s *snowflakeKv) updateTableName(table string) *snowflakeKv {
 	s.Time = field.NewInt64(table, "time")
 	s.Created = field.NewTime(table, "created")
 	s.Updated = field.NewTime(table, "updated")
+	s.IP = field.NewString(table, "ip")
+	s.DeployType = field.NewString(table, "deploy_type")
+	s.LeaseExpiry = field.NewTime(table, "lease_expiry")
 
 	s.fillFieldMap()
 
@@ -94,12 +101,15 @@ func (s *snowflakeKv) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (s *snowflakeKv) fillFieldMap() {
-	s.fieldMap = make(map[string]field.Expr, 5)
+	s.fieldMap = make(map[string]field.Expr, 8)
 	s.fieldMap["key"] = s.Key
 	s.fieldMap["node_id"] = s.NodeID
 	s.fieldMap["time"] = s.Time
 	s.fieldMap["created"] = s.Created
 	s.fieldMap["updated"] = s.Updated
+	s.fieldMap["ip"] = s.IP
+	s.fieldMap["deploy_type"] = s.DeployType
+	s.fieldMap["lease_expiry"] = s.LeaseExpiry
 }
 
 func (s snowflakeKv) clone(db *gorm.DB) snowflakeKv {
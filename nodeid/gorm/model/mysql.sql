@@ -1,13 +1,24 @@
 -- auto-generated definition
 create table snowflake_kv
 (
-    `key`   varchar(191) not null comment 'Key'
+    `key`       varchar(191) not null comment 'Key'
         primary key,
-    node_id bigint       not null comment 'Node ID',
-    time    bigint       not null comment 'time',
-    created datetime(3)  not null comment '创建时间',
-    updated datetime(3)  not null comment '更新时间',
+    node_id     bigint       not null comment 'Node ID',
+    time        bigint       not null comment 'time',
+    created     datetime(3)  not null comment '创建时间',
+    updated     datetime(3)  not null comment '更新时间',
+    ip          varchar(64)  null comment 'IP',
+    deploy_type varchar(32)  null comment '部署类型',
+    lease_expiry datetime(3) null comment '租约到期时间',
     constraint snowflake_kv_UN_node_id
         unique (node_id)
 );
 
+-- 可选，仅在开启WithAdmissionLimit时使用
+create table snowflake_admission
+(
+    name      varchar(191) not null comment '令牌名称'
+        primary key,
+    in_flight bigint       not null comment '进行中的分配数',
+    updated   datetime(3)  not null comment '更新时间'
+);
@@ -1,12 +1,15 @@
 -- auto-generated definition
 create table snowflake_kv
 (
-    key     text                     not null
+    key         text                     not null
         primary key,
-    node_id bigint,
-    time    bigint                   not null,
-    created timestamp with time zone not null,
-    updated timestamp with time zone not null
+    node_id     bigint,
+    time        bigint                   not null,
+    created     timestamp with time zone not null,
+    updated     timestamp with time zone not null,
+    ip          text,
+    deploy_type text,
+    lease_expiry timestamp with time zone
 );
 
 comment on column snowflake_kv.key is 'Key';
@@ -19,9 +22,30 @@ comment on column snowflake_kv.created is '创建时间';
 
 comment on column snowflake_kv.updated is '更新时间';
 
+comment on column snowflake_kv.ip is 'IP';
+
+comment on column snowflake_kv.deploy_type is '部署类型';
+
+comment on column snowflake_kv.lease_expiry is '租约到期时间';
+
 alter table snowflake_kv
     owner to system;
 
 create unique index "snowflake_kv_UN_node_id"
     on snowflake_kv (node_id);
 
+
+-- 可选，仅在开启WithAdmissionLimit时使用
+create table snowflake_admission
+(
+    name      text                     not null
+        primary key,
+    in_flight bigint                   not null,
+    updated   timestamp with time zone not null
+);
+
+comment on column snowflake_admission.name is '令牌名称';
+
+comment on column snowflake_admission.in_flight is '进行中的分配数';
+
+comment on column snowflake_admission.updated is '更新时间';
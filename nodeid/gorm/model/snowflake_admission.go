@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+)
+
+const TableNameSnowflakeAdmission = "snowflake_admission"
+
+// SnowflakeAdmission mapped from table <snowflake_admission>
+type SnowflakeAdmission struct {
+	Name     string    `gorm:"column:name;primaryKey;comment:令牌名称" json:"name"`            // 令牌名称
+	InFlight int64     `gorm:"column:in_flight;not null;comment:进行中的分配数" json:"in_flight"` // 进行中的分配数
+	Updated  time.Time `gorm:"column:updated;not null;comment:更新时间" json:"updated"`        // 更新时间
+}
+
+// TableName SnowflakeAdmission's table name
+func (*SnowflakeAdmission) TableName() string {
+	return TableNameSnowflakeAdmission
+}
@@ -1,6 +1,4 @@
 // Code generated by gorm.io/gen. DO NOT EDIT.
-// Code generated by gorm.io/gen. DO NOT EDIT.
-// Code generated by gorm.io/gen. DO NOT EDIT.
 
 package model
 
@@ -12,11 +10,14 @@ const TableNameSnowflakeKv = "snowflake_kv"
 
 // SnowflakeKv mapped from table <snowflake_kv>
 type SnowflakeKv struct {
-	Key     string     `gorm:"column:key;primaryKey;comment:Key" json:"key"`                                                          // Key
-	NodeID  int64      `gorm:"column:node_id;not null;uniqueIndex:snowflake_kv_UN_node_id,priority:1;comment:Node ID" json:"node_id"` // Node ID
-	Time    int64      `gorm:"column:time;not null;comment:time" json:"time"`                                                         // time
-	Created *time.Time `gorm:"column:created;not null;comment:创建时间" json:"created"`                                                   // 创建时间
-	Updated time.Time  `gorm:"column:updated;not null;comment:更新时间" json:"updated"`                                                   // 更新时间
+	Key         string     `gorm:"column:key;primaryKey;comment:Key" json:"key"`                                                          // Key
+	NodeID      int64      `gorm:"column:node_id;not null;uniqueIndex:snowflake_kv_UN_node_id,priority:1;comment:Node ID" json:"node_id"` // Node ID
+	Time        int64      `gorm:"column:time;not null;comment:time" json:"time"`                                                         // time
+	Created     *time.Time `gorm:"column:created;not null;comment:创建时间" json:"created"`                                                   // 创建时间
+	Updated     time.Time  `gorm:"column:updated;not null;comment:更新时间" json:"updated"`                                                   // 更新时间
+	IP          string     `gorm:"column:ip;comment:IP" json:"ip"`                                                                        // IP
+	DeployType  string     `gorm:"column:deploy_type;comment:部署类型" json:"deploy_type"`                                                    // 部署类型
+	LeaseExpiry *time.Time `gorm:"column:lease_expiry;comment:租约到期时间" json:"lease_expiry"`                                                // 租约到期时间
 }
 
 // TableName SnowflakeKv's table name
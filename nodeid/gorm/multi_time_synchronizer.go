@@ -0,0 +1,159 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 多key时间同步器
+package gorm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"github.com/bwmarrin/snowflake"
+	"go.uber.org/atomic"
+	"gorm.io/gorm"
+)
+
+var _ snowflake.TimeSynchronizer = new(keyedTimeSynchronizer)
+
+// MultiTimeSynchronizer 多key时间同步器
+// 适用于一个进程承载大量节点ID Key（如多租户）的场景，所有key共享一个goroutine与ticker，
+// 每次tick在一个事务内批量写入发生变化的时间
+type MultiTimeSynchronizer struct {
+	ctx    context.Context
+	dao    *dao.Query
+	ticker *time.Ticker
+	logger Logger
+	// 持久化时间戳的单位
+	timeUnit TimeUnit
+	// 获取当前时间的时钟
+	clock Clock
+	// metrics 监控指标钩子
+	metrics Metrics
+
+	mu sync.RWMutex
+	// watermarks 各key的内存时间
+	watermarks map[string]*keyedTimeSynchronizer
+}
+
+// keyedTimeSynchronizer 单个key的时间记录
+type keyedTimeSynchronizer struct {
+	curr atomic.Int64
+	// flushed 上次写入数据库的时间，仅由同步goroutine访问
+	flushed int64
+}
+
+// Async 同步时间
+func (k *keyedTimeSynchronizer) Async(t int64) {
+	last := k.curr.Load()
+	if t > last+10 { // 10ms 阈值
+		k.curr.Store(t)
+	}
+}
+
+// NewMultiTimeSynchronizer 创建一个多key时间同步器
+func NewMultiTimeSynchronizer(ctx context.Context, db *gorm.DB, interval time.Duration, logger Logger,
+	opts ...OptionFn) *MultiTimeSynchronizer {
+	op := newOption(opts...)
+
+	return &MultiTimeSynchronizer{
+		ctx:        ctx,
+		dao:        useDao(db, op.columns),
+		ticker:     time.NewTicker(interval),
+		logger:     logger,
+		timeUnit:   op.timeUnit,
+		clock:      op.clock,
+		metrics:    op.metrics,
+		watermarks: make(map[string]*keyedTimeSynchronizer),
+	}
+}
+
+// Register 注册一个节点ID Key，重复注册返回同一个时间同步器
+// @param nodeIdKey
+// @return snowflake.TimeSynchronizer
+func (m *MultiTimeSynchronizer) Register(nodeIdKey string) snowflake.TimeSynchronizer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if synchronizer, ok := m.watermarks[nodeIdKey]; ok {
+		return synchronizer
+	}
+	synchronizer := &keyedTimeSynchronizer{}
+	m.watermarks[nodeIdKey] = synchronizer
+	return synchronizer
+}
+
+// Unregister 注销一个节点ID Key
+// @param nodeIdKey
+func (m *MultiTimeSynchronizer) Unregister(nodeIdKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.watermarks, nodeIdKey)
+}
+
+func (m *MultiTimeSynchronizer) Run() {
+	go func(m *MultiTimeSynchronizer) {
+		for {
+			select {
+			case <-m.ticker.C:
+				m.safeUpdateDB()
+			case <-m.ctx.Done():
+				m.logger.Info("multi time synchronizer is done")
+				return
+			}
+		}
+	}(m)
+}
+
+// updateDB 在一个事务内将所有发生变化的时间同步到数据库
+func (m *MultiTimeSynchronizer) updateDB() {
+	type watermark struct {
+		key          string
+		synchronizer *keyedTimeSynchronizer
+		time         int64
+	}
+
+	m.mu.RLock()
+	dirty := make([]watermark, 0, len(m.watermarks))
+	for key, synchronizer := range m.watermarks {
+		if current := synchronizer.curr.Load(); current != 0 && current != synchronizer.flushed {
+			dirty = append(dirty, watermark{key: key, synchronizer: synchronizer, time: current})
+		}
+	}
+	m.mu.RUnlock()
+	if len(dirty) == 0 {
+		return
+	}
+	// 固定更新顺序，避免多个进程并发写入时死锁
+	sort.Slice(dirty, func(i, j int) bool { return dirty[i].key < dirty[j].key })
+
+	now, skew := m.clock.Now(), skewMilli(m.clock)
+	start := time.Now()
+	err := m.dao.Transaction(func(tx *dao.Query) error {
+		tab := tx.SnowflakeKv
+		for _, w := range dirty {
+			// Async接收的是系统时间的毫秒时间戳，写入时叠加时钟偏移并转换为配置的单位，保存的时间更大时不回退
+			saved := m.timeUnit.FromMilli(w.time + skew)
+			if _, err := tab.WithContext(m.ctx).Where(tab.Key.Eq(w.key), tab.Time.Lte(saved)).
+				UpdateSimple(tab.Time.Value(saved), tab.Updated.Value(now)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	observeSince(m.metrics, DBOpSync, start)
+	if err != nil {
+		m.logger.Errorf("batch update time failed. keys: %d, error: %v", len(dirty), err)
+		return
+	}
+
+	for _, w := range dirty {
+		w.synchronizer.flushed = w.time
+	}
+}
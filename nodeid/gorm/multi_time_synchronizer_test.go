@@ -0,0 +1,110 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 多key时间同步器测试
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedKeys 为每个key写入一条节点ID记录
+func seedKeys(t *testing.T, db *gorm.DB, count int) []string {
+	keys := make([]string, 0, count)
+	now := time.Now()
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("tenant-%02d", i)
+		require.NoError(t, db.Create(&model.SnowflakeKv{
+			Key: key, NodeID: int64(i), Time: 1, Created: &now, Updated: now,
+		}).Error)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// TestMultiTimeSynchronizer_Register 测试重复注册返回同一个时间同步器
+func TestMultiTimeSynchronizer_Register(t *testing.T) {
+	synchronizer := NewMultiTimeSynchronizer(context.Background(), testDB(t), time.Second, logger)
+
+	first := synchronizer.Register("tenant")
+	assert.Same(t, first, synchronizer.Register("tenant"))
+	assert.NotSame(t, first, synchronizer.Register("other"))
+
+	var _ snowflake.TimeSynchronizer = first
+}
+
+// TestMultiTimeSynchronizer_UpdateDB 测试一次tick批量写入所有key的时间
+func TestMultiTimeSynchronizer_UpdateDB(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	keys := seedKeys(t, db, 50)
+
+	synchronizer := NewMultiTimeSynchronizer(ctx, db, time.Second, logger)
+	base := time.Now().UnixMilli()
+	for i, key := range keys {
+		synchronizer.Register(key).Async(base + int64(i)*100)
+	}
+
+	// 统计一次tick内执行的更新语句，并确认均在事务内执行
+	var statements, inTransaction int
+	require.NoError(t, db.Callback().Update().Before("gorm:update").
+		Register("test:count_statements", func(tx *gorm.DB) {
+			statements++
+			if _, ok := tx.Statement.ConnPool.(gorm.TxCommitter); ok {
+				inTransaction++
+			}
+		}))
+	defer func() { _ = db.Callback().Update().Remove("test:count_statements") }()
+
+	synchronizer.updateDB()
+
+	tab := synchronizer.dao.SnowflakeKv
+	records, err := tab.WithContext(ctx).Order(tab.Key).Find()
+	require.NoError(t, err)
+	require.Len(t, records, len(keys))
+	for i, record := range records {
+		assert.Equal(t, keys[i], record.Key)
+		assert.Equal(t, base+int64(i)*100, record.Time)
+	}
+	assert.Equal(t, len(keys), statements)
+	assert.Equal(t, statements, inTransaction)
+
+	// 未变化的key不会被再次写入
+	statements = 0
+	synchronizer.Register(keys[0]).Async(base + time.Hour.Milliseconds())
+	synchronizer.updateDB()
+	assert.Equal(t, 1, statements)
+}
+
+// TestMultiTimeSynchronizer_Run 测试后台goroutine定时写入
+func TestMultiTimeSynchronizer_Run(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keys := seedKeys(t, db, 5)
+
+	synchronizer := NewMultiTimeSynchronizer(ctx, db, 50*time.Millisecond, logger, WithTimeUnit(TimeUnitSeconds))
+	testTime := time.Now().Add(time.Minute)
+	for _, key := range keys {
+		synchronizer.Register(key).Async(testTime.UnixMilli())
+	}
+	synchronizer.Run()
+
+	tab := synchronizer.dao.SnowflakeKv
+	assert.Eventually(t, func() bool {
+		count, err := tab.WithContext(ctx).Where(tab.Time.Eq(TimeUnitSeconds.From(testTime))).Count()
+		return err == nil && count == int64(len(keys))
+	}, time.Second, 10*time.Millisecond)
+}
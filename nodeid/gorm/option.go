@@ -0,0 +1,415 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 可选配置
+package gorm
+
+import (
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+)
+
+// Option gorm节点ID分配器与时间同步器的可选配置
+type Option struct {
+	// timeUnit 持久化时间戳的单位
+	timeUnit TimeUnit
+	// persistAddress 是否将解析出的IP与部署类型写入独立的列
+	persistAddress bool
+	// strictUniqueness 节点ID被其他活跃的key持有时是否直接报错
+	strictUniqueness bool
+	// podIPFile 提供Pod IP的文件路径，为空表示只读取POD_IP环境变量
+	podIPFile string
+	// podIPWait 等待podIPFile的内容变为有效地址的最长时间
+	podIPWait time.Duration
+	// partitions 按部署类型划分的节点ID范围
+	partitions DeployTypePartitions
+	// clock 获取当前时间的时钟
+	clock Clock
+	// rollbackPollInterval 小幅时钟回拨时轮询时钟的间隔，0表示等待完整的容忍时间
+	rollbackPollInterval time.Duration
+	// maxRollbackWait 小幅时钟回拨时最长等待时间，0表示等待完整的容忍时间
+	maxRollbackWait time.Duration
+	// addressFamily 节点ID Key使用的IP地址族
+	addressFamily AddressFamily
+	// columns snowflake_kv各列的实际列名
+	columns ColumnNames
+	// staleIdentityThreshold 自身记录超过该时长未更新时视为失效的前一个实例，0表示不检查
+	staleIdentityThreshold time.Duration
+	// store 节点ID的外部持久化，nil表示使用数据库
+	store NodeIdStore
+	// keySeparator 节点ID Key各部分之间的分隔符
+	keySeparator string
+	// detectCompetingWriter 时间同步器写入前是否检查记录被其他实例刷新
+	detectCompetingWriter bool
+	// deployTypePrecedence Kubernetes与Docker信号同时存在时的部署类型判断规则
+	deployTypePrecedence DeployTypePrecedence
+	// deployType 显式指定的部署类型，为空时自动检测
+	deployType DeployType
+	// cachePadding 时间同步器的内存时间是否独占整个缓存行
+	cachePadding bool
+	// physicalFallback 自动检测未命中任何信号、回退为物理机时的回调
+	physicalFallback func()
+	// onIPChange Refresh时检测到IP变化的回调
+	onIPChange func(stored, current string)
+	// nodeRange 限定的节点ID范围，nil表示不限定
+	nodeRange *nodeid.NodeIdRange
+	// degradedThreshold 时间同步器连续写入失败多少次后进入降级状态
+	degradedThreshold int64
+	// identityFile 提供实例标识的文件路径，为空表示使用IP
+	identityFile string
+	// metrics 监控指标钩子
+	metrics Metrics
+	// admissionLimit 同时进行的分配数上限，0表示不限制
+	admissionLimit int64
+	// admissionLease 准入令牌的租约
+	admissionLease time.Duration
+	// reservedNodeIds 分配时跳过的保留节点ID
+	reservedNodeIds []int64
+	// lease 节点ID租约时长，0表示不使用租约
+	lease time.Duration
+	// leaseRenewInterval 续约间隔
+	leaseRenewInterval time.Duration
+	// ipPrecedence POD_IP与网卡扫描得到的IP不一致时的选择规则
+	ipPrecedence IPPrecedence
+	// sticky 首次分配时是否优先沿用key记录中的节点ID
+	sticky bool
+}
+
+// OptionFn 可选配置函数
+type OptionFn func(op *Option)
+
+// WithTimeUnit 设置持久化时间戳的单位，默认为毫秒
+// 同一张表上的所有实例必须使用相同的单位
+// 注意：TimeUnitSeconds写入时截断到整秒，时钟回拨检测在acceptableClockDrift之外额外容忍最多1秒的回拨
+// @param unit
+// @return OptionFn
+func WithTimeUnit(unit TimeUnit) OptionFn {
+	return func(op *Option) {
+		op.timeUnit = unit
+	}
+}
+
+// WithPersistAddress 设置是否将解析出的IP与部署类型写入ip、deploy_type列，默认不写入
+// 开启前需确保表结构中已存在这两列
+// @param enabled
+// @return OptionFn
+func WithPersistAddress(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.persistAddress = enabled
+	}
+}
+
+// WithOnIPChange 设置IP变化的回调，开启WithPersistAddress后每次Refresh成功时比较记录中的IP与当前解析出的IP，不一致时调用
+// 节点ID Key在构造时确定，IP变化后不再代表当前实例，分配器不会自行重建，应在回调中重新创建分配器（如通过Wrapper.SwapAllocator）
+// @param callback stored为记录中保存的IP，current为当前解析出的IP
+// @return OptionFn
+func WithOnIPChange(callback func(stored, current string)) OptionFn {
+	return func(op *Option) {
+		op.onIPChange = callback
+	}
+}
+
+// WithStrictUniqueness 设置严格唯一模式，默认关闭
+// 开启后若哈希得到的节点ID已被其他活跃的key持有，Alloc直接返回ErrNodeIdCollision，而不是探测下一个节点ID；
+// 若节点ID Key已被进程内其他活跃的分配器持有，Alloc返回ErrKeyInUse，而不是仅输出错误日志
+// @param enabled
+// @return OptionFn
+func WithStrictUniqueness(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.strictUniqueness = enabled
+	}
+}
+
+// WithPodIPFile 设置提供Pod IP的文件路径，如Kubernetes downward API卷中引用status.podIP的文件，默认不使用
+// 环境变量在进程启动后不会变化，Pod IP可能延迟分配时应通过文件注入：构造时等待文件内容变为有效地址，最长等待timeout，
+// 文件中的有效地址优先于POD_IP，超时后回退到POD_IP与网卡IP；节点ID分配器与时间同步器需使用相同的配置，以保证节点ID Key一致
+// @param path
+// @param timeout <=0时只读取一次，不等待
+// @return OptionFn
+func WithPodIPFile(path string, timeout time.Duration) OptionFn {
+	return func(op *Option) {
+		op.podIPFile = path
+		op.podIPWait = timeout
+	}
+}
+
+// WithDeployTypePartitions 设置按部署类型划分节点ID范围，Alloc与Migration被限定在当前部署类型的范围内
+// 不同部署类型的节点ID互不重叠，便于排查问题并缩小冲突范围，可使用DefaultDeployTypePartitions
+// @param partitions
+// @return OptionFn
+func WithDeployTypePartitions(partitions DeployTypePartitions) OptionFn {
+	return func(op *Option) {
+		op.partitions = partitions
+	}
+}
+
+// WithClock 设置获取当前时间的时钟，默认为SystemClock
+// 时钟回拨判断与水位写入均使用该时钟，同一张表上的所有实例应使用一致校正后的时间
+// @param clock
+// @return OptionFn
+func WithClock(clock Clock) OptionFn {
+	return func(op *Option) {
+		op.clock = clock
+	}
+}
+
+// WithRollbackPollInterval 设置小幅时钟回拨时的轮询间隔，默认为0，即等待完整的容忍时间
+// 开启后每隔interval检查一次时钟，追上保存的时间即返回，最长等待容忍时间
+// @param interval
+// @return OptionFn
+func WithRollbackPollInterval(interval time.Duration) OptionFn {
+	return func(op *Option) {
+		op.rollbackPollInterval = interval
+	}
+}
+
+// WithMaxRollbackWait 设置小幅时钟回拨时的最长等待时间，默认为0，即等待完整的容忍时间
+// 将"容忍回拨"与"等待时长"解耦：容忍时间很大时（如批处理任务为避免报错而设置）不会因小幅回拨停顿整个容忍时间；
+// 等待结束时时钟仍未追上保存的时间则漂移到新的节点ID，建议同时配置WithRollbackPollInterval以便时钟追上后立即返回
+// @param wait
+// @return OptionFn
+func WithMaxRollbackWait(wait time.Duration) OptionFn {
+	return func(op *Option) {
+		op.maxRollbackWait = wait
+	}
+}
+
+// WithAddressFamily 设置节点ID Key使用的IP地址族，默认为AddressFamilyAuto
+// 双栈主机上固定地址族可避免不同启动间网卡顺序变化导致节点ID Key不稳定
+// 节点ID分配器与时间同步器需使用相同的配置，以保证节点ID Key一致
+// @param family
+// @return OptionFn
+func WithAddressFamily(family AddressFamily) OptionFn {
+	return func(op *Option) {
+		op.addressFamily = family
+	}
+}
+
+// WithColumnNames 设置snowflake_kv各列的实际列名，用于在列名不同的已有表上使用，默认使用model中的列名
+// 节点ID分配器与时间同步器需使用相同的配置
+// @param columns 为空的列沿用默认列名
+// @return OptionFn
+func WithColumnNames(columns ColumnNames) OptionFn {
+	return func(op *Option) {
+		op.columns = columns
+	}
+}
+
+// WithStaleIdentityThreshold 设置自身记录的最大有效时长，默认为0，即不检查
+// 分配时若当前key的记录超过threshold未更新，视为早已退出的前一个实例留下的记录，删除后重新执行分配与冲突检测，
+// 而不是直接刷新时间继续使用
+// @param threshold
+// @return OptionFn
+func WithStaleIdentityThreshold(threshold time.Duration) OptionFn {
+	return func(op *Option) {
+		op.staleIdentityThreshold = threshold
+	}
+}
+
+// WithNodeIdStore 设置节点ID的外部持久化，默认为nil，即使用数据库的snowflake_kv表
+// 设置后分配器从store加载节点ID，不存在时按哈希分配并写入store，不再读写数据库
+// @param store
+// @return OptionFn
+func WithNodeIdStore(store NodeIdStore) OptionFn {
+	return func(op *Option) {
+		op.store = store
+	}
+}
+
+// WithKeySeparator 设置节点ID Key各部分之间的分隔符，默认为DefaultKeySeparator
+// 分隔符不能出现在IP（或标识文件内容）、端口与部署类型中，出现时key无法无歧义地解析，Alloc返回包装了ErrInvalidNodeIdKey的错误；
+// 建议使用"_"、"|"、"/"等符号，为空时沿用默认分隔符
+// 修改分隔符会改变节点ID Key，已有记录将无法匹配；节点ID分配器与时间同步器需使用相同的配置
+// @param separator
+// @return OptionFn
+func WithKeySeparator(separator string) OptionFn {
+	return func(op *Option) {
+		op.keySeparator = separator
+	}
+}
+
+// WithCompetingWriterDetection 设置时间同步器是否检测其他实例以相同的key刷新记录，默认关闭
+// 开启后每次写入前查询记录的更新时间，晚于本进程最近一次写入时输出错误日志并计入CompetingWrites，
+// 用于发现多个副本共享节点ID Key的错误配置；各实例的时钟需大致同步
+// @param enabled
+// @return OptionFn
+func WithCompetingWriterDetection(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.detectCompetingWriter = enabled
+	}
+}
+
+// WithDeployTypePrecedence 设置Kubernetes与Docker信号同时存在时的部署类型判断规则，默认为DeployTypePrecedenceK8s
+// 修改规则会改变这类环境下的节点ID Key，已有记录将无法匹配；节点ID分配器与时间同步器需使用相同的配置
+// @param precedence
+// @return OptionFn
+func WithDeployTypePrecedence(precedence DeployTypePrecedence) OptionFn {
+	return func(op *Option) {
+		op.deployTypePrecedence = precedence
+	}
+}
+
+// WithDeployType 显式指定节点ID Key中的部署类型，不再自动检测，也不会触发WithPhysicalFallback的回调
+// 节点ID分配器与时间同步器需使用相同的配置
+// @param deployType
+// @return OptionFn
+func WithDeployType(deployType DeployType) OptionFn {
+	return func(op *Option) {
+		op.deployType = deployType
+	}
+}
+
+// WithPhysicalFallback 设置部署类型自动检测未命中任何信号、回退为物理机时的回调，在创建节点ID分配器时调用
+// 云上环境被识别为物理机通常说明检测信号缺失，可在回调中告警；显式指定或检测到其他部署类型时不调用
+// @param callback
+// @return OptionFn
+func WithPhysicalFallback(callback func()) OptionFn {
+	return func(op *Option) {
+		op.physicalFallback = callback
+	}
+}
+
+// WithCachePadding 设置时间同步器的内存时间是否填充到独占整个缓存行，默认开启
+// 填充避免Async与相邻字段发生伪共享，但每个实例多占用112字节；一个进程承载成千上万个时间同步器
+// （如每个租户一个）且单个实例的Async竞争不激烈时，可关闭以节省内存，行为不变
+// @param enabled
+// @return OptionFn
+func WithCachePadding(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.cachePadding = enabled
+	}
+}
+
+// WithNodeIdRange 将Alloc与Migration限定在nodeRange内，优先于WithDeployTypePartitions
+// 用于将节点ID位划分为数据中心与工作节点，nodeRange为当前数据中心的工作节点ID范围
+// @param nodeRange
+// @return OptionFn
+func WithNodeIdRange(nodeRange nodeid.NodeIdRange) OptionFn {
+	return func(op *Option) {
+		op.nodeRange = &nodeRange
+	}
+}
+
+// WithDegradedThreshold 设置时间同步器连续写入失败多少次后进入降级状态，默认为DefaultDegradedThreshold
+// 小于1时沿用默认值
+// @param failures
+// @return OptionFn
+func WithDegradedThreshold(failures int) OptionFn {
+	return func(op *Option) {
+		op.degradedThreshold = int64(failures)
+	}
+}
+
+// WithIdentityFile 设置提供实例标识的文件路径，如Kubernetes downward API卷中包含Pod名称或UID的文件
+// 文件存在且内容非空时，节点ID Key中的IP部分替换为去除首尾空白后的文件内容；文件不存在或为空时回退到POD_IP与网卡IP
+// 文件内容包含节点ID Key的分隔符时拒绝分配，可通过WithKeySeparator更换分隔符；节点ID分配器与时间同步器需使用相同的配置
+// @param path
+// @return OptionFn
+func WithIdentityFile(path string) OptionFn {
+	return func(op *Option) {
+		op.identityFile = path
+	}
+}
+
+// WithMetrics 设置监控指标钩子，记录分配时查询、创建、更新记录与时间同步器写入的数据库耗时，默认不记录
+// 可用于发现数据库缓慢导致的启动耗时问题
+// @param metrics
+// @return OptionFn
+func WithMetrics(metrics Metrics) OptionFn {
+	return func(op *Option) {
+		op.metrics = metrics
+	}
+}
+
+// WithAdmissionLimit 开启分配准入令牌，最多limit个实例同时执行分配，其余实例等待后重试，默认不限制
+// 用于大量实例同时启动时平滑数据库的负载；每个持有者为snowflake_admission表中的一条记录，所有共享协调数据库的实例应使用相同的limit
+// 持有者崩溃未归还令牌时，获取令牌超过lease后被回收，lease小于等于0时使用DefaultAdmissionLease；使用WithNodeIdStore时不生效
+// @param limit 小于1时不限制
+// @param lease
+// @return OptionFn
+func WithAdmissionLimit(limit int, lease time.Duration) OptionFn {
+	return func(op *Option) {
+		op.admissionLimit = int64(limit)
+		op.admissionLease = lease
+	}
+}
+
+// WithReservedNodeIds 设置保留的节点ID，Alloc与Migration跳过这些节点ID，默认不保留
+// 适用于将节点ID 0或某个低位范围留给管理、测试等用途的场景；已持有保留节点ID的记录不受影响
+// @param reserved
+// @return OptionFn
+func WithReservedNodeIds(reserved []int64) OptionFn {
+	return func(op *Option) {
+		op.reservedNodeIds = reserved
+	}
+}
+
+// WithLease 开启显式的节点ID租约，默认关闭
+// 分配时在记录的lease_expiry列写入租约到期时间，后台goroutine每隔renewInterval续约；
+// 其他key持有的节点ID租约到期后可被回收，租约未到期时视为活跃，与记录的时间无关；
+// 续约时发现节点ID已被回收则重新分配并触发OnNodeIdChange回调。开启前需确保表结构中已存在lease_expiry列，
+// 未写入过租约的记录仍按节点ID抢占时间间隔判断；使用WithNodeIdStore时不生效
+// @param duration 租约时长，小于等于0时不开启
+// @param renewInterval 续约间隔，小于等于0或不小于duration时使用duration的三分之一
+// @return OptionFn
+func WithLease(duration, renewInterval time.Duration) OptionFn {
+	return func(op *Option) {
+		op.lease = duration
+		op.leaseRenewInterval = renewInterval
+	}
+}
+
+// WithIPPrecedence 设置POD_IP与网卡扫描得到的IP均有效且不一致时的选择规则，默认为IPPrecedenceEnv
+// 两者不一致时输出告警；IPPrecedenceAgreement下输出错误日志，Alloc返回包装了ErrIPMismatch的错误
+// @param precedence
+// @return OptionFn
+func WithIPPrecedence(precedence IPPrecedence) OptionFn {
+	return func(op *Option) {
+		op.ipPrecedence = precedence
+	}
+}
+
+// WithStickyNodeId 设置粘性模式，默认关闭
+// 开启后首次分配优先沿用数据库中key记录持有的节点ID，记录不存在或节点ID已不在当前的节点ID空间、范围内或被保留时才重新分配；
+// 默认每次启动都从哈希得到的节点ID开始，key此前漂移到其他节点ID时会移回哈希节点ID；
+// 因时钟回拨漂移时，哈希节点ID上遗留的水位记录过期后才移回
+// @param enabled
+// @return OptionFn
+func WithStickyNodeId(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.sticky = enabled
+	}
+}
+
+// newOption 应用可选配置
+func newOption(opts ...OptionFn) *Option {
+	op := &Option{
+		timeUnit:     TimeUnitMillis,
+		clock:        SystemClock,
+		metrics:      nopMetrics{},
+		cachePadding: true,
+	}
+	for _, opt := range opts {
+		opt(op)
+	}
+	if op.keySeparator == "" {
+		op.keySeparator = DefaultKeySeparator
+	}
+	if op.metrics == nil {
+		op.metrics = nopMetrics{}
+	}
+	if op.admissionLease <= 0 {
+		op.admissionLease = DefaultAdmissionLease
+	}
+	if op.lease > 0 && (op.leaseRenewInterval <= 0 || op.leaseRenewInterval >= op.lease) {
+		op.leaseRenewInterval = op.lease / 3
+	}
+	if op.degradedThreshold < 1 {
+		op.degradedThreshold = DefaultDegradedThreshold
+	}
+	return op
+}
@@ -0,0 +1,54 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 分配结果
+package gorm
+
+import "time"
+
+// AllocOutcome 节点ID分配结果
+type AllocOutcome int32
+
+const (
+	// AllocOutcomeNone 尚未分配
+	AllocOutcomeNone AllocOutcome = iota
+	// AllocOutcomeCreated 首次分配，新建节点ID记录
+	AllocOutcomeCreated
+	// AllocOutcomeReused 复用已有的节点ID记录
+	AllocOutcomeReused
+	// AllocOutcomeContention 记录超过抢占时间间隔未更新，抢占该节点ID
+	AllocOutcomeContention
+	// AllocOutcomeMigrated 节点ID发生漂移，记录移动到新的节点ID
+	AllocOutcomeMigrated
+)
+
+// String 分配结果名称
+func (o AllocOutcome) String() string {
+	switch o {
+	case AllocOutcomeCreated:
+		return "created"
+	case AllocOutcomeReused:
+		return "reused"
+	case AllocOutcomeContention:
+		return "contention"
+	case AllocOutcomeMigrated:
+		return "migrated"
+	default:
+		return "none"
+	}
+}
+
+// AllocResult 一次节点ID分配的结果
+type AllocResult struct {
+	// NodeID 分配的节点ID
+	NodeID int64
+	// Outcome 分配结果
+	Outcome AllocOutcome
+	// StoredTime 分配后记录中保存的时间，使用WithNodeIdStore时为零值
+	StoredTime time.Time
+	// PreviousNodeID 本次分配之前的节点ID，本进程已分配过时为上次分配的节点ID，否则为key此前持有的节点ID，不存在时为-1
+	PreviousNodeID int64
+}
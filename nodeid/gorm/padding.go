@@ -0,0 +1,31 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 缓存行填充
+package gorm
+
+import "go.uber.org/atomic"
+
+// paddedInt64 独占整个缓存行的atomic.Int64
+type paddedInt64 struct {
+	// 填充前缀，避免与前面的内存发生伪共享
+	_pad0 [56]byte
+
+	atomic.Int64
+
+	// 填充后缀，防止后面的内存干扰
+	_pad1 [56]byte
+}
+
+// newTimeCell 创建时间同步器的内存时间
+// @param padded 是否填充到独占整个缓存行
+// @return *atomic.Int64
+func newTimeCell(padded bool) *atomic.Int64 {
+	if padded {
+		return &new(paddedInt64).Int64
+	}
+	return new(atomic.Int64)
+}
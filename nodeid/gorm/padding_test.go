@@ -0,0 +1,68 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 缓存行填充测试
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithCachePadding 测试填充与不填充的时间同步器Async与Run的行为一致
+func TestWithCachePadding(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	created := time.Now()
+	now := created.UnixMilli()
+	saved := make(map[bool]int64)
+	for i, padded := range []bool{true, false} {
+		synchronizer := NewTimeSynchronizer(ctx, db, fmt.Sprintf("padding-%t", padded), testPort,
+			10*time.Millisecond, logger, WithCachePadding(padded))
+		require.NoError(t, db.Create(&model.SnowflakeKv{
+			Key: synchronizer.nodeIdKey, NodeID: int64(i), Time: 1, Created: &created, Updated: created,
+		}).Error)
+		// 10ms阈值内的时间不覆盖内存时间
+		synchronizer.Async(now)
+		synchronizer.Async(now + 5)
+		assert.Equal(t, now, synchronizer.curr.Load())
+		synchronizer.Async(now + 20)
+		assert.Equal(t, now+20, synchronizer.curr.Load())
+
+		synchronizer.Run()
+		var row model.SnowflakeKv
+		require.Eventually(t, func() bool {
+			return db.Where("key = ?", synchronizer.nodeIdKey).Take(&row).Error == nil && row.Time > 1
+		}, time.Second, 5*time.Millisecond)
+		synchronizer.Stop()
+		saved[padded] = row.Time
+	}
+	assert.Equal(t, now+20, saved[true])
+	assert.Equal(t, saved[true], saved[false])
+}
+
+// BenchmarkNewTimeSynchronizer 比较填充与不填充时每个时间同步器占用的内存
+func BenchmarkNewTimeSynchronizer(b *testing.B) {
+	db := testDB(b)
+	for _, padded := range []bool{true, false} {
+		b.Run(fmt.Sprintf("padded=%t", padded), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				synchronizer := NewTimeSynchronizer(context.Background(), db, testName, testPort, time.Second, logger,
+					WithCachePadding(padded))
+				synchronizer.ticker.Stop()
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 按部署类型划分节点ID范围
+package gorm
+
+import (
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+)
+
+// DeployTypePartitions 部署类型到节点ID范围的映射
+type DeployTypePartitions map[DeployType]nodeid.NodeIdRange
+
+// DefaultDeployTypePartitions 默认划分（默认的10位节点ID）：k8s 0-511，docker 512-767，physical 768-1023
+// 未列出的部署类型使用physical的范围
+var DefaultDeployTypePartitions = DeployTypePartitions{
+	K8s:      {Min: 0, Max: nodeid.MaxNodes/2 - 1},
+	Docker:   {Min: nodeid.MaxNodes / 2, Max: nodeid.MaxNodes*3/4 - 1},
+	Physical: {Min: nodeid.MaxNodes * 3 / 4, Max: nodeid.MaxNodes - 1},
+}
+
+// rangeOf 获取部署类型对应的节点ID范围，未配置时使用physical的范围，K8sDocker未配置时优先使用k8s的范围
+// @param deployType
+// @return nodeid.NodeIdRange
+// @return bool 是否存在对应的范围
+func (p DeployTypePartitions) rangeOf(deployType DeployType) (nodeid.NodeIdRange, bool) {
+	if nodeRange, ok := p[deployType]; ok {
+		return nodeRange, true
+	}
+	if nodeRange, ok := p[K8s]; ok && deployType == K8sDocker {
+		return nodeRange, true
+	}
+	nodeRange, ok := p[Physical]
+	return nodeRange, ok
+}
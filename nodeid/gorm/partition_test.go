@@ -0,0 +1,103 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 按部署类型划分节点ID范围测试
+package gorm
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeployTypePartitions 测试各部署类型分配的节点ID落在对应范围内
+func TestDeployTypePartitions(t *testing.T) {
+	cases := []struct {
+		deployType DeployType
+		setup      func(t *testing.T) func()
+	}{
+		{K8s, func(t *testing.T) func() {
+			os.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+			return stubDeployFiles(t, "", "")
+		}},
+		{Docker, func(t *testing.T) func() { return stubDeployFiles(t, "docker", "") }},
+		{Physical, func(t *testing.T) func() { return stubDeployFiles(t, "", "") }},
+		// 未配置范围的部署类型使用physical的范围
+		{Nspawn, func(t *testing.T) func() { return stubDeployFiles(t, "", "systemd-nspawn") }},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.deployType), func(t *testing.T) {
+			defer unsetDeployEnv()()
+			defer c.setup(t)()
+			require.Equal(t, c.deployType, GetDeployType())
+
+			expected, _ := DefaultDeployTypePartitions.rangeOf(c.deployType)
+			db := testDB(t)
+			for port := 8000; port < 8020; port++ {
+				allocator := NewNodeIdAllocator(context.Background(), db, testName, port, time.Second, 5*time.Second, logger,
+					WithDeployTypePartitions(DefaultDeployTypePartitions))
+				nodeId, err := allocator.Alloc()
+				require.NoError(t, err)
+				assert.True(t, expected.Contains(nodeId), "node id %d", nodeId)
+
+				migrated, err := allocator.NodeIdAllocator.Migration(nodeId)
+				require.NoError(t, err)
+				assert.True(t, expected.Contains(migrated), "migrated node id %d", migrated)
+			}
+		})
+	}
+}
+
+// TestDeployTypePartitions_Disabled 测试未开启时使用完整的节点ID空间
+func TestDeployTypePartitions_Disabled(t *testing.T) {
+	_, ok := DeployTypePartitions(nil).rangeOf(K8s)
+	assert.False(t, ok)
+
+	nodeRange, ok := DeployTypePartitions{K8s: {Min: 0, Max: 99}}.rangeOf(Docker)
+	assert.False(t, ok)
+	assert.Zero(t, nodeRange)
+}
+
+// TestNodeIdRange 测试限定的节点ID范围优先于按部署类型划分
+func TestNodeIdRange(t *testing.T) {
+	nodeRange := nodeid.NodeIdRange{Min: 640, Max: 767}
+	db := testDB(t)
+	for port := 8000; port < 8020; port++ {
+		allocator := NewNodeIdAllocator(context.Background(), db, testName, port, time.Second, 5*time.Second, logger,
+			WithDeployTypePartitions(DefaultDeployTypePartitions), WithNodeIdRange(nodeRange))
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		assert.True(t, nodeRange.Contains(nodeId), "node id %d", nodeId)
+
+		migrated, err := allocator.Migration(nodeId)
+		require.NoError(t, err)
+		assert.True(t, nodeRange.Contains(migrated), "migrated node id %d", migrated)
+	}
+}
+
+// TestWithReservedNodeIds 测试分配与冲突探测均跳过保留的节点ID
+func TestWithReservedNodeIds(t *testing.T) {
+	reserved := make([]int64, 0, 16)
+	for nodeId := int64(0); nodeId < 16; nodeId++ {
+		reserved = append(reserved, nodeId)
+	}
+	// 限定在较小的范围内，使冲突探测频繁发生
+	nodeRange := nodeid.NodeIdRange{Min: 0, Max: 255}
+	db := testDB(t)
+	for port := 8000; port < 8030; port++ {
+		allocator := NewNodeIdAllocator(context.Background(), db, testName, port, time.Second, 5*time.Second, logger,
+			WithNodeIdRange(nodeRange), WithReservedNodeIds(reserved))
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		assert.True(t, nodeId >= 16 && nodeRange.Contains(nodeId), "node id %d", nodeId)
+	}
+}
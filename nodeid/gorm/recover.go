@@ -0,0 +1,44 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 同步goroutine的panic恢复
+package gorm
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// recoverSync 恢复时间同步器写入时的panic并记录日志，需以defer直接调用
+// 同步goroutine因panic退出后时钟回拨保护即失效，恢复后等待下一次tick继续写入；日志记录器本身panic时输出到标准输出
+// @param logger
+// @param name 时间同步器名称
+func recoverSync(logger Logger, name string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	defer func() {
+		if again := recover(); again != nil {
+			fmt.Println(fmt.Sprintf("%s panicked and the logger panicked too, continuing on the next tick. panic: %v, "+
+				"logger panic: %v\n%s", name, r, again, stack))
+		}
+	}()
+	logger.Errorf("%s panicked, continuing on the next tick. panic: %v\n%s", name, r, stack)
+}
+
+// safeUpdateDB 同步时间到数据库，恢复其中的panic
+func (m *TimeSynchronizer) safeUpdateDB() {
+	defer recoverSync(m.logger, "time synchronizer")
+	m.updateDB()
+}
+
+// safeUpdateDB 批量同步时间到数据库，恢复其中的panic
+func (m *MultiTimeSynchronizer) safeUpdateDB() {
+	defer recoverSync(m.logger, "multi time synchronizer")
+	m.updateDB()
+}
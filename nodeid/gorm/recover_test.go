@@ -0,0 +1,74 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 同步goroutine的panic恢复测试
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// panickingLogger Errorf时panic的日志记录器
+type panickingLogger struct {
+	DefaultLogger
+	panics atomic.Int64
+}
+
+// Errorf 记录次数后panic
+func (l *panickingLogger) Errorf(format string, args ...interface{}) {
+	l.panics.Inc()
+	panic("logger panicked")
+}
+
+// TestTimeSynchronizer_RecoverPanic 测试写入时panic后同步goroutine继续运行
+func TestTimeSynchronizer_RecoverPanic(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	panicking := &panickingLogger{}
+	synchronizer := NewTimeSynchronizer(ctx, db, testName, testPort, 10*time.Millisecond, panicking)
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+
+	// 删除表后每次写入失败，Errorf panic
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	synchronizer.Async(time.Now().UnixMilli())
+	synchronizer.Run()
+	defer synchronizer.Stop()
+	require.Eventually(t, func() bool { return panicking.panics.Load() >= 3 }, time.Second, 5*time.Millisecond)
+
+	// 恢复表后继续写入
+	require.NoError(t, db.AutoMigrate(&model.SnowflakeKv{}))
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	synchronizer.Async(time.Now().Add(time.Second).UnixMilli())
+	require.Eventually(t, func() bool { return !synchronizer.Watermark().IsZero() }, time.Second, 5*time.Millisecond)
+}
+
+// TestMultiTimeSynchronizer_RecoverPanic 测试批量写入时panic后同步goroutine继续运行
+func TestMultiTimeSynchronizer_RecoverPanic(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	panicking := &panickingLogger{}
+	synchronizer := NewMultiTimeSynchronizer(ctx, db, 10*time.Millisecond, panicking)
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	synchronizer.Register("tenant-a").Async(time.Now().UnixMilli())
+	synchronizer.Run()
+
+	require.Eventually(t, func() bool { return panicking.panics.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, panicking.panics.Load(), int64(3))
+}
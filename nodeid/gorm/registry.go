@@ -0,0 +1,107 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 进程内节点ID Key注册表
+package gorm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// activeKeys 进程内活跃的节点ID Key及其分配器
+// 同一进程中以相同的名称与端口创建两个雪花算法时，二者的节点ID相同，生成的ID必然重复
+var activeKeys = struct {
+	sync.Mutex
+	holders map[string]*NodeIdAllocator
+}{holders: make(map[string]*NodeIdAllocator)}
+
+// allocLocks 进程内按节点ID Key加锁，同一key的分配串行执行
+// 同一进程中误以相同的key创建多个分配器并发Alloc时，避免它们交错读写同一条记录
+var allocLocks = struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: make(map[string]*sync.Mutex)}
+
+// lockKey 获取节点ID Key的分配锁
+// @return func() 释放锁
+func lockKey(nodeIdKey string) func() {
+	allocLocks.Lock()
+	lock, ok := allocLocks.locks[nodeIdKey]
+	if !ok {
+		lock = &sync.Mutex{}
+		allocLocks.locks[nodeIdKey] = lock
+	}
+	allocLocks.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// ownWrites 进程内分配器与时间同步器最近一次写入各节点ID Key记录的更新时间
+// 时间同步器据此区分本进程的写入与其他实例的写入
+var ownWrites = struct {
+	sync.Mutex
+	updated map[string]time.Time
+}{updated: make(map[string]time.Time)}
+
+// recordOwnWrite 记录本进程写入节点ID Key记录的更新时间
+func recordOwnWrite(nodeIdKey string, updated time.Time) {
+	ownWrites.Lock()
+	defer ownWrites.Unlock()
+
+	if updated.After(ownWrites.updated[nodeIdKey]) {
+		ownWrites.updated[nodeIdKey] = updated
+	}
+}
+
+// lastOwnWrite 本进程最近一次写入节点ID Key记录的更新时间，未写入过时返回零值
+func lastOwnWrite(nodeIdKey string) time.Time {
+	ownWrites.Lock()
+	defer ownWrites.Unlock()
+
+	return ownWrites.updated[nodeIdKey]
+}
+
+// checkActive 检查节点ID Key是否已被进程内其他活跃的分配器持有
+// 严格模式下返回ErrKeyInUse，否则输出错误日志
+// @param logger
+// @return error
+func (m *NodeIdAllocator) checkActive(logger Logger) error {
+	activeKeys.Lock()
+	defer activeKeys.Unlock()
+
+	holder, ok := activeKeys.holders[m.nodeIdKey]
+	if !ok || holder == m || holder.ctx.Err() != nil {
+		return nil
+	}
+	if m.strictUniqueness {
+		return fmt.Errorf("%w. key: %s", ErrKeyInUse, m.nodeIdKey)
+	}
+	logger.Errorf("node id key is already active in this process, generated ids will collide!!! key: %s",
+		m.nodeIdKey)
+	return nil
+}
+
+// markActive 将当前分配器登记为节点ID Key的持有者
+func (m *NodeIdAllocator) markActive() {
+	activeKeys.Lock()
+	defer activeKeys.Unlock()
+
+	activeKeys.holders[m.nodeIdKey] = m
+}
+
+// Release 从进程内注册表中注销当前分配器，之后以相同身份创建的分配器不再告警
+// 分配器的context结束后同样视为已注销
+func (m *NodeIdAllocator) Release() {
+	activeKeys.Lock()
+	defer activeKeys.Unlock()
+
+	if activeKeys.holders[m.nodeIdKey] == m {
+		delete(activeKeys.holders, m.nodeIdKey)
+	}
+}
@@ -0,0 +1,157 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 进程内节点ID Key注册表测试
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordLogger 记录格式化日志的日志记录器
+type recordLogger struct {
+	DefaultLogger
+	mu   sync.Mutex
+	logs []string
+}
+
+func (r *recordLogger) record(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordLogger) Infof(format string, args ...interface{}) {
+	r.record(format, args...)
+}
+
+func (r *recordLogger) Warnf(format string, args ...interface{}) {
+	r.record(format, args...)
+}
+
+func (r *recordLogger) Errorf(format string, args ...interface{}) {
+	r.record(format, args...)
+}
+
+// contains 是否记录过包含指定内容的日志
+func (r *recordLogger) contains(substr string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range r.logs {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestNodeIdAllocator_SameKeyInProcess 测试同一进程中以相同身份创建两个分配器
+func TestNodeIdAllocator_SameKeyInProcess(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	defer first.Release()
+	firstId, err := first.Alloc()
+	require.NoError(t, err)
+
+	// 第二个分配器输出告警
+	recorder := &recordLogger{}
+	second := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, recorder)
+	secondId, err := second.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, firstId, secondId)
+	assert.True(t, recorder.contains("already active in this process"))
+
+	// 严格模式下报错
+	strict := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStrictUniqueness(true))
+	_, err = strict.Alloc()
+	assert.True(t, errors.Is(err, ErrKeyInUse))
+
+	// 注销后不再告警
+	second.Release()
+	recorder = &recordLogger{}
+	third := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, recorder)
+	defer third.Release()
+	_, err = third.Alloc()
+	require.NoError(t, err)
+	assert.False(t, recorder.contains("already active in this process"))
+}
+
+// TestNodeIdAllocator_SameKeyInProcess_ContextDone 测试持有者的context结束后视为已注销
+func TestNodeIdAllocator_SameKeyInProcess_ContextDone(t *testing.T) {
+	db := testDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger)
+	_, err := first.Alloc()
+	require.NoError(t, err)
+	cancel()
+
+	strict := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithStrictUniqueness(true))
+	defer strict.Release()
+	_, err = strict.Alloc()
+	assert.NoError(t, err)
+}
+
+// TestNodeIdAllocator_SameKeyConcurrentAlloc 测试进程内以相同key创建的两个分配器并发Alloc时串行执行，结果一致
+func TestNodeIdAllocator_SameKeyConcurrentAlloc(t *testing.T) {
+	db := testDB(t)
+	peak := trackInFlight(t, db)
+	ctx := context.Background()
+	allocators := []*NodeIdAllocator{
+		NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger),
+		NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger),
+	}
+
+	const rounds = 5
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		nodeIds = make([][]int64, len(allocators))
+		errs    = make([][]error, len(allocators))
+	)
+	for i, allocator := range allocators {
+		nodeIds[i], errs[i] = make([]int64, rounds), make([]error, rounds)
+		for j := 0; j < rounds; j++ {
+			wg.Add(1)
+			go func(allocator *NodeIdAllocator, i, j int) {
+				defer wg.Done()
+				<-start
+				nodeIds[i][j], errs[i][j] = allocator.Alloc()
+			}(allocator, i, j)
+		}
+	}
+	close(start)
+	wg.Wait()
+
+	expected := nodeIds[0][0]
+	for i := range allocators {
+		for j := 0; j < rounds; j++ {
+			require.NoError(t, errs[i][j])
+			assert.Equal(t, expected, nodeIds[i][j])
+		}
+	}
+	// 同一时刻最多一条snowflake_kv语句
+	assert.Equal(t, int64(1), peak.Load())
+
+	tab := allocators[0].dao.SnowflakeKv
+	rows, err := tab.WithContext(ctx).Where(tab.Key.Eq(allocators[0].nodeIdKey)).Find()
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, expected, rows[0].NodeID)
+}
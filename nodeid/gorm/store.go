@@ -0,0 +1,38 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 外部持久化
+package gorm
+
+// NodeIdStore 节点ID的外部持久化，如Consul KV、本地文件
+// 设置后分配器不再读写snowflake_kv，而是从Store中加载已选定的节点ID，不存在时按哈希分配并写入Store
+// 注意：外部持久化不具备数据库记录的冲突检测、时钟回拨检测与抢占能力，节点ID的唯一性由调用方保证
+type NodeIdStore interface {
+	// Load 加载已持久化的节点ID，不存在时返回false
+	Load() (int64, bool)
+	// Store 持久化选定的节点ID
+	Store(nodeId int64) error
+}
+
+// allocFromStore 从外部持久化分配节点ID，PreviousNodeID为此前持久化的节点ID，不存在时为-1
+// 不读写数据库，StoredTime为零值
+// @return AllocResult
+// @return error
+func (m *NodeIdAllocator) allocFromStore(logger Logger) (AllocResult, error) {
+	if nodeId, ok := m.store.Load(); ok {
+		return AllocResult{NodeID: nodeId, Outcome: AllocOutcomeReused, PreviousNodeID: nodeId}, nil
+	}
+
+	nodeId, err := m.NodeIdAllocator.Alloc()
+	if err != nil {
+		return AllocResult{}, err
+	}
+	if err = m.store.Store(nodeId); err != nil {
+		return AllocResult{}, err
+	}
+	logger.Infof("node id stored externally. key: %s, node id: %d", m.nodeIdKey, nodeId)
+	return AllocResult{NodeID: nodeId, Outcome: AllocOutcomeCreated, PreviousNodeID: -1}, nil
+}
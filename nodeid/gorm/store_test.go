@@ -0,0 +1,74 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 外部持久化测试
+package gorm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore 内存中的节点ID持久化
+type memoryStore struct {
+	mu     sync.Mutex
+	nodeId int64
+	stored bool
+	loads  int
+	stores int
+}
+
+func (s *memoryStore) Load() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loads++
+	return s.nodeId, s.stored
+}
+
+func (s *memoryStore) Store(nodeId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stores++
+	s.nodeId, s.stored = nodeId, true
+	return nil
+}
+
+// TestNodeIdAllocator_NodeIdStore 测试外部持久化的节点ID在共享store的分配器之间保持不变，且不写入数据库
+func TestNodeIdAllocator_NodeIdStore(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	store := &memoryStore{}
+
+	allocator := NewNodeIdAllocator(ctx, db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithNodeIdStore(store))
+	nodeId, err := allocator.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, AllocOutcomeCreated, allocator.LastOutcome())
+	assert.Equal(t, 1, store.loads)
+	assert.Equal(t, 1, store.stores)
+	require.NoError(t, allocator.Close())
+
+	restarted := NewNodeIdAllocator(ctx, db, "another", 9090, time.Second, 5*time.Second, logger,
+		WithNodeIdStore(store))
+	restartedId, err := restarted.Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, nodeId, restartedId)
+	assert.Equal(t, AllocOutcomeReused, restarted.LastOutcome())
+	assert.Equal(t, 2, store.loads)
+	assert.Equal(t, 1, store.stores)
+
+	var count int64
+	require.NoError(t, db.Model(&model.SnowflakeKv{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
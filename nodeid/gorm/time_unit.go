@@ -0,0 +1,124 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 时间单位
+package gorm
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeUnit 持久化时间戳的单位
+type TimeUnit int
+
+const (
+	// TimeUnitMillis 毫秒，默认单位
+	TimeUnitMillis TimeUnit = iota
+	// TimeUnitSeconds 秒
+	TimeUnitSeconds
+)
+
+// String 时间单位名称
+func (u TimeUnit) String() string {
+	switch u {
+	case TimeUnitSeconds:
+		return "seconds"
+	default:
+		return "millis"
+	}
+}
+
+// From 将时间转换为当前单位的时间戳
+// @param t
+// @return int64
+func (u TimeUnit) From(t time.Time) int64 {
+	return u.FromMilli(t.UnixMilli())
+}
+
+// FromMilli 将毫秒时间戳转换为当前单位的时间戳，秒向下取整
+// @param milli
+// @return int64
+func (u TimeUnit) FromMilli(milli int64) int64 {
+	switch u {
+	case TimeUnitSeconds:
+		return milli / 1000
+	default:
+		return milli
+	}
+}
+
+// Duration 将时长转换为当前单位的数值
+// @param d
+// @return int64
+func (u TimeUnit) Duration(d time.Duration) int64 {
+	switch u {
+	case TimeUnitSeconds:
+		return int64(d / time.Second)
+	default:
+		return d.Milliseconds()
+	}
+}
+
+// Time 将当前单位的时间戳转换为时间
+// @param v
+// @return time.Time
+func (u TimeUnit) Time(v int64) time.Time {
+	switch u {
+	case TimeUnitSeconds:
+		return time.Unix(v, 0)
+	default:
+		return time.UnixMilli(v)
+	}
+}
+
+// timeUnitMismatchThreshold 保存的时间按配置的单位解读后与当前时间相差超过该值，
+// 而按另一个单位解读时在该值以内，视为写入方使用了不同的单位
+const timeUnitMismatchThreshold = 365 * 24 * time.Hour
+
+// timeUnits 所有支持的时间单位
+var timeUnits = []TimeUnit{TimeUnitMillis, TimeUnitSeconds}
+
+// mismatch 判断保存的时间是否更像以另一个单位写入
+// 按配置的单位解读明显不合理（如毫秒配置读到秒级时间戳会落在1970年）时，返回与当前时间最接近的单位
+// @param saved 保存的时间
+// @param now 当前时间
+// @return TimeUnit 更可能的单位
+// @return bool 是否不匹配
+func (u TimeUnit) mismatch(saved int64, now time.Time) (TimeUnit, bool) {
+	if distance(u.Time(saved), now) <= timeUnitMismatchThreshold {
+		return u, false
+	}
+	for _, other := range timeUnits {
+		if other != u && distance(other.Time(saved), now) <= timeUnitMismatchThreshold {
+			return other, true
+		}
+	}
+	return u, false
+}
+
+// distance 两个时间之间的时长，不区分先后
+func distance(a, b time.Time) time.Duration {
+	if d := a.Sub(b); d > 0 {
+		return d
+	}
+	return b.Sub(a)
+}
+
+// ParseTimeUnit 解析时间单位名称，空字符串为默认的毫秒
+// @param name millis/seconds
+// @return TimeUnit
+// @return error
+func ParseTimeUnit(name string) (TimeUnit, error) {
+	switch name {
+	case "", "millis", "ms":
+		return TimeUnitMillis, nil
+	case "seconds", "s":
+		return TimeUnitSeconds, nil
+	default:
+		return TimeUnitMillis, fmt.Errorf("unknown time unit %q", name)
+	}
+}
@@ -0,0 +1,98 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 时间单位测试
+package gorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTimeUnit_Default 测试默认时间单位为毫秒
+func TestTimeUnit_Default(t *testing.T) {
+	assert.Equal(t, TimeUnitMillis, newOption().timeUnit)
+	assert.Equal(t, TimeUnitSeconds, newOption(WithTimeUnit(TimeUnitSeconds)).timeUnit)
+}
+
+// TestTimeUnit_Convert 测试时间单位转换
+func TestTimeUnit_Convert(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+
+	assert.Equal(t, int64(1700000000123), TimeUnitMillis.From(now))
+	assert.Equal(t, int64(1700000000), TimeUnitSeconds.From(now))
+
+	assert.Equal(t, int64(1500), TimeUnitMillis.Duration(1500*time.Millisecond))
+	assert.Equal(t, int64(1), TimeUnitSeconds.Duration(1500*time.Millisecond))
+
+	assert.Equal(t, now, TimeUnitMillis.Time(TimeUnitMillis.From(now)))
+	assert.Equal(t, time.Unix(1700000000, 0), TimeUnitSeconds.Time(TimeUnitSeconds.From(now)))
+}
+
+// TestParseTimeUnit 测试解析时间单位名称
+func TestParseTimeUnit(t *testing.T) {
+	for name, expected := range map[string]TimeUnit{
+		"": TimeUnitMillis, "millis": TimeUnitMillis, "ms": TimeUnitMillis,
+		"seconds": TimeUnitSeconds, "s": TimeUnitSeconds,
+	} {
+		unit, err := ParseTimeUnit(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, unit)
+	}
+
+	_, err := ParseTimeUnit("minutes")
+	assert.Error(t, err)
+}
+
+// TestTimeUnit_Mismatch 测试识别以另一个单位写入的时间
+func TestTimeUnit_Mismatch(t *testing.T) {
+	now := time.Now()
+	unit, mismatch := TimeUnitMillis.mismatch(TimeUnitSeconds.From(now), now)
+	assert.True(t, mismatch)
+	assert.Equal(t, TimeUnitSeconds, unit)
+
+	unit, mismatch = TimeUnitSeconds.mismatch(TimeUnitMillis.From(now), now)
+	assert.True(t, mismatch)
+	assert.Equal(t, TimeUnitMillis, unit)
+
+	// 单位一致，或按任何单位解读都不合理时不告警
+	_, mismatch = TimeUnitMillis.mismatch(TimeUnitMillis.From(now.Add(-time.Hour)), now)
+	assert.False(t, mismatch)
+	_, mismatch = TimeUnitMillis.mismatch(1, now)
+	assert.False(t, mismatch)
+}
+
+// TestNodeIdAllocator_TimeUnitMismatch 测试毫秒配置的分配器读到秒级时间时输出告警
+func TestNodeIdAllocator_TimeUnitMismatch(t *testing.T) {
+	db := testDB(t)
+	recorder := &recordLogger{}
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second,
+		recorder)
+	nodeId, err := nodeid.NewHashNodeIdAllocator(allocator.nodeIdKey).Alloc()
+	require.NoError(t, err)
+
+	// 另一个配置为秒的实例写入的记录
+	now := time.Now()
+	require.NoError(t, db.Create(&model.SnowflakeKv{
+		Key: allocator.nodeIdKey, NodeID: nodeId, Time: TimeUnitSeconds.From(now), Created: &now, Updated: now,
+	}).Error)
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	assert.True(t, recorder.contains("saved time looks like seconds but the time unit is millis"))
+
+	// 记录已按毫秒写入，再次分配不再告警
+	recorder = &recordLogger{}
+	allocator.logger = recorder
+	_, err = allocator.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, recorder.contains("saved time looks like"))
+}
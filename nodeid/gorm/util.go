@@ -11,111 +11,552 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type DeployType string
 
 const (
 	K8s      DeployType = "k8s"
+	Nomad    DeployType = "nomad"
 	Docker   DeployType = "docker"
+	Nspawn   DeployType = "nspawn"
 	Physical DeployType = "physical"
+	// K8sDocker 同时存在Kubernetes与Docker信号，仅在DeployTypePrecedenceCombined时返回
+	K8sDocker DeployType = "k8s-docker"
 )
 
+// DeployTypePrecedence Kubernetes与Docker信号同时存在时的部署类型判断规则
+// 使用Docker作为容器运行时的Pod同时设置了KUBERNETES_SERVICE_HOST并存在/.dockerenv
+type DeployTypePrecedence int
+
+const (
+	// DeployTypePrecedenceK8s 默认，与历史行为一致，识别为K8s
+	DeployTypePrecedenceK8s DeployTypePrecedence = iota
+	// DeployTypePrecedenceDocker 识别为Docker
+	DeployTypePrecedenceDocker
+	// DeployTypePrecedenceCombined 识别为K8sDocker，便于节点ID Key区分编排层
+	DeployTypePrecedenceCombined
+)
+
+var (
+	// dockerEnvPath Docker容器内的标识文件
+	dockerEnvPath = "/.dockerenv"
+	// systemdContainerPath systemd容器管理器写入的容器类型文件
+	systemdContainerPath = "/run/systemd/container"
+	// podIPPollInterval 等待Pod IP文件时的轮询间隔
+	podIPPollInterval = 100 * time.Millisecond
+	// ipCacheTTL 网卡扫描结果的缓存时间
+	ipCacheTTL = 30 * time.Second
+	// scanInterfaces 扫描网卡地址，测试中可替换
+	scanInterfaces = scanInterfaceAddrs
+)
+
+// ipCache 网卡扫描结果缓存
+var ipCache struct {
+	sync.Mutex
+	interfaces []interfaceAddrs
+	expires    time.Time
+}
+
+// AddressFamily 节点ID Key使用的IP地址族
+type AddressFamily int
+
+const (
+	// AddressFamilyAuto 默认，与历史行为一致：POD_IP不限地址族，网卡扫描只选择IPv4
+	AddressFamilyAuto AddressFamily = iota
+	// AddressFamilyIPv4 只选择IPv4地址
+	AddressFamilyIPv4
+	// AddressFamilyIPv6 只选择IPv6地址
+	AddressFamilyIPv6
+)
+
+// IPPrecedence POD_IP与网卡扫描得到的IP均有效且不一致时的选择规则
+// Docker容器内网卡扫描通常得到网桥地址（如172.17.x.x），而POD_IP往往被设置为宿主机可路由的地址
+type IPPrecedence int
+
+const (
+	// IPPrecedenceEnv 默认，与历史行为一致，使用POD_IP
+	IPPrecedenceEnv IPPrecedence = iota
+	// IPPrecedenceInterface 使用网卡扫描得到的IP
+	IPPrecedenceInterface
+	// IPPrecedenceAgreement 要求两者一致，不一致时返回ErrIPMismatch
+	IPPrecedenceAgreement
+)
+
+// matches 网卡地址是否属于当前地址族，Auto只选择IPv4
+// IPv6链路本地地址每块网卡都有且不可路由，不作为身份使用
+func (f AddressFamily) matches(ip net.IP) bool {
+	switch f {
+	case AddressFamilyIPv6:
+		return ip.To4() == nil && ip.To16() != nil && !ip.IsLinkLocalUnicast()
+	default:
+		return ip.To4() != nil
+	}
+}
+
 func (d DeployType) Is(typ DeployType) bool {
 	return d == typ
 }
 
+// GetNodeIdKey 生成节点ID Key，格式为{name}_{ip}_{port}_{deployType}，可通过ParseNodeIdKey解析
+// 不校验端口与分隔符，非正数端口会原样拼接到key中，建议使用NewNodeIdKey
 func GetNodeIdKey(name string, port int) string {
-	return fmt.Sprintf("%s_%s_%d_%s", name, GetIP(), port, GetDeployType())
+	return formatNodeIdKey(name, GetIP(), port, GetDeployType(), DefaultKeySeparator)
+}
+
+// NewNodeIdKey 校验端口与分隔符后生成节点ID Key，格式为{name}_{ip}_{port}_{deployType}
+// @param name 可以包含分隔符
+// @param port 1-65535
+// @return string
+// @return error 端口无效时返回包装了ErrInvalidPort的错误，IP或部署类型包含分隔符时返回包装了ErrInvalidNodeIdKey的错误
+func NewNodeIdKey(name string, port int) (string, error) {
+	if err := ValidatePort(port); err != nil {
+		return "", err
+	}
+	ip, deployType := GetIP(), GetDeployType()
+	key := formatNodeIdKey(name, ip, port, deployType, DefaultKeySeparator)
+	if err := checkNodeIdKey(key, name, ip, port, deployType, DefaultKeySeparator); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ValidatePort 校验用于节点ID Key的端口
+// @param port
+// @return error 端口不在1-65535范围内时返回包装了ErrInvalidPort的错误
+func ValidatePort(port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("%w: %d", ErrInvalidPort, port)
+	}
+	return nil
+}
+
+// DefaultKeySeparator 节点ID Key各部分之间的默认分隔符
+const DefaultKeySeparator = "_"
+
+// formatNodeIdKey 使用分隔符拼接节点ID Key
+func formatNodeIdKey(name, ip string, port int, deployType DeployType, separator string) string {
+	return strings.Join([]string{name, ip, strconv.Itoa(port), string(deployType)}, separator)
+}
+
+// keyIdentity 节点ID Key中标识实例的部分，标识文件存在且内容非空时使用文件内容，否则使用ip
+// @param identityFile 为空表示不读取文件
+// @param ip
+// @return string
+func keyIdentity(identityFile, ip string) string {
+	if identityFile == "" {
+		return ip
+	}
+	content, err := os.ReadFile(identityFile)
+	if err != nil {
+		return ip
+	}
+	if identity := strings.TrimSpace(string(content)); identity != "" {
+		return identity
+	}
+	return ip
+}
+
+// ParseNodeIdKey 将使用默认分隔符生成的节点ID Key解析为各部分，与GetNodeIdKey互逆
+// @param key
+// @return name 服务名称，可以包含分隔符
+// @return ip
+// @return port
+// @return deployType
+// @return error key的格式无效时返回包装了ErrInvalidNodeIdKey的错误
+func ParseNodeIdKey(key string) (name, ip string, port int, deployType DeployType, err error) {
+	return ParseNodeIdKeyWithSeparator(key, DefaultKeySeparator)
+}
+
+// ParseNodeIdKeyWithSeparator 将使用指定分隔符生成的节点ID Key解析为各部分
+// IP、端口与部署类型中不会出现分隔符，因此从右向左依次拆分，剩余部分整体作为服务名称，服务名称中的分隔符无需转义
+// 端口必须是strconv.Itoa生成的规范写法（如拒绝"+8080"、"08080"），保证解析结果重新拼接后与key完全一致；
+// 任意输入均不会panic，无法解析时返回错误
+// @param key
+// @param separator 与生成key时使用的分隔符一致
+// @return name
+// @return ip
+// @return port
+// @return deployType
+// @return error
+func ParseNodeIdKeyWithSeparator(key, separator string) (name, ip string, port int, deployType DeployType, err error) {
+	if separator == "" {
+		return "", "", 0, "", fmt.Errorf("%w: empty separator", ErrInvalidNodeIdKey)
+	}
+	rest := key
+	parts := make([]string, 3)
+	for i := len(parts) - 1; i >= 0; i-- {
+		index := strings.LastIndex(rest, separator)
+		if index < 0 {
+			return "", "", 0, "", fmt.Errorf("%w: %q", ErrInvalidNodeIdKey, key)
+		}
+		parts[i], rest = rest[index+len(separator):], rest[:index]
+	}
+	port, err = strconv.Atoi(parts[1])
+	if err != nil || strconv.Itoa(port) != parts[1] {
+		return "", "", 0, "", fmt.Errorf("%w: %q has an invalid port", ErrInvalidNodeIdKey, key)
+	}
+	return rest, parts[0], port, DeployType(parts[2]), nil
+}
+
+// checkNodeIdKey 校验key能否被ParseNodeIdKeyWithSeparator解析回生成它的各部分
+// 只有服务名称可以包含分隔符，IP（或标识）、端口或部署类型包含分隔符（含与相邻分隔符部分重叠）时无法无歧义地拆分，
+// 不同实例的key可能解析为相同的各部分，因此拒绝而不是转义，已有的合法key保持不变
+// @return error 无法拆分时返回包装了ErrInvalidNodeIdKey的错误
+func checkNodeIdKey(key, name, ip string, port int, deployType DeployType, separator string) error {
+	gotName, gotIP, gotPort, gotDeployType, err := ParseNodeIdKeyWithSeparator(key, separator)
+	if err == nil && gotName == name && gotIP == ip && gotPort == port && gotDeployType == deployType {
+		return nil
+	}
+	return fmt.Errorf("%w: the identity, port or deploy type contains the separator %q. key: %s",
+		ErrInvalidNodeIdKey, separator, key)
 }
 
 // GetDeployType 获取部署类型
+// 依次检查Kubernetes、Nomad、Docker、systemd-nspawn，均未命中时为物理机；
+// Kubernetes与Docker信号同时存在时识别为K8s，可通过GetDeployTypeWithPrecedence调整
 func GetDeployType() DeployType {
+	return GetDeployTypeWithPrecedence(DeployTypePrecedenceK8s)
+}
+
+// GetDeployTypeWithPrecedence 按指定的规则获取部署类型
+// @param precedence Kubernetes与Docker信号同时存在时的判断规则
+// @return DeployType
+func GetDeployTypeWithPrecedence(precedence DeployTypePrecedence) DeployType {
+	deployType, _ := detectDeployType(precedence)
+	return deployType
+}
+
+// detectDeployType 按指定的规则检测部署类型
+// @param precedence Kubernetes与Docker信号同时存在时的判断规则
+// @return DeployType
+// @return bool 是否因未命中任何信号而回退为物理机
+func detectDeployType(precedence DeployTypePrecedence) (DeployType, bool) {
 	// 检查是否在Kubernetes环境中
-	if _, ok := os.LookupEnv("KUBERNETES_SERVICE_HOST"); ok {
-		return K8s
+	_, k8s := os.LookupEnv("KUBERNETES_SERVICE_HOST")
+	// 检查是否在Docker环境中
+	_, err := os.Stat(dockerEnvPath)
+	docker := err == nil
+
+	if k8s {
+		if docker {
+			switch precedence {
+			case DeployTypePrecedenceDocker:
+				return Docker, false
+			case DeployTypePrecedenceCombined:
+				return K8sDocker, false
+			}
+		}
+		return K8s, false
 	}
 
-	// 检查是否在Docker环境中
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return Docker
+	// 检查是否在Nomad环境中
+	if _, ok := os.LookupEnv("NOMAD_ALLOC_ID"); ok {
+		return Nomad, false
+	}
+
+	if docker {
+		return Docker, false
+	}
+
+	// 检查是否在systemd-nspawn环境中
+	if content, err := os.ReadFile(systemdContainerPath); err == nil &&
+		strings.TrimSpace(string(content)) == "systemd-nspawn" {
+		return Nspawn, false
 	}
 
 	// 默认返回物理机环境
-	return Physical
+	return Physical, true
+}
+
+// keyDeployType 获取节点ID Key使用的部署类型，设置了WithDeployType时直接使用，不再检测
+// @param op
+// @param notify 检测回退为物理机时是否调用WithPhysicalFallback设置的回调
+// @return DeployType
+func keyDeployType(op *Option, notify bool) DeployType {
+	if op.deployType != "" {
+		return op.deployType
+	}
+	deployType, fallback := detectDeployType(op.deployTypePrecedence)
+	if fallback && notify && op.physicalFallback != nil {
+		op.physicalFallback()
+	}
+	return deployType
+}
+
+// WaitForPodIP 等待Pod IP文件的内容变为有效地址后获取IP
+// 部分K8s环境在启动初期Pod IP为空或占位符，直接回退到网卡扫描可能选中错误的地址，导致节点ID Key不稳定；
+// 环境变量在进程启动后不会变化，因此只等待downward API卷等文件，超时后与GetIP一致回退到POD_IP与网卡扫描
+// @param path Pod IP文件路径
+// @param timeout 最长等待时间，<=0时只读取一次
+// @return string
+func WaitForPodIP(path string, timeout time.Duration) string {
+	if ip := awaitPodIPFile(path, timeout, AddressFamilyAuto); ip != "" {
+		return ip
+	}
+	return GetIP()
+}
+
+// awaitPodIPFile 等待文件的内容变为指定地址族的有效IP，最长等待timeout
+// @return string 未配置文件或超时时为空
+func awaitPodIPFile(path string, timeout time.Duration, family AddressFamily) string {
+	if path == "" {
+		return ""
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if ip := fileIP(path, family); ip != "" || !time.Now().Before(deadline) {
+			return ip
+		}
+		time.Sleep(podIPPollInterval)
+	}
+}
+
+// fileIP 文件中指定地址族的有效IP，文件不存在、内容无效或不属于指定地址族时返回空
+func fileIP(path string, family AddressFamily) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return validIP(strings.TrimSpace(string(content)), family)
+}
+
+// keyIP 等待Pod IP文件后按规则获取节点ID Key使用的IP，两者不一致时输出日志
+// @return string
+// @return error 要求一致而两者不一致时返回包装了ErrIPMismatch的错误
+func keyIP(op *Option, logger Logger) (string, error) {
+	awaitPodIPFile(op.podIPFile, op.podIPWait, op.addressFamily)
+	ip, mismatch := resolveIP(podIP(op.podIPFile, op.addressFamily), op.addressFamily, op.ipPrecedence)
+	if mismatch == nil {
+		return ip, nil
+	}
+	if op.ipPrecedence == IPPrecedenceAgreement {
+		logger.Errorf("POD_IP and the interface address disagree, alloc is refused until they agree. error: %v",
+			mismatch)
+		return ip, mismatch
+	}
+	logger.Warnf("POD_IP and the interface address disagree, using %s. error: %v", ip, mismatch)
+	return ip, nil
 }
 
 // GetIP 获取有效的网卡IP地址
+// 网卡扫描结果缓存ipCacheTTL，需要立即感知网卡变化时使用RefreshIP
 func GetIP() string {
+	return GetIPByFamily(AddressFamilyAuto)
+}
+
+// GetIPByFamily 获取指定地址族的有效IP地址
+// POD_IP不属于指定地址族时忽略，回退到网卡扫描
+// @param family
+// @return string
+func GetIPByFamily(family AddressFamily) string {
 	// 优先从环境变量获取
-	if podIP := os.Getenv("POD_IP"); podIP != "" {
-		if net.ParseIP(podIP) != nil {
-			return podIP
+	if podIP := envIP(family); podIP != "" {
+		return podIP
+	}
+	return interfaceIP(family)
+}
+
+// GetIPWithPrecedence 按指定的规则获取IP
+// POD_IP无效或不属于指定地址族时使用网卡扫描得到的IP，网卡扫描未得到IP时使用POD_IP，两者均有效且不一致时按规则选择
+// @param family
+// @param precedence
+// @return string 要求一致而两者不一致时返回POD_IP
+// @return error 要求一致而两者不一致时返回包装了ErrIPMismatch的错误
+func GetIPWithPrecedence(family AddressFamily, precedence IPPrecedence) (string, error) {
+	ip, mismatch := resolveIP(envIP(family), family, precedence)
+	if precedence != IPPrecedenceAgreement {
+		return ip, nil
+	}
+	return ip, mismatch
+}
+
+// resolveIP 按规则在Pod IP与网卡扫描得到的IP间选择
+// @param podIP POD_IP或Pod IP文件中的有效IP，为空表示未设置
+// @return ip 选中的IP，要求一致而两者不一致时为Pod IP
+// @return mismatch 两者均有效且不一致时返回包装了ErrIPMismatch的错误，与规则无关
+func resolveIP(podIP string, family AddressFamily, precedence IPPrecedence) (ip string, mismatch error) {
+	ifaceIP := interfaceIP(family)
+	switch {
+	case podIP == "":
+		return ifaceIP, nil
+	case ifaceIP == "" || podIP == ifaceIP:
+		return podIP, nil
+	}
+	mismatch = fmt.Errorf("%w. POD_IP: %s, interface: %s", ErrIPMismatch, podIP, ifaceIP)
+	if precedence == IPPrecedenceInterface {
+		return ifaceIP, mismatch
+	}
+	return podIP, mismatch
+}
+
+// podIP Pod IP文件中指定地址族的有效IP，未配置文件或文件内容无效时使用POD_IP
+func podIP(path string, family AddressFamily) string {
+	if path != "" {
+		if ip := fileIP(path, family); ip != "" {
+			return ip
 		}
 	}
+	return envIP(family)
+}
+
+// envIP POD_IP中指定地址族的有效IP，未设置、无效或不属于指定地址族时返回空
+func envIP(family AddressFamily) string {
+	return validIP(os.Getenv("POD_IP"), family)
+}
+
+// validIP value为指定地址族的有效IP时返回value，否则返回空
+func validIP(value string, family AddressFamily) string {
+	if ip := net.ParseIP(value); ip != nil && (family == AddressFamilyAuto || family.matches(ip)) {
+		return value
+	}
+	return ""
+}
+
+// interfaceIP 网卡扫描得到的指定地址族的IP，扫描结果缓存ipCacheTTL
+func interfaceIP(family AddressFamily) string {
+	ipCache.Lock()
+	defer ipCache.Unlock()
+	if ipCache.expires.IsZero() || time.Now().After(ipCache.expires) {
+		ipCache.interfaces = scanInterfaces()
+		ipCache.expires = time.Now().Add(ipCacheTTL)
+	}
+	return selectIP(ipCache.interfaces, family)
+}
+
+// RefreshIP 跳过缓存重新扫描网卡并获取IP
+// @return string
+func RefreshIP() string {
+	ipCache.Lock()
+	ipCache.expires = time.Time{}
+	ipCache.Unlock()
+	return GetIP()
+}
+
+// interfaceAddrs 网卡及其地址
+type interfaceAddrs struct {
+	flags net.Flags
+	addrs []net.IP
+}
 
+// scanInterfaceAddrs 扫描所有网卡的地址
+func scanInterfaceAddrs() []interfaceAddrs {
 	// 获取所有网络接口
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		fmt.Println("Error: Unable to get network interfaces:", err)
-		return ""
+		return nil
 	}
 
-	// 遍历所有网络接口，获取IP地址
+	result := make([]interfaceAddrs, 0, len(interfaces))
 	for _, iface := range interfaces {
-		// 忽略未启用和回环接口
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-
 		addrs, err := iface.Addrs()
 		if err != nil {
 			continue
 		}
 
-		// 遍历接口的所有地址，查找IPv4地址
+		ips := make([]net.IP, 0, len(addrs))
 		for _, addr := range addrs {
-			var ip net.IP
 			switch v := addr.(type) {
 			case *net.IPNet:
-				ip = v.IP
+				ips = append(ips, v.IP)
 			case *net.IPAddr:
-				ip = v.IP
-			}
-
-			// 检查是否是IPv4地址且非本地地址
-			if ip != nil && ip.To4() != nil && !ip.IsPrivate() && !ip.IsLoopback() {
-				return ip.String()
+				ips = append(ips, v.IP)
 			}
 		}
+		result = append(result, interfaceAddrs{flags: iface.Flags, addrs: ips})
 	}
+	return result
+}
 
-	// 如果未找到公网IP，尝试返回内网IP
-	for _, iface := range interfaces {
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-
-		addrs, err := iface.Addrs()
-		if err != nil {
-			continue
-		}
+// selectIP 按网卡顺序选择指定地址族的IP，优先公网地址，其次内网地址
+func selectIP(interfaces []interfaceAddrs, family AddressFamily) string {
+	if candidates := candidateIPs(interfaces, family); len(candidates) > 0 {
+		return candidates[0]
+	}
+	return ""
+}
 
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
+// candidateIPs 按网卡顺序列出指定地址族的候选IP，存在公网地址时只列出公网地址，否则列出内网地址
+func candidateIPs(interfaces []interfaceAddrs, family AddressFamily) []string {
+	// 遍历所有网络接口，先查找公网地址，未找到时再查找内网地址
+	for _, public := range []bool{true, false} {
+		var candidates []string
+		for _, iface := range interfaces {
+			// 忽略未启用和回环接口
+			if iface.flags&net.FlagUp == 0 || iface.flags&net.FlagLoopback != 0 {
+				continue
 			}
 
-			// 返回第一个有效的IPv4地址
-			if ip != nil && ip.To4() != nil && !ip.IsLoopback() {
-				return ip.String()
+			for _, ip := range iface.addrs {
+				// 链路本地地址（如DHCP失败时的169.254.x.x）不可路由且不稳定，两轮都不作为身份使用
+				if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || !family.matches(ip) {
+					continue
+				}
+				if public && ip.IsPrivate() {
+					continue
+				}
+				candidates = append(candidates, ip.String())
 			}
 		}
+		if len(candidates) > 0 {
+			return candidates
+		}
 	}
 
-	return ""
+	return nil
+}
+
+// NodeIdKeyStability 检查当前环境下生成的节点ID Key在重启后是否稳定，可用于发布前检查
+// 设置了有效的POD_IP时稳定；否则依赖网卡扫描，存在多个候选地址时选中的地址取决于网卡顺序，可能不稳定
+// POD_NAME不参与节点ID Key的生成，仅设置POD_NAME不能保证稳定；
+// IP、端口或部署类型包含分隔符时key无法无歧义地解析，分配器拒绝分配，同样视为不稳定
+// @param port 节点ID Key中的端口
+// @param opts 与分配器一致的配置，用于确定分隔符与部署类型
+// @return stable
+// @return reason 判断依据
+func NodeIdKeyStability(port int, opts ...OptionFn) (stable bool, reason string) {
+	ip, stable, reason := addressStability()
+	if !stable {
+		return false, reason
+	}
+	op := newOption(opts...)
+	deployType := keyDeployType(op, false)
+	key := formatNodeIdKey("", ip, port, deployType, op.keySeparator)
+	if err := checkNodeIdKey(key, "", ip, port, deployType, op.keySeparator); err != nil {
+		return false, reason + "; " + err.Error()
+	}
+	return true, reason
+}
+
+// addressStability 节点ID Key中的IP在重启后是否稳定
+// @return ip 稳定时为选中的IP
+// @return stable
+// @return reason 判断依据
+func addressStability() (ip string, stable bool, reason string) {
+	if podIP, ok := os.LookupEnv("POD_IP"); ok {
+		if net.ParseIP(podIP) != nil {
+			return podIP, true, fmt.Sprintf("POD_IP is set to %s", podIP)
+		}
+		reason = fmt.Sprintf("POD_IP %q is not a valid address, ", podIP)
+	}
+
+	candidates := candidateIPs(scanInterfaces(), AddressFamilyAuto)
+	switch len(candidates) {
+	case 0:
+		reason += "no usable interface address was found"
+	case 1:
+		return candidates[0], true, reason + fmt.Sprintf("the only usable interface address is %s", candidates[0])
+	default:
+		reason += fmt.Sprintf("%d usable interface addresses %s were found, the selected one depends on interface ordering",
+			len(candidates), strings.Join(candidates, ","))
+	}
+	if _, ok := os.LookupEnv("POD_NAME"); ok {
+		reason += "; POD_NAME is set but is not part of the node id key, set POD_IP instead"
+	}
+	return "", false, reason
 }
@@ -0,0 +1,107 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+//go:build go1.18
+// +build go1.18
+
+// Package gorm 节点ID Key解析模糊测试
+package gorm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// nodeIdKeySeeds 节点ID Key模糊测试的种子语料
+var nodeIdKeySeeds = []string{
+	"",
+	"_",
+	"___",
+	"____",
+	"service_10.0.0.1_8080_k8s",
+	"order_service_v2_10.0.0.1_8080_k8s",
+	"_leading__double__10.0.0.1_8080_k8s",
+	"_8080_k8s",
+	"___8080_",
+	"service_10.0.0.1_8080_",
+	"service_fd00::1_8080_physical",
+	"服务_10.0.0.1_8080_k8s",
+	"service\x00_10.0.0.1_8080_k8s",
+	"\xff\xfe_10.0.0.1_8080_k8s",
+	"service_10.0.0.1_-1_k8s",
+	"service_10.0.0.1_-0_k8s",
+	"service_10.0.0.1_+8080_k8s",
+	"service_10.0.0.1_08080_k8s",
+	"service_10.0.0.1_ 8080_k8s",
+	"service_10.0.0.1_9223372036854775807_k8s",
+	"service_10.0.0.1_9223372036854775808_k8s",
+	"service_10.0.0.1_port_k8s",
+}
+
+// FuzzParseNodeIdKey 测试任意输入解析时不panic，解析成功时各部分重新拼接后与key完全一致
+func FuzzParseNodeIdKey(f *testing.F) {
+	for _, key := range nodeIdKeySeeds {
+		f.Add(key, DefaultKeySeparator)
+	}
+	f.Add("order|service|fd00::1|8080|k8s", "|")
+	f.Add("a__b__10.0.0.1__8080__k8s", "__")
+	f.Add("aaaa", "aa")
+	f.Add("service_10.0.0.1_8080_k8s", "")
+	f.Fuzz(func(t *testing.T, key, separator string) {
+		name, ip, port, deployType, err := ParseNodeIdKeyWithSeparator(key, separator)
+		if err != nil {
+			return
+		}
+		if formatted := formatNodeIdKey(name, ip, port, deployType, separator); formatted != key {
+			t.Fatalf("parsed key %q formats back to %q", key, formatted)
+		}
+	})
+}
+
+// separatorOnlyBetween 分隔符是否只出现在IP、端口与部署类型之间，各部分内部及与相邻分隔符重叠处均不出现
+func separatorOnlyBetween(separator string, components ...string) bool {
+	if separator == "" {
+		return false
+	}
+	suffix := separator + strings.Join(components, separator)
+	expected := make(map[int]bool, len(components))
+	offset := 0
+	for _, component := range components {
+		expected[offset] = true
+		offset += len(separator) + len(component)
+	}
+	for i := range suffix {
+		if strings.HasPrefix(suffix[i:], separator) && !expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// FuzzNodeIdKeyRoundTrip 测试服务名称可以包含分隔符，分隔符只出现在其余各部分之间时拼接的key可以无损解析
+func FuzzNodeIdKeyRoundTrip(f *testing.F) {
+	f.Add("order_service", "10.0.0.1", 8080, "k8s", DefaultKeySeparator)
+	f.Add("_leading__double_", "", 0, "", DefaultKeySeparator)
+	f.Add("_8080_k8s", "fd00::1", -1, "physical", DefaultKeySeparator)
+	f.Add("服务_名称", "10.0.0.1", 65535, "docker", DefaultKeySeparator)
+	f.Add("order|service|", "fd00::1", 1<<62, "nomad", "|")
+	f.Add("a____b", "10.0.0.1", 8080, "k8s", "__")
+	f.Add("0", "0", 8080, "0", "0000000")
+	f.Fuzz(func(t *testing.T, name, ip string, port int, deployType, separator string) {
+		if !separatorOnlyBetween(separator, ip, strconv.Itoa(port), deployType) {
+			t.Skip()
+		}
+		key := formatNodeIdKey(name, ip, port, DeployType(deployType), separator)
+		gotName, gotIP, gotPort, gotDeployType, err := ParseNodeIdKeyWithSeparator(key, separator)
+		if err != nil {
+			t.Fatalf("parse %q: %v", key, err)
+		}
+		if gotName != name || gotIP != ip || gotPort != port || string(gotDeployType) != deployType {
+			t.Fatalf("key %q parsed as (%q, %q, %d, %q)", key, gotName, gotIP, gotPort, gotDeployType)
+		}
+	})
+}
@@ -8,10 +8,17 @@
 package gorm
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestDeployType_Is 测试部署类型判断
@@ -37,8 +44,12 @@ func TestGetDeployType_Default(t *testing.T) {
 	// 保存原始环境变量
 	oldK8sEnv, k8sExists := os.LookupEnv("KUBERNETES_SERVICE_HOST")
 
-	// 清除Kubernetes环境变量
+	oldNomadEnv, nomadExists := os.LookupEnv("NOMAD_ALLOC_ID")
+
+	// 清除Kubernetes、Nomad环境变量，并屏蔽宿主机上的容器标识文件
 	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	os.Unsetenv("NOMAD_ALLOC_ID")
+	defer stubDeployFiles(t, "", "")()
 
 	deployType := GetDeployType()
 	assert.Equal(t, Physical, deployType)
@@ -47,6 +58,123 @@ func TestGetDeployType_Default(t *testing.T) {
 	if k8sExists {
 		os.Setenv("KUBERNETES_SERVICE_HOST", oldK8sEnv)
 	}
+	if nomadExists {
+		os.Setenv("NOMAD_ALLOC_ID", oldNomadEnv)
+	}
+}
+
+// stubDeployFiles 将容器标识文件替换为临时文件，内容为空表示文件不存在
+func stubDeployFiles(t *testing.T, dockerEnv, systemdContainer string) func() {
+	dir := t.TempDir()
+	oldDockerEnvPath, oldSystemdContainerPath := dockerEnvPath, systemdContainerPath
+
+	dockerEnvPath = filepath.Join(dir, ".dockerenv")
+	if dockerEnv != "" {
+		assert.NoError(t, os.WriteFile(dockerEnvPath, []byte(dockerEnv), 0o600))
+	}
+	systemdContainerPath = filepath.Join(dir, "container")
+	if systemdContainer != "" {
+		assert.NoError(t, os.WriteFile(systemdContainerPath, []byte(systemdContainer), 0o600))
+	}
+
+	return func() {
+		dockerEnvPath, systemdContainerPath = oldDockerEnvPath, oldSystemdContainerPath
+	}
+}
+
+// unsetDeployEnv 清除部署类型相关的环境变量，返回恢复函数
+func unsetDeployEnv() func() {
+	keys := []string{"KUBERNETES_SERVICE_HOST", "NOMAD_ALLOC_ID"}
+	old := make(map[string]string)
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			old[key] = value
+		}
+		os.Unsetenv(key)
+	}
+
+	return func() {
+		for _, key := range keys {
+			if value, ok := old[key]; ok {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}
+}
+
+// TestGetDeployType_Nomad 测试Nomad环境检测
+func TestGetDeployType_Nomad(t *testing.T) {
+	defer unsetDeployEnv()()
+	defer stubDeployFiles(t, "", "")()
+
+	os.Setenv("NOMAD_ALLOC_ID", "5f4d7a2c-1b7e-4d3e-9b2a-6c1f0e8d9a3b")
+	assert.Equal(t, Nomad, GetDeployType())
+
+	// Nomad的docker驱动同样会存在/.dockerenv，优先识别为Nomad
+	defer stubDeployFiles(t, "docker", "")()
+	assert.Equal(t, Nomad, GetDeployType())
+}
+
+// TestGetDeployType_Nspawn 测试systemd-nspawn环境检测
+func TestGetDeployType_Nspawn(t *testing.T) {
+	defer unsetDeployEnv()()
+
+	restore := stubDeployFiles(t, "", "systemd-nspawn\n")
+	assert.Equal(t, Nspawn, GetDeployType())
+	restore()
+
+	// 其他容器管理器写入的内容不识别为nspawn
+	restore = stubDeployFiles(t, "", "lxc\n")
+	assert.Equal(t, Physical, GetDeployType())
+	restore()
+
+	// Docker优先于nspawn
+	restore = stubDeployFiles(t, "docker", "systemd-nspawn\n")
+	assert.Equal(t, Docker, GetDeployType())
+	restore()
+}
+
+// TestWithPhysicalFallback 测试检测回退为物理机时调用回调，显式指定或检测到其他部署类型时不调用
+func TestWithPhysicalFallback(t *testing.T) {
+	defer unsetDeployEnv()()
+	db := testDB(t)
+	fallbacks := 0
+	onFallback := WithPhysicalFallback(func() { fallbacks++ })
+
+	// 未命中任何信号，回退为物理机
+	restore := stubDeployFiles(t, "", "")
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		onFallback)
+	assert.Equal(t, Physical, allocator.deployType)
+	assert.Equal(t, 1, fallbacks)
+
+	// 显式指定物理机不属于回退
+	allocator = NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		onFallback, WithDeployType(Physical))
+	assert.Equal(t, Physical, allocator.deployType)
+	assert.Equal(t, 1, fallbacks)
+
+	// 显式指定的部署类型优先于检测
+	allocator = NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		onFallback, WithDeployType(K8s))
+	assert.Equal(t, K8s, allocator.deployType)
+	assert.Equal(t, 1, fallbacks)
+
+	// 时间同步器与分配器使用相同的Key，但不重复调用回调
+	synchronizer := NewTimeSynchronizer(context.Background(), db, testName, testPort, time.Second, logger, onFallback)
+	assert.Contains(t, synchronizer.nodeIdKey, string(Physical))
+	assert.Equal(t, 1, fallbacks)
+	restore()
+
+	// 检测到Docker
+	restore = stubDeployFiles(t, "docker", "")
+	allocator = NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		onFallback)
+	assert.Equal(t, Docker, allocator.deployType)
+	assert.Equal(t, 1, fallbacks)
+	restore()
 }
 
 // TestGetDeployType_K8s 测试Kubernetes环境检测
@@ -68,6 +196,35 @@ func TestGetDeployType_K8s(t *testing.T) {
 	assert.Equal(t, K8s, deployType)
 }
 
+// TestGetDeployTypeWithPrecedence 测试Kubernetes与Docker信号同时存在时按配置的规则判断部署类型
+func TestGetDeployTypeWithPrecedence(t *testing.T) {
+	defer unsetDeployEnv()()
+	defer stubDeployFiles(t, "docker", "")()
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+
+	assert.Equal(t, K8s, GetDeployType())
+	assert.Equal(t, K8s, GetDeployTypeWithPrecedence(DeployTypePrecedenceK8s))
+	assert.Equal(t, Docker, GetDeployTypeWithPrecedence(DeployTypePrecedenceDocker))
+	assert.Equal(t, K8sDocker, GetDeployTypeWithPrecedence(DeployTypePrecedenceCombined))
+
+	// 节点ID Key使用配置的规则
+	allocator := NewNodeIdAllocator(context.Background(), testDB(t), testName, testPort, time.Second, 5*time.Second,
+		logger, WithDeployTypePrecedence(DeployTypePrecedenceCombined))
+	_, _, _, deployType, err := ParseNodeIdKey(allocator.nodeIdKey)
+	require.NoError(t, err)
+	assert.Equal(t, K8sDocker, deployType)
+	nodeRange, ok := DefaultDeployTypePartitions.rangeOf(K8sDocker)
+	assert.True(t, ok)
+	assert.Equal(t, DefaultDeployTypePartitions[K8s], nodeRange)
+
+	// 只有一种信号时不受规则影响
+	os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	assert.Equal(t, Docker, GetDeployTypeWithPrecedence(DeployTypePrecedenceCombined))
+	defer stubDeployFiles(t, "", "")()
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+	assert.Equal(t, K8s, GetDeployTypeWithPrecedence(DeployTypePrecedenceDocker))
+}
+
 // TestGetNodeIdKey 测试节点ID Key生成
 func TestGetNodeIdKey(t *testing.T) {
 	port := 8080
@@ -118,6 +275,249 @@ func TestGetIP_InvalidEnv(t *testing.T) {
 	assert.NotEqual(t, "invalid-ip", ip)
 }
 
+// TestWaitForPodIP 测试Pod IP文件延迟写入有效地址
+func TestWaitForPodIP(t *testing.T) {
+	oldInterval := podIPPollInterval
+	podIPPollInterval = 10 * time.Millisecond
+	defer func() { podIPPollInterval = oldInterval }()
+
+	// 启动初期为空，稍后写入真实地址
+	testIP := "10.1.2.3"
+	path := filepath.Join(t.TempDir(), "pod_ip")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	assigned := make(chan struct{})
+	go func() {
+		defer close(assigned)
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, os.WriteFile(path, []byte(testIP+"\n"), 0o600))
+	}()
+
+	assert.Equal(t, testIP, WaitForPodIP(path, time.Second))
+	<-assigned
+
+	// 分配器构造时等待文件，文件中的地址优先于POD_IP
+	allocator := NewNodeIdAllocator(context.Background(), testDB(t), testName, testPort, time.Second, 5*time.Second,
+		logger, WithPodIPFile(path, time.Second))
+	assert.Equal(t, testIP, allocator.IP())
+
+	// 超时后回退到POD_IP与网卡扫描
+	require.NoError(t, os.WriteFile(path, []byte("pending"), 0o600))
+	start := time.Now()
+	assert.NotEqual(t, "pending", WaitForPodIP(path, 50*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// 不等待
+	require.NoError(t, os.WriteFile(path, []byte(testIP), 0o600))
+	assert.Equal(t, testIP, WaitForPodIP(path, 0))
+}
+
+// TestGetIP_Cache 测试网卡扫描结果被缓存且RefreshIP跳过缓存
+func TestGetIP_Cache(t *testing.T) {
+	oldPodIP, podIPExists := os.LookupEnv("POD_IP")
+	os.Unsetenv("POD_IP")
+	oldScan := scanInterfaces
+	var scans int
+	scanInterfaces = func() []interfaceAddrs {
+		scans++
+		return []interfaceAddrs{{flags: net.FlagUp, addrs: []net.IP{net.ParseIP(fmt.Sprintf("192.0.2.%d", scans))}}}
+	}
+	defer func() {
+		scanInterfaces = oldScan
+		RefreshIP()
+		if podIPExists {
+			os.Setenv("POD_IP", oldPodIP)
+		}
+	}()
+
+	ip := RefreshIP()
+	assert.Equal(t, "192.0.2.1", ip)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, ip, GetIP())
+	}
+	assert.Equal(t, 1, scans)
+
+	// 刷新后重新扫描
+	assert.Equal(t, "192.0.2.2", RefreshIP())
+	assert.Equal(t, "192.0.2.2", GetIP())
+	assert.Equal(t, 2, scans)
+
+	// POD_IP优先于缓存
+	os.Setenv("POD_IP", "10.0.0.8")
+	assert.Equal(t, "10.0.0.8", GetIP())
+	assert.Equal(t, 2, scans)
+}
+
+// stubInterfaces 将网卡扫描结果替换为指定的网卡，返回恢复函数
+func stubInterfaces(interfaces ...interfaceAddrs) func() {
+	oldScan := scanInterfaces
+	scanInterfaces = func() []interfaceAddrs { return interfaces }
+	RefreshIP()
+	return func() {
+		scanInterfaces = oldScan
+		RefreshIP()
+	}
+}
+
+// dualStack 双栈网卡，IPv6在前
+func dualStack() []interfaceAddrs {
+	return []interfaceAddrs{
+		{flags: net.FlagUp | net.FlagLoopback, addrs: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}},
+		{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::10"),
+			net.ParseIP("10.0.0.10")}},
+		{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("fd00::20"), net.ParseIP("192.168.1.20")}},
+	}
+}
+
+// TestGetIPByFamily 测试双栈主机按地址族选择IP
+func TestGetIPByFamily(t *testing.T) {
+	oldPodIP, podIPExists := os.LookupEnv("POD_IP")
+	os.Unsetenv("POD_IP")
+	defer func() {
+		if podIPExists {
+			os.Setenv("POD_IP", oldPodIP)
+		}
+	}()
+	defer stubInterfaces(dualStack()...)()
+
+	assert.Equal(t, "10.0.0.10", GetIPByFamily(AddressFamilyAuto))
+	assert.Equal(t, "10.0.0.10", GetIPByFamily(AddressFamilyIPv4))
+	// 跳过链路本地地址，优先公网地址
+	assert.Equal(t, "2001:db8::10", GetIPByFamily(AddressFamilyIPv6))
+
+	// 只有内网IPv6时回退到内网地址
+	defer stubInterfaces(interfaceAddrs{flags: net.FlagUp,
+		addrs: []net.IP{net.ParseIP("fe80::1"), net.ParseIP("fd00::20"), net.ParseIP("10.0.0.10")}})()
+	assert.Equal(t, "fd00::20", GetIPByFamily(AddressFamilyIPv6))
+
+	// POD_IP不属于指定地址族时忽略
+	os.Setenv("POD_IP", "10.1.1.1")
+	assert.Equal(t, "10.1.1.1", GetIPByFamily(AddressFamilyAuto))
+	assert.Equal(t, "10.1.1.1", GetIPByFamily(AddressFamilyIPv4))
+	assert.Equal(t, "fd00::20", GetIPByFamily(AddressFamilyIPv6))
+	os.Setenv("POD_IP", "2001:db8::99")
+	assert.Equal(t, "2001:db8::99", GetIPByFamily(AddressFamilyAuto))
+	assert.Equal(t, "10.0.0.10", GetIPByFamily(AddressFamilyIPv4))
+	assert.Equal(t, "2001:db8::99", GetIPByFamily(AddressFamilyIPv6))
+}
+
+// TestGetIP_SkipsIPv4LinkLocal 测试跳过IPv4链路本地地址
+func TestGetIP_SkipsIPv4LinkLocal(t *testing.T) {
+	oldPodIP, podIPExists := os.LookupEnv("POD_IP")
+	os.Unsetenv("POD_IP")
+	defer func() {
+		if podIPExists {
+			os.Setenv("POD_IP", oldPodIP)
+		}
+	}()
+
+	// 只有链路本地地址时不选择任何地址
+	defer stubInterfaces(
+		interfaceAddrs{flags: net.FlagUp | net.FlagLoopback, addrs: []net.IP{net.ParseIP("127.0.0.1")}},
+		interfaceAddrs{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("169.254.10.20")}},
+	)()
+	assert.Equal(t, "", GetIP())
+	stable, reason := NodeIdKeyStability(testPort)
+	assert.False(t, stable)
+	assert.Contains(t, reason, "no usable interface address")
+
+	// 存在内网地址时选择内网地址，而不是公网轮中的链路本地地址
+	defer stubInterfaces(interfaceAddrs{flags: net.FlagUp,
+		addrs: []net.IP{net.ParseIP("169.254.10.20"), net.ParseIP("10.0.0.10")}})()
+	assert.Equal(t, "10.0.0.10", GetIP())
+}
+
+// TestNodeIdKeyStability 测试根据POD_IP、POD_NAME与网卡扫描结果判断节点ID Key是否稳定
+func TestNodeIdKeyStability(t *testing.T) {
+	oldPodIP, podIPExists := os.LookupEnv("POD_IP")
+	oldPodName, podNameExists := os.LookupEnv("POD_NAME")
+	os.Unsetenv("POD_IP")
+	os.Unsetenv("POD_NAME")
+	defer func() {
+		os.Unsetenv("POD_IP")
+		os.Unsetenv("POD_NAME")
+		if podIPExists {
+			os.Setenv("POD_IP", oldPodIP)
+		}
+		if podNameExists {
+			os.Setenv("POD_NAME", oldPodName)
+		}
+	}()
+	defer stubInterfaces(dualStack()...)()
+
+	// 多个候选地址时依赖网卡顺序
+	stable, reason := NodeIdKeyStability(testPort)
+	assert.False(t, stable)
+	assert.Contains(t, reason, "depends on interface ordering")
+
+	// POD_NAME不参与key的生成
+	os.Setenv("POD_NAME", "order-0")
+	stable, reason = NodeIdKeyStability(testPort)
+	assert.False(t, stable)
+	assert.Contains(t, reason, "POD_NAME is set but is not part of the node id key")
+
+	// 无效的POD_IP回退到网卡扫描
+	os.Setenv("POD_IP", "pending")
+	stable, reason = NodeIdKeyStability(testPort)
+	assert.False(t, stable)
+	assert.Contains(t, reason, `POD_IP "pending" is not a valid address`)
+
+	os.Setenv("POD_IP", "10.0.0.8")
+	stable, reason = NodeIdKeyStability(testPort)
+	assert.True(t, stable)
+	assert.Equal(t, "POD_IP is set to 10.0.0.8", reason)
+
+	// IP或端口包含分隔符时key无法无歧义地解析
+	stable, reason = NodeIdKeyStability(testPort, WithKeySeparator("."))
+	assert.False(t, stable)
+	assert.Contains(t, reason, `contains the separator "."`)
+	stable, reason = NodeIdKeyStability(8080, WithKeySeparator("80"))
+	assert.False(t, stable)
+	assert.Contains(t, reason, `contains the separator "80"`)
+
+	// 只有一个候选地址时稳定
+	os.Unsetenv("POD_IP")
+	defer stubInterfaces(interfaceAddrs{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("10.0.0.10")}})()
+	stable, reason = NodeIdKeyStability(testPort)
+	assert.True(t, stable)
+	assert.Contains(t, reason, "10.0.0.10")
+
+	defer stubInterfaces()()
+	stable, reason = NodeIdKeyStability(testPort)
+	assert.False(t, stable)
+	assert.Contains(t, reason, "no usable interface address")
+}
+
+// TestWithAddressFamily_StableKey 测试网卡顺序变化时节点ID Key保持稳定
+func TestWithAddressFamily_StableKey(t *testing.T) {
+	oldPodIP, podIPExists := os.LookupEnv("POD_IP")
+	os.Unsetenv("POD_IP")
+	defer func() {
+		if podIPExists {
+			os.Setenv("POD_IP", oldPodIP)
+		}
+	}()
+
+	key := func(family AddressFamily, interfaces ...interfaceAddrs) string {
+		defer stubInterfaces(interfaces...)()
+		return NewNodeIdAllocator(context.Background(), testDB(t), testName, testPort, time.Second, 5*time.Second,
+			logger, WithAddressFamily(family)).nodeIdKey
+	}
+	v6 := interfaceAddrs{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("2001:db8::10")}}
+	v4 := interfaceAddrs{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("10.0.0.10")}}
+
+	expected := map[AddressFamily]string{
+		AddressFamilyAuto: "_10.0.0.10_",
+		AddressFamilyIPv4: "_10.0.0.10_",
+		AddressFamilyIPv6: "_2001:db8::10_",
+	}
+	for family, ip := range expected {
+		// 两次启动网卡顺序不同
+		first, second := key(family, v6, v4), key(family, v4, v6)
+		assert.Contains(t, first, ip)
+		assert.Equal(t, first, second)
+	}
+}
+
 // TestGetIP_NoEnv 测试无环境变量时从网络接口获取IP
 func TestGetIP_NoEnv(t *testing.T) {
 	// 保存原始环境变量
@@ -165,7 +565,9 @@ func TestGetNodeIdKey_SamePort(t *testing.T) {
 // TestDeployType_String 测试部署类型字符串表示
 func TestDeployType_String(t *testing.T) {
 	assert.Equal(t, "k8s", string(K8s))
+	assert.Equal(t, "nomad", string(Nomad))
 	assert.Equal(t, "docker", string(Docker))
+	assert.Equal(t, "nspawn", string(Nspawn))
 	assert.Equal(t, "physical", string(Physical))
 }
 
@@ -182,6 +584,101 @@ func TestGetNodeIdKey_Format(t *testing.T) {
 	assert.Contains(t, key, "_8080_")
 }
 
+// TestParseNodeIdKey 测试服务名称包含分隔符时节点ID Key可以无损解析
+func TestParseNodeIdKey(t *testing.T) {
+	cases := []struct {
+		name, ip  string
+		separator string
+	}{
+		{name: "order_service_v2", ip: "10.0.0.1", separator: DefaultKeySeparator},
+		{name: "_leading__double_", ip: "10.0.0.1", separator: DefaultKeySeparator},
+		{name: "order|service", ip: "fd00::1", separator: "|"},
+		{name: "order_service", ip: "", separator: DefaultKeySeparator},
+	}
+	for _, c := range cases {
+		key := formatNodeIdKey(c.name, c.ip, 8080, K8s, c.separator)
+		name, ip, port, deployType, err := ParseNodeIdKeyWithSeparator(key, c.separator)
+		require.NoError(t, err, key)
+		assert.Equal(t, c.name, name)
+		assert.Equal(t, c.ip, ip)
+		assert.Equal(t, 8080, port)
+		assert.Equal(t, K8s, deployType)
+	}
+
+	name, _, port, deployType, err := ParseNodeIdKey(GetNodeIdKey("order_service", 9090))
+	require.NoError(t, err)
+	assert.Equal(t, "order_service", name)
+	assert.Equal(t, 9090, port)
+	assert.Equal(t, GetDeployType(), deployType)
+}
+
+// TestCheckNodeIdKey 测试服务名称以外的部分包含分隔符时key无法无损解析，被拒绝
+func TestCheckNodeIdKey(t *testing.T) {
+	cases := []struct {
+		ip         string
+		port       int
+		deployType DeployType
+		separator  string
+	}{
+		{ip: "order_0", port: 8080, deployType: K8s, separator: DefaultKeySeparator},
+		{ip: "fd00::1", port: 8080, deployType: K8s, separator: ":"},
+		{ip: "10.0.0.1", port: 8080, deployType: "k8s_docker", separator: DefaultKeySeparator},
+		{ip: "10.0.0.1", port: -1, deployType: K8s, separator: "-"},
+		{ip: "10.0.0.1", port: 8080, deployType: K8s, separator: "0"},
+		{ip: "10.0.0.1", port: 8080, deployType: "ak8s", separator: "aa"},
+	}
+	for _, c := range cases {
+		key := formatNodeIdKey("order_service", c.ip, c.port, c.deployType, c.separator)
+		name, ip, port, deployType, err := ParseNodeIdKeyWithSeparator(key, c.separator)
+		if err == nil {
+			// 解析成功时各部分与生成时不一致
+			assert.NotEqual(t, []interface{}{"order_service", c.ip, c.port, c.deployType},
+				[]interface{}{name, ip, port, deployType}, key)
+		}
+		assert.ErrorIs(t, checkNodeIdKey(key, "order_service", c.ip, c.port, c.deployType, c.separator),
+			ErrInvalidNodeIdKey, key)
+	}
+
+	// 仅服务名称包含分隔符时可以无损解析
+	key := formatNodeIdKey("order_service", "10.0.0.1", 8080, K8s, DefaultKeySeparator)
+	assert.NoError(t, checkNodeIdKey(key, "order_service", "10.0.0.1", 8080, K8s, DefaultKeySeparator))
+}
+
+// TestParseNodeIdKey_Invalid 测试无法解析的节点ID Key返回ErrInvalidNodeIdKey
+func TestParseNodeIdKey_Invalid(t *testing.T) {
+	for _, key := range []string{"", "service", "service_10.0.0.1_k8s", "service_10.0.0.1_port_k8s",
+		"service_10.0.0.1_+8080_k8s", "service_10.0.0.1_08080_k8s", "service_10.0.0.1_-0_k8s",
+		"service_10.0.0.1_99999999999999999999_k8s"} {
+		_, _, _, _, err := ParseNodeIdKey(key)
+		assert.ErrorIs(t, err, ErrInvalidNodeIdKey, key)
+	}
+	_, _, _, _, err := ParseNodeIdKeyWithSeparator("service_10.0.0.1_8080_k8s", "")
+	assert.ErrorIs(t, err, ErrInvalidNodeIdKey)
+}
+
+// TestWithKeySeparator 测试分配器使用配置的分隔符生成节点ID Key
+func TestWithKeySeparator(t *testing.T) {
+	allocator := NewNodeIdAllocator(context.Background(), testDB(t), "order_service", 8080, time.Second,
+		5*time.Second, logger, WithKeySeparator("|"))
+	name, _, port, _, err := ParseNodeIdKeyWithSeparator(allocator.nodeIdKey, "|")
+	require.NoError(t, err)
+	assert.Equal(t, "order_service", name)
+	assert.Equal(t, 8080, port)
+}
+
+// TestNewNodeIdKey 测试生成节点ID Key时拒绝无效端口
+func TestNewNodeIdKey(t *testing.T) {
+	for _, port := range []int{-1, 0, 65536} {
+		key, err := NewNodeIdKey("test-service", port)
+		assert.ErrorIs(t, err, ErrInvalidPort, "port %d", port)
+		assert.Empty(t, key)
+	}
+
+	key, err := NewNodeIdKey("test-service", 8080)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("test-service_%s_8080_%s", GetIP(), GetDeployType()), key)
+}
+
 // TestGetIP_Loopback 测试本地回环地址被忽略
 func TestGetIP_Loopback(t *testing.T) {
 	// 保存原始环境变量
@@ -238,3 +735,123 @@ func TestGetDeployType_StringValues(t *testing.T) {
 	assert.NotEqual(t, Docker, dt)
 	assert.NotEqual(t, Physical, dt)
 }
+
+// BenchmarkGetIP 测试获取IP的性能
+func BenchmarkGetIP(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = GetIP()
+		}
+	})
+	b.Run("refresh", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = RefreshIP()
+		}
+	})
+}
+
+// BenchmarkGetNodeIdKey 测试生成节点ID Key的性能
+func BenchmarkGetNodeIdKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = GetNodeIdKey("bench-service", 8080)
+	}
+}
+
+// TestWithIdentityFile 测试标识文件的内容替换节点ID Key中的IP，文件不存在或为空时回退到IP
+func TestWithIdentityFile(t *testing.T) {
+	db := testDB(t)
+	ip := GetIP()
+	path := filepath.Join(t.TempDir(), "podname")
+	require.NoError(t, os.WriteFile(path, []byte("order-service-7d9c5b-x2k4p\n"), 0o644))
+
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second, logger,
+		WithIdentityFile(path))
+	synchronizer := NewTimeSynchronizer(context.Background(), db, testName, testPort, time.Second, logger,
+		WithIdentityFile(path))
+	expected := formatNodeIdKey(testName, "order-service-7d9c5b-x2k4p", testPort, GetDeployType(), DefaultKeySeparator)
+	assert.Equal(t, expected, allocator.nodeIdKey)
+	assert.Equal(t, expected, synchronizer.nodeIdKey)
+	_, identity, _, _, err := ParseNodeIdKey(allocator.nodeIdKey)
+	require.NoError(t, err)
+	assert.Equal(t, "order-service-7d9c5b-x2k4p", identity)
+
+	require.NoError(t, os.WriteFile(path, []byte(" \n"), 0o644))
+	assert.Equal(t, ip, keyIdentity(path, ip))
+	assert.Equal(t, ip, keyIdentity(filepath.Join(t.TempDir(), "missing"), ip))
+	assert.Equal(t, ip, keyIdentity("", ip))
+}
+
+// TestWithIdentityFile_Separator 测试标识文件的内容包含分隔符时拒绝分配
+func TestWithIdentityFile_Separator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "podname")
+	require.NoError(t, os.WriteFile(path, []byte("order_service_0"), 0o644))
+
+	recorder := &recordLogger{}
+	allocator := NewNodeIdAllocator(context.Background(), testDB(t), testName, testPort, time.Second, 5*time.Second,
+		recorder, WithIdentityFile(path))
+	assert.True(t, recorder.contains("node id key is ambiguous"))
+	_, err := allocator.Alloc()
+	assert.ErrorIs(t, err, ErrInvalidNodeIdKey)
+
+	recorder = &recordLogger{}
+	allocator = NewNodeIdAllocator(context.Background(), testDB(t), testName, testPort, time.Second, 5*time.Second,
+		recorder, WithIdentityFile(path), WithKeySeparator("|"))
+	assert.False(t, recorder.contains("node id key is ambiguous"))
+	_, err = allocator.Alloc()
+	require.NoError(t, err)
+	_, identity, _, _, err := ParseNodeIdKeyWithSeparator(allocator.NodeIdKey(), "|")
+	require.NoError(t, err)
+	assert.Equal(t, "order_service_0", identity)
+}
+
+// TestIPPrecedence 测试Docker网桥地址与POD_IP不一致时各规则选择的地址，并输出告警
+func TestIPPrecedence(t *testing.T) {
+	oldPodIP, podIPExists := os.LookupEnv("POD_IP")
+	os.Setenv("POD_IP", "10.1.2.3")
+	defer func() {
+		if podIPExists {
+			os.Setenv("POD_IP", oldPodIP)
+		} else {
+			os.Unsetenv("POD_IP")
+		}
+	}()
+	// 容器内网卡扫描得到网桥地址
+	defer stubInterfaces(interfaceAddrs{flags: net.FlagUp, addrs: []net.IP{net.ParseIP("172.17.0.2")}})()
+
+	db := testDB(t)
+	tests := []struct {
+		precedence IPPrecedence
+		expected   string
+		refused    bool
+	}{
+		{IPPrecedenceEnv, "10.1.2.3", false},
+		{IPPrecedenceInterface, "172.17.0.2", false},
+		{IPPrecedenceAgreement, "10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		ip, err := GetIPWithPrecedence(AddressFamilyAuto, tt.precedence)
+		assert.Equal(t, tt.expected, ip)
+		assert.Equal(t, tt.refused, errors.Is(err, ErrIPMismatch))
+
+		recorder := &recordLogger{}
+		allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second,
+			recorder, WithIPPrecedence(tt.precedence))
+		_, keyIP, _, _, err := ParseNodeIdKey(allocator.nodeIdKey)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, keyIP)
+		assert.True(t, recorder.contains("POD_IP and the interface address disagree"))
+
+		_, err = allocator.Alloc()
+		assert.Equal(t, tt.refused, errors.Is(err, ErrIPMismatch))
+		allocator.Release()
+	}
+
+	// 两者一致时不告警
+	os.Setenv("POD_IP", "172.17.0.2")
+	recorder := &recordLogger{}
+	allocator := NewNodeIdAllocator(context.Background(), db, testName, testPort, time.Second, 5*time.Second,
+		recorder, WithIPPrecedence(IPPrecedenceAgreement))
+	assert.False(t, recorder.contains("disagree"))
+	_, err := allocator.Alloc()
+	require.NoError(t, err)
+}
@@ -0,0 +1,92 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package gorm 节点id分配器 漂移遗留的时间水位
+package gorm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"gorm.io/gen/field"
+)
+
+// watermarkKeyInfix 水位记录的key为节点ID Key + watermarkKeyInfix + 节点ID
+const watermarkKeyInfix = "#watermark#"
+
+// watermarkKey 当前key在节点ID上遗留的水位记录的key
+func (m *NodeIdAllocator) watermarkKey(nodeId int64) string {
+	return m.nodeIdKey + watermarkKeyInfix + strconv.FormatInt(nodeId, 10)
+}
+
+// isWatermarkKey 是否为漂移遗留的水位记录
+func isWatermarkKey(key string) bool {
+	return strings.Contains(key, watermarkKeyInfix)
+}
+
+// ownWatermark 是否为当前key遗留的水位记录
+func (m *NodeIdAllocator) ownWatermark(row *model.SnowflakeKv) bool {
+	return strings.HasPrefix(row.Key, m.nodeIdKey+watermarkKeyInfix)
+}
+
+// moveNodeId 将key的记录原地移动到节点ID nodeId
+// key为主键，每个key只有一条记录，移动后旧节点ID的时间随即丢失；
+// 旧节点ID保存的时间晚于当前时间（因时钟回拨漂移）时，在旧节点ID上写入水位记录，
+// 水位记录在抢占时间间隔内视为活跃的持有者，避免key重启后从哈希节点ID开始时以当前时间移回，生成重复的ID
+// @param ctx
+// @param q
+// @param held key当前的记录
+// @param nodeId 移动到的节点ID
+// @param columns 移动时更新的列
+// @param now
+// @param nowTime
+// @return error
+func (m *NodeIdAllocator) moveNodeId(ctx context.Context, q *dao.Query, held *model.SnowflakeKv, nodeId int64,
+	columns []field.AssignExpr, now time.Time, nowTime int64) error {
+	return q.Transaction(func(tx *dao.Query) error {
+		tab := tx.SnowflakeKv
+		start := time.Now()
+		_, err := tab.WithContext(ctx).Where(tab.Key.Eq(m.nodeIdKey)).UpdateSimple(columns...)
+		observeSince(m.metrics, DBOpUpdate, start)
+		if err != nil || held.Time <= nowTime {
+			return err
+		}
+		start = time.Now()
+		err = tab.WithContext(ctx).UnderlyingDB().Table(tab.TableName()).Create(map[string]interface{}{
+			tab.ColumnName("key"):     m.watermarkKey(held.NodeID),
+			tab.ColumnName("node_id"): held.NodeID,
+			tab.ColumnName("time"):    held.Time,
+			tab.ColumnName("created"): now,
+			tab.ColumnName("updated"): now,
+		}).Error
+		observeSince(m.metrics, DBOpCreate, start)
+		return err
+	})
+}
+
+// releaseWatermark 删除已过期的水位记录，水位之后的ID已不会在当前时间重复
+// 查询之后记录被删除或更新时不删除
+// @return bool 是否删除成功
+// @return error
+func (m *NodeIdAllocator) releaseWatermark(ctx context.Context, q *dao.Query, row *model.SnowflakeKv,
+	logger Logger) (bool, error) {
+	tab := q.SnowflakeKv
+	result, err := tab.WithContext(ctx).
+		Where(tab.Key.Eq(row.Key), tab.NodeID.Eq(row.NodeID), tab.Time.Eq(row.Time)).Delete()
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	logger.Infof("node id watermark expired, releasing. key: %s, node id: %d, owner: %s, saved: %s",
+		m.nodeIdKey, row.NodeID, row.Key, m.timeUnit.Time(row.Time).Format(time.RFC3339))
+	return true, nil
+}
@@ -9,6 +9,8 @@ package nodeid
 
 import (
 	"encoding/binary"
+	"errors"
+	"strings"
 
 	"github.com/bwmarrin/snowflake"
 	xxhash2 "github.com/cespare/xxhash/v2"
@@ -17,6 +19,8 @@ import (
 // HashNodeIdAllocator 哈希节点ID分配器
 type HashNodeIdAllocator struct {
 	nodeIdKey string
+	// modulus 节点ID的取模数，0表示使用当前位布局下的全部节点ID
+	modulus int64
 }
 
 // NewHashNodeIdAllocator 创建一个哈希节点ID分配器
@@ -26,20 +30,90 @@ func NewHashNodeIdAllocator(nodeIdKey string) snowflake.NodeIdAllocator {
 	return &HashNodeIdAllocator{nodeIdKey: nodeIdKey}
 }
 
+// NewHashNodeIdAllocatorWithModulus 创建一个按指定模数取模的哈希节点ID分配器，节点ID落在[0, modulus)内
+// 模数与位布局无关，可以不是2的幂，如为便于记忆只预留1000个节点ID；节点ID仍需放入节点位中，模数不能超过节点ID数量
+// @param nodeIdKey
+// @param modulus
+// @return snowflake.NodeIdAllocator
+// @return error 模数不在[1, 1<<snowflake.NodeBits]内时返回ErrInvalidModulus
+func NewHashNodeIdAllocatorWithModulus(nodeIdKey string, modulus int64) (snowflake.NodeIdAllocator, error) {
+	if err := validateModulus(modulus); err != nil {
+		return nil, err
+	}
+	return &HashNodeIdAllocator{nodeIdKey: nodeIdKey, modulus: modulus}, nil
+}
+
+// keyPartSeparator 多段节点ID Key的分隔符，不会出现在服务名、UID等常规组成部分中，
+// 避免("a_b", "c")与("a", "b_c")拼接后相同
+const keyPartSeparator = "\x00"
+
+// NewHashNodeIdAllocatorMulti 由多段组成部分创建一个哈希节点ID分配器，各部分按顺序以分隔符拼接后哈希
+// 调用方无需自行拼接，如服务名与Pod UID；交换顺序会得到不同的节点ID
+// @param parts
+// @return snowflake.NodeIdAllocator
+func NewHashNodeIdAllocatorMulti(parts ...string) snowflake.NodeIdAllocator {
+	return &HashNodeIdAllocator{nodeIdKey: strings.Join(parts, keyPartSeparator)}
+}
+
 // Alloc 分配一个哈希节点ID
 // @receiver n
 // @return nodeId
 // @return err
 func (n *HashNodeIdAllocator) Alloc() (int64, error) {
+	slots, err := moduloSlots(n.modulus)
+	if err != nil {
+		return 0, err
+	}
+	return int64(KeyHash(n.nodeIdKey) % uint64(slots)), nil
+}
+
+// KeyHash 节点ID Key的哈希值，对槽位数取模即为哈希分配的节点ID
+// @param nodeIdKey
+// @return uint64
+func KeyHash(nodeIdKey string) uint64 {
 	d := xxhash2.New()
-	_, _ = d.WriteString(n.nodeIdKey)
-	return int64(d.Sum64() % 1024), nil
+	_, _ = d.WriteString(nodeIdKey)
+	return d.Sum64()
 }
 
+// maxMigrationAttempts 节点ID漂移时重新哈希的最大次数
+const maxMigrationAttempts = 8
+
+// ErrMigrationExhausted 节点ID漂移找不到可用的其他节点ID
+var ErrMigrationExhausted = errors.New("node id migration exhausted")
+
+// Migration 节点ID漂移，保证漂移后的节点ID与原节点ID不同
+// 对节点ID重新哈希，若落回原槽位则带上尝试次数继续哈希，结果是确定的；
+// 超过最大次数仍未离开原槽位时顺延到下一个槽位；只有一个槽位时返回ErrMigrationExhausted
+// @receiver n
+// @param nodeId
+// @return newNodeId
+// @return err
 func (n *HashNodeIdAllocator) Migration(nodeId int64) (newNodeId int64, err error) {
-	nodeIdBytes := make([]byte, 8)
+	slots, err := moduloSlots(n.modulus)
+	if err != nil {
+		return 0, err
+	}
+	if slots <= 1 {
+		return 0, ErrMigrationExhausted
+	}
+	for attempt := 0; attempt < maxMigrationAttempts; attempt++ {
+		if newNodeId = migrationHash(nodeId, attempt, slots); newNodeId != nodeId {
+			return newNodeId, nil
+		}
+	}
+	return (nodeId + 1) % slots, nil
+}
+
+// migrationHash 计算第attempt次漂移的哈希槽位，首次与历史算法保持一致
+func migrationHash(nodeId int64, attempt int, slots int64) int64 {
+	nodeIdBytes := make([]byte, 8, 16)
 	binary.LittleEndian.PutUint64(nodeIdBytes, uint64(nodeId))
+	if attempt > 0 {
+		nodeIdBytes = nodeIdBytes[:16]
+		binary.LittleEndian.PutUint64(nodeIdBytes[8:], uint64(attempt))
+	}
 	d := xxhash2.New()
 	_, _ = d.Write(nodeIdBytes)
-	return int64(d.Sum64() % 1024), nil
+	return int64(d.Sum64() % uint64(slots))
 }
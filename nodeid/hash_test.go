@@ -8,8 +8,11 @@
 package nodeid
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/bwmarrin/snowflake"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -64,7 +67,7 @@ func TestHashNodeIdAllocator_Alloc_Range(t *testing.T) {
 		nodeId, err := allocator.Alloc()
 		assert.NoError(t, err)
 		assert.GreaterOrEqual(t, nodeId, int64(0))
-		assert.Less(t, nodeId, int64(1024))
+		assert.Less(t, nodeId, int64(MaxNodes))
 	}
 }
 
@@ -90,7 +93,7 @@ func TestHashNodeIdAllocator_Alloc_EmptyKey(t *testing.T) {
 	nodeId, err := allocator.Alloc()
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, nodeId, int64(0))
-	assert.Less(t, nodeId, int64(1024))
+	assert.Less(t, nodeId, int64(MaxNodes))
 }
 
 // TestHashNodeIdAllocator_Migration 测试节点ID漂移
@@ -98,13 +101,13 @@ func TestHashNodeIdAllocator_Migration(t *testing.T) {
 	allocator := NewHashNodeIdAllocator("test-key")
 
 	// 测试多个节点ID的漂移
-	testNodeIds := []int64{0, 100, 512, 1023, 555}
+	testNodeIds := []int64{0, 100, 512, MaxNodes - 1, 555}
 
 	for _, oldNodeId := range testNodeIds {
 		newNodeId, err := allocator.Migration(oldNodeId)
 		assert.NoError(t, err)
 		assert.GreaterOrEqual(t, newNodeId, int64(0))
-		assert.Less(t, newNodeId, int64(1024))
+		assert.Less(t, newNodeId, int64(MaxNodes))
 
 		// 验证相同输入产生相同输出
 		newNodeId2, err := allocator.Migration(oldNodeId)
@@ -120,7 +123,7 @@ func TestHashNodeIdAllocator_Migration_DifferentFromOriginal(t *testing.T) {
 	// 大多数情况下，漂移后的ID应该与原ID不同
 	diffCount := 0
 	for i := 0; i < 100; i++ {
-		oldNodeId := int64(i % 1024)
+		oldNodeId := int64(i % MaxNodes)
 		newNodeId, err := allocator.Migration(oldNodeId)
 		assert.NoError(t, err)
 		if newNodeId != oldNodeId {
@@ -150,3 +153,85 @@ func TestHashNodeIdAllocator_Migration_Consistency(t *testing.T) {
 	assert.Equal(t, newNodeId1, newNodeId2)
 	assert.Equal(t, newNodeId2, newNodeId3)
 }
+
+// TestHashNodeIdAllocator_Migration_AlwaysMoves 测试所有节点ID漂移后都离开原槽位
+func TestHashNodeIdAllocator_Migration_AlwaysMoves(t *testing.T) {
+	allocator := NewHashNodeIdAllocator("test-key")
+
+	for nodeId := int64(0); nodeId < MaxNodes; nodeId++ {
+		newNodeId, err := allocator.Migration(nodeId)
+		assert.NoError(t, err)
+		assert.NotEqual(t, nodeId, newNodeId)
+		assert.GreaterOrEqual(t, newNodeId, int64(0))
+		assert.Less(t, newNodeId, int64(MaxNodes))
+	}
+}
+
+// TestHashNodeIdAllocator_Migration_Compatible 测试未落回原槽位时漂移结果与首次哈希一致
+func TestHashNodeIdAllocator_Migration_Compatible(t *testing.T) {
+	allocator := NewHashNodeIdAllocator("test-key")
+
+	for nodeId := int64(0); nodeId < MaxNodes; nodeId++ {
+		if first := migrationHash(nodeId, 0, nodeSlots()); first != nodeId {
+			newNodeId, err := allocator.Migration(nodeId)
+			assert.NoError(t, err)
+			assert.Equal(t, first, newNodeId)
+		}
+	}
+}
+
+// TestHashNodeIdAllocator_Migration_SingleSlot 测试只有一个槽位时漂移返回耗尽错误
+func TestHashNodeIdAllocator_Migration_SingleSlot(t *testing.T) {
+	oldNodeBits := snowflake.NodeBits
+	snowflake.NodeBits = 0
+	defer func() { snowflake.NodeBits = oldNodeBits }()
+
+	_, err := NewHashNodeIdAllocator("test-key").Migration(0)
+	assert.True(t, errors.Is(err, ErrMigrationExhausted))
+}
+
+// TestNewHashNodeIdAllocatorMulti 测试多段组成部分的哈希分配器是确定的且与顺序相关
+func TestNewHashNodeIdAllocatorMulti(t *testing.T) {
+	first, err := NewHashNodeIdAllocatorMulti("order-service", "3f6c1b2e-pod-uid").Alloc()
+	assert.NoError(t, err)
+	again, err := NewHashNodeIdAllocatorMulti("order-service", "3f6c1b2e-pod-uid").Alloc()
+	assert.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	swapped, err := NewHashNodeIdAllocatorMulti("3f6c1b2e-pod-uid", "order-service").Alloc()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, swapped)
+
+	// 分隔符避免不同的切分方式拼接出相同的key
+	assert.NotEqual(t, NewHashNodeIdAllocatorMulti("a_b", "c"), NewHashNodeIdAllocatorMulti("a", "b_c"))
+}
+
+// TestHashNodeIdAllocator_Modulus 测试指定模数时节点ID与漂移结果均落在[0, modulus)内
+func TestHashNodeIdAllocator_Modulus(t *testing.T) {
+	for i := 0; i < 2000; i++ {
+		allocator, err := NewHashNodeIdAllocatorWithModulus(fmt.Sprintf("test-key-%d", i), 1000)
+		assert.NoError(t, err)
+		nodeId, err := allocator.Alloc()
+		assert.NoError(t, err)
+		assert.True(t, nodeId >= 0 && nodeId < 1000, "node id %d", nodeId)
+
+		migrated, err := allocator.Migration(nodeId)
+		assert.NoError(t, err)
+		assert.True(t, migrated >= 0 && migrated < 1000, "migrated node id %d", migrated)
+		assert.NotEqual(t, nodeId, migrated)
+	}
+
+	for _, modulus := range []int64{0, -1, 1025} {
+		_, err := NewHashNodeIdAllocatorWithModulus("test-key", modulus)
+		assert.True(t, errors.Is(err, ErrInvalidModulus))
+	}
+
+	// 创建后位布局缩小到模数以下时返回错误，而不是分配放不进节点位的节点ID
+	allocator, err := NewHashNodeIdAllocatorWithModulus("test-key", 1000)
+	assert.NoError(t, err)
+	oldNodeBits := snowflake.NodeBits
+	snowflake.NodeBits = 8
+	defer func() { snowflake.NodeBits = oldNodeBits }()
+	_, err = allocator.Alloc()
+	assert.True(t, errors.Is(err, ErrInvalidModulus))
+}
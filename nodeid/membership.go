@@ -0,0 +1,61 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 静态成员列表节点ID分配器
+package nodeid
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrNotMember 当前实例不在成员列表中
+var ErrNotMember = errors.New("self is not in the member list")
+
+// MembershipAllocator 静态成员列表节点ID分配器，以当前实例在有序成员列表中的下标作为节点ID
+// 适用于成员固定的小规模集群，所有成员需使用相同顺序的成员列表
+type MembershipAllocator struct {
+	members []string
+	self    string
+}
+
+// NewMembershipAllocator 创建一个静态成员列表节点ID分配器
+// @param members 有序的成员标识列表，通常来自配置
+// @param self 当前实例的成员标识
+// @return snowflake.NodeIdAllocator
+func NewMembershipAllocator(members []string, self string) snowflake.NodeIdAllocator {
+	return &MembershipAllocator{members: members, self: self}
+}
+
+// Alloc 返回当前实例在成员列表中的下标
+// 不在列表中时返回ErrNotMember，下标超出当前位布局的节点ID数量时返回错误
+// @receiver n
+// @return nodeId
+// @return err
+func (n *MembershipAllocator) Alloc() (int64, error) {
+	for i, member := range n.members {
+		if member != n.self {
+			continue
+		}
+		nodeId := int64(i)
+		if nodeId >= nodeSlots() {
+			return 0, fmt.Errorf("member %s at index %d exceeds the node id limit %d", n.self, nodeId, nodeSlots()-1)
+		}
+		return nodeId, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrNotMember, n.self)
+}
+
+// Migration 成员的节点ID是固定的，漂移到其他下标会与其他成员冲突，总是返回ErrMigrationExhausted
+// @receiver n
+// @param nodeId
+// @return newNodeId
+// @return err
+func (n *MembershipAllocator) Migration(nodeId int64) (int64, error) {
+	return 0, fmt.Errorf("%w: member node id %d is fixed", ErrMigrationExhausted, nodeId)
+}
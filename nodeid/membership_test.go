@@ -0,0 +1,63 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 静态成员列表节点ID分配器测试
+package nodeid
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMembershipAllocator_Alloc 测试以成员下标作为节点ID
+func TestMembershipAllocator_Alloc(t *testing.T) {
+	members := []string{"node-a", "node-b", "node-c"}
+	for i, member := range members {
+		nodeId, err := NewMembershipAllocator(members, member).Alloc()
+		require.NoError(t, err)
+		assert.Equal(t, int64(i), nodeId)
+	}
+}
+
+// TestMembershipAllocator_NotMember 测试不在成员列表中时返回ErrNotMember
+func TestMembershipAllocator_NotMember(t *testing.T) {
+	_, err := NewMembershipAllocator([]string{"node-a", "node-b"}, "node-z").Alloc()
+	assert.True(t, errors.Is(err, ErrNotMember))
+	assert.Contains(t, err.Error(), "node-z")
+
+	_, err = NewMembershipAllocator(nil, "node-a").Alloc()
+	assert.True(t, errors.Is(err, ErrNotMember))
+}
+
+// TestMembershipAllocator_OutOfRange 测试下标超出节点ID数量时返回错误
+func TestMembershipAllocator_OutOfRange(t *testing.T) {
+	defer func(bits uint8) { snowflake.NodeBits = bits }(snowflake.NodeBits)
+	snowflake.NodeBits = 2
+
+	members := make([]string, 5)
+	for i := range members {
+		members[i] = fmt.Sprintf("node-%d", i)
+	}
+	nodeId, err := NewMembershipAllocator(members, "node-3").Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), nodeId)
+
+	_, err = NewMembershipAllocator(members, "node-4").Alloc()
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrNotMember))
+	assert.Contains(t, err.Error(), "exceeds the node id limit 3")
+}
+
+// TestMembershipAllocator_Migration 测试成员节点ID不可漂移
+func TestMembershipAllocator_Migration(t *testing.T) {
+	_, err := NewMembershipAllocator([]string{"node-a"}, "node-a").Migration(0)
+	assert.True(t, errors.Is(err, ErrMigrationExhausted))
+}
@@ -15,6 +15,8 @@ import (
 
 // RandNodeIdAllocator 随机节点ID分配器
 type RandNodeIdAllocator struct {
+	// modulus 节点ID的取模数，0表示使用当前位布局下的全部节点ID
+	modulus int64
 }
 
 // NewRandNodeIdAllocator 创建一个随机节点ID分配器
@@ -23,12 +25,27 @@ func NewRandNodeIdAllocator() snowflake.NodeIdAllocator {
 	return &RandNodeIdAllocator{}
 }
 
+// NewRandNodeIdAllocatorWithModulus 创建一个在[0, modulus)内随机分配节点ID的分配器，模数可以不是2的幂
+// @param modulus
+// @return snowflake.NodeIdAllocator
+// @return error 模数不在[1, 1<<snowflake.NodeBits]内时返回ErrInvalidModulus
+func NewRandNodeIdAllocatorWithModulus(modulus int64) (snowflake.NodeIdAllocator, error) {
+	if err := validateModulus(modulus); err != nil {
+		return nil, err
+	}
+	return &RandNodeIdAllocator{modulus: modulus}, nil
+}
+
 // Alloc 分配一个随机节点ID
 // @receiver n
 // @return nodeId
 // @return err
 func (n *RandNodeIdAllocator) Alloc() (nodeId int64, err error) {
-	return rand.Int64N(1023), nil
+	slots, err := moduloSlots(n.modulus)
+	if err != nil {
+		return 0, err
+	}
+	return rand.Int64N(slots), nil
 }
 
 // Migration 节点ID漂移
@@ -37,5 +54,5 @@ func (n *RandNodeIdAllocator) Alloc() (nodeId int64, err error) {
 // @return newNodeId
 // @return err
 func (n *RandNodeIdAllocator) Migration(_ int64) (newNodeId int64, err error) {
-	return rand.Int64N(1023), nil
+	return n.Alloc()
 }
@@ -8,6 +8,7 @@
 package nodeid
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,7 +28,7 @@ func TestRandNodeIdAllocator_Alloc_Range(t *testing.T) {
 		nodeId, err := allocator.Alloc()
 		assert.NoError(t, err)
 		assert.GreaterOrEqual(t, nodeId, int64(0))
-		assert.LessOrEqual(t, nodeId, int64(1023))
+		assert.LessOrEqual(t, nodeId, int64(MaxNodes-1))
 	}
 }
 
@@ -81,13 +82,13 @@ func TestRandNodeIdAllocator_Alloc_DifferentAllocators(t *testing.T) {
 func TestRandNodeIdAllocator_Migration_Range(t *testing.T) {
 	allocator := NewRandNodeIdAllocator()
 
-	testNodeIds := []int64{0, 100, 512, 1023, 555}
+	testNodeIds := []int64{0, 100, 512, MaxNodes - 1, 555}
 
 	for _, oldNodeId := range testNodeIds {
 		newNodeId, err := allocator.Migration(oldNodeId)
 		assert.NoError(t, err)
 		assert.GreaterOrEqual(t, newNodeId, int64(0))
-		assert.LessOrEqual(t, newNodeId, int64(1023))
+		assert.LessOrEqual(t, newNodeId, int64(MaxNodes-1))
 	}
 }
 
@@ -127,3 +128,21 @@ func TestRandNodeIdAllocator_Migration_DifferentFromOriginal(t *testing.T) {
 	// 极大概率会有不同的结果
 	assert.Greater(t, diffCount, 50)
 }
+
+// TestRandNodeIdAllocator_Modulus 测试指定模数时节点ID均落在[0, modulus)内
+func TestRandNodeIdAllocator_Modulus(t *testing.T) {
+	allocator, err := NewRandNodeIdAllocatorWithModulus(1000)
+	assert.NoError(t, err)
+	for i := 0; i < 5000; i++ {
+		nodeId, err := allocator.Alloc()
+		assert.NoError(t, err)
+		assert.True(t, nodeId >= 0 && nodeId < 1000, "node id %d", nodeId)
+
+		migrated, err := allocator.Migration(nodeId)
+		assert.NoError(t, err)
+		assert.True(t, migrated >= 0 && migrated < 1000, "migrated node id %d", migrated)
+	}
+
+	_, err = NewRandNodeIdAllocatorWithModulus(2048)
+	assert.True(t, errors.Is(err, ErrInvalidModulus))
+}
@@ -0,0 +1,96 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 限定范围的节点ID分配器
+package nodeid
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// NodeIdRange 节点ID范围，包含Min与Max
+type NodeIdRange struct {
+	Min int64
+	Max int64
+}
+
+// Size 范围内的节点ID数量
+func (r NodeIdRange) Size() int64 {
+	return r.Max - r.Min + 1
+}
+
+// Contains 节点ID是否在范围内
+func (r NodeIdRange) Contains(nodeId int64) bool {
+	return nodeId >= r.Min && nodeId <= r.Max
+}
+
+// validate 校验范围是否位于当前位布局的节点ID空间内
+func (r NodeIdRange) validate() error {
+	if r.Min < 0 || r.Max < r.Min || r.Max >= nodeSlots() {
+		return fmt.Errorf("invalid node id range [%d, %d], node id must be between 0 and %d", r.Min, r.Max,
+			nodeSlots()-1)
+	}
+	return nil
+}
+
+// RangeNodeIdAllocator 将其他分配器的结果映射到指定范围内的节点ID分配器
+type RangeNodeIdAllocator struct {
+	allocator snowflake.NodeIdAllocator
+	nodeRange NodeIdRange
+}
+
+// NewRangeNodeIdAllocator 创建一个限定范围的节点ID分配器
+// @param allocator 被包装的分配器
+// @param nodeRange 节点ID范围
+// @return snowflake.NodeIdAllocator
+func NewRangeNodeIdAllocator(allocator snowflake.NodeIdAllocator, nodeRange NodeIdRange) snowflake.NodeIdAllocator {
+	return &RangeNodeIdAllocator{allocator: allocator, nodeRange: nodeRange}
+}
+
+// Alloc 分配一个范围内的节点ID
+// @receiver n
+// @return nodeId
+// @return err
+func (n *RangeNodeIdAllocator) Alloc() (int64, error) {
+	if err := n.nodeRange.validate(); err != nil {
+		return 0, err
+	}
+	nodeId, err := n.allocator.Alloc()
+	if err != nil {
+		return 0, err
+	}
+	return n.nodeRange.Min + nodeId%n.nodeRange.Size(), nil
+}
+
+// Migration 节点ID漂移，漂移后的节点ID仍在范围内且与原节点ID不同，范围内只有一个节点ID时返回ErrMigrationExhausted
+// @receiver n
+// @param nodeId
+// @return newNodeId
+// @return err
+func (n *RangeNodeIdAllocator) Migration(nodeId int64) (int64, error) {
+	if err := n.nodeRange.validate(); err != nil {
+		return 0, err
+	}
+	size := n.nodeRange.Size()
+	if size <= 1 {
+		return 0, ErrMigrationExhausted
+	}
+	offset := (nodeId - n.nodeRange.Min) % size
+	if offset < 0 {
+		offset += size
+	}
+
+	migrated, err := n.allocator.Migration(offset)
+	if err != nil {
+		return 0, err
+	}
+	if migrated %= size; migrated == offset {
+		migrated = (offset + 1) % size
+	}
+	return n.nodeRange.Min + migrated, nil
+}
@@ -0,0 +1,50 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 限定范围的节点ID分配器测试
+package nodeid
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRangeNodeIdAllocator_Alloc 测试分配的节点ID在范围内
+func TestRangeNodeIdAllocator_Alloc(t *testing.T) {
+	nodeRange := NodeIdRange{Min: 512, Max: 767}
+	for i := 0; i < 200; i++ {
+		allocator := NewRangeNodeIdAllocator(NewHashNodeIdAllocator(fmt.Sprintf("key-%d", i)), nodeRange)
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		assert.True(t, nodeRange.Contains(nodeId), "node id %d", nodeId)
+	}
+}
+
+// TestRangeNodeIdAllocator_Migration 测试漂移后的节点ID仍在范围内且与原节点ID不同
+func TestRangeNodeIdAllocator_Migration(t *testing.T) {
+	nodeRange := NodeIdRange{Min: 768, Max: MaxNodes - 1}
+	allocator := NewRangeNodeIdAllocator(NewHashNodeIdAllocator("key"), nodeRange)
+	for nodeId := nodeRange.Min; nodeId <= nodeRange.Max; nodeId++ {
+		migrated, err := allocator.Migration(nodeId)
+		require.NoError(t, err)
+		assert.True(t, nodeRange.Contains(migrated), "node id %d", migrated)
+		assert.NotEqual(t, nodeId, migrated)
+	}
+}
+
+// TestRangeNodeIdAllocator_InvalidRange 测试超出节点ID空间的范围
+func TestRangeNodeIdAllocator_InvalidRange(t *testing.T) {
+	for _, nodeRange := range []NodeIdRange{{Min: -1, Max: 10}, {Min: 10, Max: 5}, {Min: 0, Max: MaxNodes}} {
+		allocator := NewRangeNodeIdAllocator(NewHashNodeIdAllocator("key"), nodeRange)
+		_, err := allocator.Alloc()
+		assert.Error(t, err)
+		_, err = allocator.Migration(0)
+		assert.Error(t, err)
+	}
+}
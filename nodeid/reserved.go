@@ -0,0 +1,77 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 跳过保留节点ID的分配器
+package nodeid
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// ReservedNodeIdAllocator 跳过保留节点ID的分配器，保留的节点ID可留给管理、测试等用途
+type ReservedNodeIdAllocator struct {
+	allocator snowflake.NodeIdAllocator
+	reserved  map[int64]struct{}
+}
+
+// NewReservedNodeIdAllocator 创建一个跳过保留节点ID的分配器
+// 被包装的分配器得到保留的节点ID时继续漂移，直到得到未保留的节点ID
+// @param allocator 被包装的分配器
+// @param reserved 保留的节点ID
+// @return snowflake.NodeIdAllocator
+func NewReservedNodeIdAllocator(allocator snowflake.NodeIdAllocator, reserved []int64) snowflake.NodeIdAllocator {
+	set := make(map[int64]struct{}, len(reserved))
+	for _, nodeId := range reserved {
+		set[nodeId] = struct{}{}
+	}
+	return &ReservedNodeIdAllocator{allocator: allocator, reserved: set}
+}
+
+// Alloc 分配一个未保留的节点ID
+// @receiver n
+// @return nodeId
+// @return err
+func (n *ReservedNodeIdAllocator) Alloc() (int64, error) {
+	nodeId, err := n.allocator.Alloc()
+	if err != nil {
+		return 0, err
+	}
+	return n.skip(nodeId)
+}
+
+// Migration 节点ID漂移，漂移后的节点ID未保留且与原节点ID不同
+// @receiver n
+// @param nodeId
+// @return newNodeId
+// @return err
+func (n *ReservedNodeIdAllocator) Migration(nodeId int64) (int64, error) {
+	migrated, err := n.allocator.Migration(nodeId)
+	if err != nil {
+		return 0, err
+	}
+	return n.skip(migrated)
+}
+
+// skip 节点ID被保留时沿被包装分配器的漂移链继续漂移，漂移链回到已尝试过的节点ID时返回ErrMigrationExhausted
+func (n *ReservedNodeIdAllocator) skip(nodeId int64) (int64, error) {
+	visited := make(map[int64]struct{})
+	for {
+		if _, ok := n.reserved[nodeId]; !ok {
+			return nodeId, nil
+		}
+		if _, ok := visited[nodeId]; ok {
+			return 0, fmt.Errorf("%w: every node id on the migration path is reserved", ErrMigrationExhausted)
+		}
+		visited[nodeId] = struct{}{}
+
+		var err error
+		if nodeId, err = n.allocator.Migration(nodeId); err != nil {
+			return 0, err
+		}
+	}
+}
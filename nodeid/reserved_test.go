@@ -0,0 +1,69 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 跳过保留节点ID的分配器测试
+package nodeid
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reservedRange 生成[min, max]内的节点ID
+func reservedRange(min, max int64) []int64 {
+	reserved := make([]int64, 0, max-min+1)
+	for nodeId := min; nodeId <= max; nodeId++ {
+		reserved = append(reserved, nodeId)
+	}
+	return reserved
+}
+
+// TestReservedNodeIdAllocator 测试大量key分配与漂移的节点ID均不落在保留范围内
+func TestReservedNodeIdAllocator(t *testing.T) {
+	reserved := NodeIdRange{Min: 0, Max: 15}
+	landed := 0
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if hashed, _ := NewHashNodeIdAllocator(key).Alloc(); reserved.Contains(hashed) {
+			landed++
+		}
+		allocator := NewReservedNodeIdAllocator(NewHashNodeIdAllocator(key), reservedRange(reserved.Min, reserved.Max))
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		assert.False(t, reserved.Contains(nodeId), "key %s allocated reserved node id %d", key, nodeId)
+
+		migrated, err := allocator.Migration(nodeId)
+		require.NoError(t, err)
+		assert.False(t, reserved.Contains(migrated), "key %s migrated to reserved node id %d", key, migrated)
+		assert.NotEqual(t, nodeId, migrated)
+	}
+	// 确认存在原本会落在保留范围内的key
+	assert.Greater(t, landed, 0)
+}
+
+// TestReservedNodeIdAllocator_Range 测试与限定范围的分配器组合时，跳过保留节点ID后仍在范围内
+func TestReservedNodeIdAllocator_Range(t *testing.T) {
+	nodeRange := NodeIdRange{Min: 0, Max: 31}
+	for i := 0; i < 500; i++ {
+		allocator := NewReservedNodeIdAllocator(NewRangeNodeIdAllocator(NewHashNodeIdAllocator(fmt.Sprintf("key-%d", i)),
+			nodeRange), reservedRange(0, 15))
+		nodeId, err := allocator.Alloc()
+		require.NoError(t, err)
+		assert.True(t, nodeId >= 16 && nodeId <= 31, "node id %d", nodeId)
+	}
+}
+
+// TestReservedNodeIdAllocator_Exhausted 测试所有节点ID均被保留时返回ErrMigrationExhausted
+func TestReservedNodeIdAllocator_Exhausted(t *testing.T) {
+	allocator := NewReservedNodeIdAllocator(NewRangeNodeIdAllocator(NewHashNodeIdAllocator("key"),
+		NodeIdRange{Min: 0, Max: 3}), reservedRange(0, 3))
+	_, err := allocator.Alloc()
+	assert.True(t, errors.Is(err, ErrMigrationExhausted))
+}
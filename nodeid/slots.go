@@ -0,0 +1,56 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 节点ID槽位
+package nodeid
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrInvalidModulus 节点ID模数超出当前位布局下的节点ID数量
+var ErrInvalidModulus = errors.New("invalid node id modulus")
+
+const (
+	// DefaultNodeBits snowflake包默认的节点ID位数
+	DefaultNodeBits = 10
+	// MaxNodes 默认位布局下的节点ID数量，节点ID范围为[0, MaxNodes-1]；修改snowflake.NodeBits后以NodeIDSpace为准
+	MaxNodes = 1 << DefaultNodeBits
+)
+
+// NodeIDSpace 当前位布局下的节点ID数量，跟随snowflake.NodeBits变化，哈希与随机分配器的节点ID均小于该值
+// @return int64
+func NodeIDSpace() int64 {
+	return nodeSlots()
+}
+
+// nodeSlots 当前位布局下可用的节点ID数量，跟随snowflake.NodeBits变化
+func nodeSlots() int64 {
+	return int64(1) << snowflake.NodeBits
+}
+
+// validateModulus 校验模数在[1, nodeSlots()]内
+func validateModulus(modulus int64) error {
+	if modulus < 1 || modulus > nodeSlots() {
+		return fmt.Errorf("%w: %d is out of range [1, %d]", ErrInvalidModulus, modulus, nodeSlots())
+	}
+	return nil
+}
+
+// moduloSlots 分配器取模使用的槽位数，modulus为0时跟随位布局
+// 创建后位布局缩小导致模数超出节点ID数量时返回ErrInvalidModulus
+func moduloSlots(modulus int64) (int64, error) {
+	if modulus == 0 {
+		return nodeSlots(), nil
+	}
+	if err := validateModulus(modulus); err != nil {
+		return 0, err
+	}
+	return modulus, nil
+}
@@ -0,0 +1,53 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package nodeid 节点ID空间测试
+package nodeid
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMaxNodes 测试MaxNodes与默认节点ID位数一致，NodeIDSpace跟随位布局变化
+func TestMaxNodes(t *testing.T) {
+	assert.Equal(t, uint8(DefaultNodeBits), snowflake.NodeBits)
+	assert.Equal(t, int64(1)<<snowflake.NodeBits, int64(MaxNodes))
+	assert.Equal(t, int64(MaxNodes), NodeIDSpace())
+
+	defer func(bits uint8) { snowflake.NodeBits = bits }(snowflake.NodeBits)
+	snowflake.NodeBits = 8
+	assert.Equal(t, int64(256), NodeIDSpace())
+}
+
+// TestNodeIDSpace_Allocators 测试哈希与随机分配器分配与漂移的节点ID均在节点ID空间内
+func TestNodeIDSpace_Allocators(t *testing.T) {
+	for _, bits := range []uint8{DefaultNodeBits, 6} {
+		func() {
+			defer func(bits uint8) { snowflake.NodeBits = bits }(snowflake.NodeBits)
+			snowflake.NodeBits = bits
+			space := NodeIDSpace()
+
+			for i := 0; i < 500; i++ {
+				for _, allocator := range []snowflake.NodeIdAllocator{
+					NewHashNodeIdAllocator(fmt.Sprintf("key-%d", i)), NewRandNodeIdAllocator(),
+				} {
+					nodeId, err := allocator.Alloc()
+					require.NoError(t, err)
+					assert.True(t, nodeId >= 0 && nodeId < space, "bits %d, node id %d", bits, nodeId)
+
+					migrated, err := allocator.Migration(nodeId)
+					require.NoError(t, err)
+					assert.True(t, migrated >= 0 && migrated < space, "bits %d, migrated node id %d", bits, migrated)
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,238 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 可选配置
+package snowflake
+
+import (
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+	"gorm.io/gorm"
+)
+
+// DefaultSyncInterval 时间同步器默认的写入间隔
+const DefaultSyncInterval = time.Second
+
+// DefaultClusterOrderingMaxLead WithClusterOrdering默认允许ID时间戳超前本机时钟的时长
+const DefaultClusterOrderingMaxLead = time.Second
+
+// Option 雪花算法可选配置
+type Option struct {
+	// nodeIdOptions gorm节点ID分配器与时间同步器的可选配置
+	nodeIdOptions []nodeidgorm.OptionFn
+	// encoding GenerateString使用的编码
+	encoding Encoding
+	// minimumID 生成的ID必须大于该值，0表示不限制
+	minimumID int64
+	// pauseSynchronizer Pause时是否同时暂停时间同步器
+	pauseSynchronizer bool
+	// coordinationDB 节点ID注册表所在的协调数据库，nil表示使用NewSnowflake传入的数据库
+	coordinationDB *gorm.DB
+	// autoMigrate 创建时是否在协调数据库上迁移snowflake_kv表结构
+	autoMigrate bool
+	// allocator 注入的节点ID分配器，nil表示使用gorm节点ID分配器
+	allocator snowflake.NodeIdAllocator
+	// synchronizer 注入的时间同步器，nil表示使用gorm时间同步器
+	synchronizer snowflake.TimeSynchronizer
+	// datacenterBits 节点ID中数据中心ID的位数，0表示不划分数据中心
+	datacenterBits uint8
+	// datacenterId 数据中心ID，-1表示从环境变量SNOWFLAKE_DATACENTER_ID读取
+	datacenterId int64
+	// typeBits 节点ID中类型前缀的位数，0表示不使用类型前缀
+	typeBits uint8
+	// typeValue 类型前缀
+	typeValue int64
+	// duplicateWindow 重复ID检测窗口大小，0表示不检测
+	duplicateWindow int
+	// syncInterval 时间同步器写入数据库的间隔
+	syncInterval time.Duration
+	// asyncLogBuffer 异步日志的缓冲区大小，0表示同步记录日志
+	asyncLogBuffer int
+	// orderingInterval 协调集群时间下限的间隔，0表示不协调
+	orderingInterval time.Duration
+	// orderingMaxLead 为追上集群时间下限，生成的ID时间戳最多超前本机时钟的时长
+	orderingMaxLead time.Duration
+}
+
+// OptionFn 可选配置函数
+type OptionFn func(op *Option)
+
+// WithNodeIdOptions 设置gorm节点ID分配器与时间同步器的可选配置
+// @param opts
+// @return OptionFn
+func WithNodeIdOptions(opts ...nodeidgorm.OptionFn) OptionFn {
+	return func(op *Option) {
+		op.nodeIdOptions = append(op.nodeIdOptions, opts...)
+	}
+}
+
+// WithEncoding 设置GenerateString使用的编码，默认十进制
+// @param encoding
+// @return OptionFn
+func WithEncoding(encoding Encoding) OptionFn {
+	return func(op *Option) {
+		op.encoding = encoding
+	}
+}
+
+// WithMinimumID 设置生成的ID必须大于floor，用于从自增主键迁移到雪花ID时保持同一列的顺序
+// 创建时校验当前时间生成的ID已大于floor，否则返回ErrMinimumIDUnreachable，可通过更早的纪元满足
+// @param floor 现有自增主键的最大值
+// @return OptionFn
+func WithMinimumID(floor int64) OptionFn {
+	return func(op *Option) {
+		op.minimumID = floor
+	}
+}
+
+// WithPauseSynchronizer 设置Pause时是否同时暂停时间同步器向数据库写入时间，默认不暂停
+// 暂停时间同步器可避免维护窗口内写入数据库，但暂停时间超过抢占时间间隔后节点ID可能被其他实例抢占，恢复时需调用Refresh
+// @param enabled
+// @return OptionFn
+func WithPauseSynchronizer(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.pauseSynchronizer = enabled
+	}
+}
+
+// WithCoordinationDB 设置节点ID注册表所在的协调数据库，默认使用NewSnowflake传入的数据库
+// 适用于业务数据与节点ID注册表分库的场景，多个服务可共享一个小型协调数据库
+// @param db
+// @return OptionFn
+func WithCoordinationDB(db *gorm.DB) OptionFn {
+	return func(op *Option) {
+		op.coordinationDB = db
+	}
+}
+
+// WithAutoMigrate 设置创建时是否在协调数据库上迁移snowflake_kv表结构，默认不迁移
+// @param enabled
+// @return OptionFn
+func WithAutoMigrate(enabled bool) OptionFn {
+	return func(op *Option) {
+		op.autoMigrate = enabled
+	}
+}
+
+// WithAllocator 注入节点ID分配器，替代基于数据库的gorm节点ID分配器
+// 同时注入时间同步器时无需数据库，NewSnowflake的db可以为nil；分配器实现了Refresh、OnNodeIdChange、Clock、Close等方法时，
+// Wrapper的同名方法会调用它们
+// @param allocator
+// @return OptionFn
+func WithAllocator(allocator snowflake.NodeIdAllocator) OptionFn {
+	return func(op *Option) {
+		op.allocator = allocator
+	}
+}
+
+// WithSynchronizer 注入时间同步器，替代基于数据库的gorm时间同步器
+// 同时注入节点ID分配器时无需数据库；时间同步器实现了Run时在创建时调用，实现了Stop、Pause、Resume、LastSyncAge时由Wrapper转发
+// @param synchronizer
+// @return OptionFn
+func WithSynchronizer(synchronizer snowflake.TimeSynchronizer) OptionFn {
+	return func(op *Option) {
+		op.synchronizer = synchronizer
+	}
+}
+
+// WithDatacenterBits 将节点ID的高bits位划分为数据中心ID，默认为0，即不划分
+// 节点ID由(datacenterID << workerBits) | workerID组成，workerBits为节点ID位数减去bits；
+// 数据中心ID由WithDatacenterID或环境变量SNOWFLAKE_DATACENTER_ID指定，工作节点ID由分配器在数据中心的范围内分配
+// @param bits
+// @return OptionFn
+func WithDatacenterBits(bits uint8) OptionFn {
+	return func(op *Option) {
+		op.datacenterBits = bits
+	}
+}
+
+// WithDatacenterID 设置数据中心ID，需配合WithDatacenterBits使用，未设置时从环境变量SNOWFLAKE_DATACENTER_ID读取
+// @param id
+// @return OptionFn
+func WithDatacenterID(id int64) OptionFn {
+	return func(op *Option) {
+		op.datacenterId = id
+	}
+}
+
+// WithTypePrefix 将节点ID的最高bits位固定为类型前缀value，默认为0，即不使用类型前缀
+// 用于在ID中编码实体类型以按类型路由或分片，分配器可用的节点ID空间相应缩小为原来的1/2^bits；
+// 同时配置WithDatacenterBits时数据中心ID位于类型前缀之后，两者位数之和需小于节点ID位数
+// @param bits
+// @param value
+// @return OptionFn
+func WithTypePrefix(bits uint8, value int64) OptionFn {
+	return func(op *Option) {
+		op.typeBits = bits
+		op.typeValue = value
+	}
+}
+
+// WithDuplicateDetection 开启重复ID检测，用于调试，默认关闭
+// 进程内所有开启检测的Wrapper共享一个最近windowSize个ID的窗口，生成的ID已在窗口中时调用OnDuplicate注册的回调，
+// 未注册回调时panic；窗口需要额外的内存且每次生成都需加锁，不应在生产环境开启
+// @param windowSize
+// @return OptionFn
+func WithDuplicateDetection(windowSize int) OptionFn {
+	return func(op *Option) {
+		op.duplicateWindow = windowSize
+	}
+}
+
+// WithSyncInterval 设置时间同步器写入数据库的间隔，默认为DefaultSyncInterval，与时钟回拨容忍时间无关
+// 间隔越短，重启时保存的时间越接近最后生成的ID，数据库写入也越频繁；小于等于0时沿用默认值
+// @param interval
+// @return OptionFn
+func WithSyncInterval(interval time.Duration) OptionFn {
+	return func(op *Option) {
+		op.syncInterval = interval
+	}
+}
+
+// WithAsyncLogging 开启异步日志，分配器与时间同步器的日志写入大小为bufferSize的缓冲区后由后台goroutine输出，
+// 注入的Logger变慢时不会阻塞时间同步与节点ID分配；缓冲区已满时丢弃日志，丢弃数见DroppedLogs，默认同步记录日志
+// @param bufferSize 小于等于0时不开启
+// @return OptionFn
+func WithAsyncLogging(bufferSize int) OptionFn {
+	return func(op *Option) {
+		op.asyncLogBuffer = bufferSize
+	}
+}
+
+// WithClusterOrdering 开启尽力而为的集群范围递增：每隔interval从注册表协调集群已生成ID的时间下限，
+// 本机时钟落后于下限时以下限作为ID的时间戳，不低于集群中已见到的最大时间，以每次生成加锁与定期查询换取更强的全局顺序
+// 限制：下限来自各实例时间同步器写入的时间，最多落后一个同步间隔加一个协调间隔，该容忍范围内仍可能乱序；
+// 同一毫秒内不同节点的ID按节点ID排序；时间戳最多超前本机时钟maxLead，时钟超前更多的实例无法被追上；
+// 注入的分配器不支持查询注册表时不生效；Registry中的生成器与BufferedSnowflake缓冲区中已生成的ID不受约束
+// @param interval 小于等于0时不开启
+// @param maxLead 小于等于0时为1秒
+// @return OptionFn
+func WithClusterOrdering(interval, maxLead time.Duration) OptionFn {
+	return func(op *Option) {
+		op.orderingInterval = interval
+		op.orderingMaxLead = maxLead
+	}
+}
+
+// newOption 应用可选配置
+func newOption(opts ...OptionFn) *Option {
+	op := &Option{
+		encoding:     EncodingDecimal,
+		datacenterId: -1,
+	}
+	for _, opt := range opts {
+		opt(op)
+	}
+	if op.syncInterval <= 0 {
+		op.syncInterval = DefaultSyncInterval
+	}
+	if op.orderingInterval > 0 && op.orderingMaxLead <= 0 {
+		op.orderingMaxLead = DefaultClusterOrderingMaxLead
+	}
+	return op
+}
@@ -0,0 +1,154 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 集群范围递增
+package snowflake
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+)
+
+// clusterOrdering 定期通过注册表协调集群的时间下限，生成的ID时间戳不低于下限
+// 开启后ID由clusterOrdering自行拼装，不再经过snowflake.Node，时间戳取本机时钟与下限中的较大者
+type clusterOrdering struct {
+	ctx      context.Context
+	interval time.Duration
+	maxLead  time.Duration
+	logger   nodeidgorm.Logger
+	// floor 集群已生成ID的时间下限，毫秒时间戳
+	floor int64
+
+	// epoch 纪元，携带单调时钟读数，时钟回拨时生成的时间戳不会回退
+	epoch    time.Time
+	stepMask int64
+	mu       sync.Mutex
+	time     int64
+	step     int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// startClusterOrdering 立即协调一次时间下限，然后在后台每隔interval协调
+func (w *Wrapper) startClusterOrdering(ctx context.Context, interval, maxLead time.Duration,
+	logger nodeidgorm.Logger) {
+	now := time.Now()
+	o := &clusterOrdering{
+		ctx:      ctx,
+		interval: interval,
+		maxLead:  maxLead,
+		logger:   logger,
+		epoch:    now.Add(time.UnixMilli(snowflake.Epoch).Sub(now)),
+		stepMask: -1 ^ (-1 << snowflake.StepBits),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.ordering = o
+	w.coordinateFloor()
+	go w.coordinateLoop()
+}
+
+// coordinateLoop 每隔协调间隔刷新时间下限，直到Close或context结束
+func (w *Wrapper) coordinateLoop() {
+	o := w.ordering
+	defer close(o.done)
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.coordinateFloor()
+		case <-o.stop:
+			return
+		case <-o.ctx.Done():
+			return
+		}
+	}
+}
+
+// coordinateFloor 查询注册表中的最大时间并更新时间下限，下限只增不减
+// 注入的分配器不支持查询时跳过
+func (w *Wrapper) coordinateFloor() {
+	o := w.ordering
+	querier, ok := w.currentAllocator().(interface {
+		ClusterFloor(ctx context.Context) (int64, error)
+	})
+	if !ok {
+		return
+	}
+	floor, err := querier.ClusterFloor(o.ctx)
+	if err != nil {
+		o.logger.Warnf("coordinate cluster floor failed. error: %v", err)
+		return
+	}
+	for {
+		current := atomic.LoadInt64(&o.floor)
+		if floor <= current || atomic.CompareAndSwapInt64(&o.floor, current, floor) {
+			return
+		}
+	}
+}
+
+// generate 以本机时钟与集群时间下限中的较大者为时间戳生成ID，时间戳最多超前本机时钟maxLead
+// 同一毫秒内序列号递增，序列号用尽时时间戳前进一毫秒，前进后超出maxLead时自旋等待本机时钟追上；
+// 生成的时间写入时间同步器，重启后的回拨检测覆盖超前的时间戳
+func (o *clusterOrdering) generate(nodeId int64, synchronizer snowflake.TimeSynchronizer) snowflake.ID {
+	o.mu.Lock()
+	now := time.Since(o.epoch).Milliseconds()
+	ts := now
+	if floor := atomic.LoadInt64(&o.floor) - snowflake.Epoch; floor > ts {
+		if lead := now + o.maxLead.Milliseconds(); floor > lead {
+			floor = lead
+		}
+		ts = floor
+	}
+	if ts <= o.time {
+		ts = o.time
+		if o.step = (o.step + 1) & o.stepMask; o.step == 0 {
+			ts++
+			for lead := o.maxLead.Milliseconds(); ts > now+lead; {
+				now = time.Since(o.epoch).Milliseconds()
+			}
+		}
+	} else {
+		o.step = 0
+	}
+	o.time = ts
+	id := snowflake.ID(ts<<(snowflake.NodeBits+snowflake.StepBits) | nodeId<<snowflake.StepBits | o.step)
+	o.mu.Unlock()
+
+	if synchronizer != nil {
+		synchronizer.Async(id.Time())
+	}
+	return id
+}
+
+// stopOrdering 停止协调并等待后台goroutine退出
+func (o *clusterOrdering) stopOrdering() {
+	o.stopOnce.Do(func() {
+		close(o.stop)
+	})
+	<-o.done
+}
+
+// ClusterFloor 最近一次协调得到的集群时间下限，未开启WithClusterOrdering或尚未协调到时返回零值
+// @return time.Time
+func (w *Wrapper) ClusterFloor() time.Time {
+	if w.ordering == nil {
+		return time.Time{}
+	}
+	if floor := atomic.LoadInt64(&w.ordering.floor); floor > 0 {
+		return time.UnixMilli(floor)
+	}
+	return time.Time{}
+}
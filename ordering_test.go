@@ -0,0 +1,107 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 集群范围递增测试
+package snowflake
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestWithClusterOrdering 测试时钟落后的生成器以集群时间下限为时间戳，两个生成器交替生成的ID在协调容忍范围内不递减
+func TestWithClusterOrdering(t *testing.T) {
+	const (
+		interval  = 10 * time.Millisecond
+		ahead     = 200 * time.Millisecond
+		tolerance = 100 * time.Millisecond
+		rounds    = 10
+	)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ordering.db")))
+	require.NoError(t, err)
+	ctx := context.Background()
+	opts := []OptionFn{WithAutoMigrate(true), WithSyncInterval(interval), WithClusterOrdering(interval, time.Second)}
+
+	behind, err := NewSnowflake(ctx, db, "test_ordering_behind", 8080, time.Second, 5*time.Second, logger, opts...)
+	require.NoError(t, err)
+	defer behind.Close()
+	// 创建节点时提前纪元，该生成器的ID时间戳比系统时间超前ahead
+	epoch := snowflake.Epoch
+	snowflake.Epoch -= ahead.Milliseconds()
+	aheadNode, err := NewSnowflake(ctx, db, "test_ordering_ahead", 8080, time.Second, 5*time.Second, logger, opts...)
+	snowflake.Epoch = epoch
+	require.NoError(t, err)
+	defer aheadNode.Close()
+
+	// 等待超前的生成器写入水位并被协调为集群时间下限
+	first := aheadNode.Generate()
+	require.NoError(t, aheadNode.FlushNow(ctx))
+	require.Eventually(t, func() bool {
+		return behind.ClusterFloor().UnixMilli() >= first.Time()
+	}, time.Second, interval)
+
+	var ids []snowflake.ID
+	for i := 0; i < rounds; i++ {
+		ids = append(ids, aheadNode.Generate(), behind.Generate())
+	}
+	latest := int64(0)
+	for i, id := range ids {
+		assert.GreaterOrEqual(t, id.Time(), latest-tolerance.Milliseconds(), "id %d", i)
+		if id.Time() > latest {
+			latest = id.Time()
+		}
+	}
+	// 时钟落后的生成器生成的ID时间戳超前于系统时间
+	assert.Greater(t, behind.Generate().Time(), time.Now().UnixMilli()+(ahead-tolerance).Milliseconds())
+
+	// 序列号用尽时时间戳前进，同一生成器的ID严格递增
+	previous := behind.Generate()
+	for i := 0; i < 10000; i++ {
+		id := behind.Generate()
+		require.Greater(t, int64(id), int64(previous))
+		assert.Equal(t, behind.NodeId(), id.Node())
+		previous = id
+	}
+}
+
+// TestWithClusterOrdering_Burst 测试持续超过每毫秒序列号上限地生成时，时间戳不超前本机时钟maxLead
+func TestWithClusterOrdering_Burst(t *testing.T) {
+	const maxLead = 10 * time.Millisecond
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "burst.db")))
+	require.NoError(t, err)
+	sf, err := NewSnowflake(context.Background(), db, "test_ordering_burst", 8080, time.Second, 5*time.Second, logger,
+		WithAutoMigrate(true), WithClusterOrdering(time.Second, maxLead))
+	require.NoError(t, err)
+	defer sf.Close()
+	// 每毫秒只有4个序列号，单个goroutine即可持续超出上限
+	sf.ordering.stepMask = 3
+
+	previous := sf.Generate()
+	for i := 0; i < 2000; i++ {
+		id := sf.Generate()
+		require.LessOrEqual(t, id.Time(), time.Now().UnixMilli()+maxLead.Milliseconds(), "id %d", i)
+		require.Greater(t, int64(id), int64(previous))
+		previous = id
+	}
+}
+
+// TestWithClusterOrdering_Disabled 测试未开启时不协调时间下限
+func TestWithClusterOrdering_Disabled(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_ordering", 8080, time.Second, 5*time.Second,
+		logger)
+	require.NoError(t, err)
+	defer sf.Close()
+	assert.True(t, sf.ClusterFloor().IsZero())
+	assert.Equal(t, DefaultClusterOrderingMaxLead, newOption(WithClusterOrdering(time.Second, 0)).orderingMaxLead)
+}
@@ -0,0 +1,60 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 暂停与恢复
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrPaused 生成已暂停
+var ErrPaused = errors.New("snowflake generation is paused")
+
+// Pause 暂停生成，用于维护窗口，暂停期间GenerateContext与GORM回调返回ErrPaused
+// 配置了WithPauseSynchronizer时同时暂停时间同步器
+// 注意：Generate没有错误返回值，暂停期间仍会生成ID，需要感知暂停的调用方应使用GenerateContext
+func (w *Wrapper) Pause() {
+	if atomic.CompareAndSwapInt32(&w.paused, 0, 1) && w.pauseSynchronizer {
+		if pauser, ok := w.synchronizer.(interface{ Pause() }); ok {
+			pauser.Pause()
+		}
+	}
+}
+
+// Resume 恢复生成，无需重新创建雪花算法
+func (w *Wrapper) Resume() {
+	if atomic.CompareAndSwapInt32(&w.paused, 1, 0) && w.pauseSynchronizer {
+		if resumer, ok := w.synchronizer.(interface{ Resume() }); ok {
+			resumer.Resume()
+		}
+	}
+}
+
+// Paused 是否已暂停生成
+// @return bool
+func (w *Wrapper) Paused() bool {
+	return atomic.LoadInt32(&w.paused) == 1
+}
+
+// GenerateContext 生成一个雪花ID，暂停期间返回ErrPaused
+// @param ctx 已结束时返回包装了ErrContextCancelled的错误
+// @return snowflake.ID
+// @return error
+func (w *Wrapper) GenerateContext(ctx context.Context) (snowflake.ID, error) {
+	if err := nodeidgorm.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if w.Paused() {
+		return 0, ErrPaused
+	}
+	return w.Generate(), nil
+}
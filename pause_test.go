@@ -0,0 +1,89 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 暂停与恢复测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_Pause 测试暂停期间生成返回ErrPaused，恢复后正常生成
+func TestWrapper_Pause(t *testing.T) {
+	ctx := context.Background()
+	sf, err := NewSnowflake(ctx, setupTestDB(t), "test_pause", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	before, err := sf.GenerateContext(ctx)
+	require.NoError(t, err)
+
+	sf.Pause()
+	assert.True(t, sf.Paused())
+	id, err := sf.GenerateContext(ctx)
+	assert.True(t, errors.Is(err, ErrPaused))
+	assert.Equal(t, snowflake.ID(0), id)
+
+	sf.Resume()
+	assert.False(t, sf.Paused())
+	after, err := sf.GenerateContext(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, after.Int64(), before.Int64())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = sf.GenerateContext(cancelled)
+	assert.True(t, errors.Is(err, nodeidgorm.ErrContextCancelled))
+}
+
+// TestWrapper_Pause_GormCreateCallback 测试暂停期间GORM回调为语句添加ErrPaused
+func TestWrapper_Pause_GormCreateCallback(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, db.Migrator().DropTable(&testOrder{}))
+	require.NoError(t, db.AutoMigrate(&testOrder{}))
+
+	sf, err := NewSnowflake(context.Background(), db, "test_pause_hook", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+	require.NoError(t, db.Callback().Create().Before("gorm:create").
+		Register("snowflake:id", sf.GormCreateCallback("ID")))
+	defer func() { _ = db.Callback().Create().Remove("snowflake:id") }()
+
+	sf.Pause()
+	assert.True(t, errors.Is(db.Create(&testOrder{Name: "paused"}).Error, ErrPaused))
+	sf.Resume()
+	order := &testOrder{Name: "resumed"}
+	require.NoError(t, db.Create(order).Error)
+	assert.NotZero(t, order.ID)
+}
+
+// TestWrapper_PauseSynchronizer 测试配置WithPauseSynchronizer后暂停期间不写入时间
+func TestWrapper_PauseSynchronizer(t *testing.T) {
+	db := setupTestDB(t)
+	sf, err := NewSnowflake(context.Background(), db, "test_pause_sync", 8080, time.Second, 5*time.Second,
+		logger, WithPauseSynchronizer(true), WithSyncInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	sf.Generate()
+	time.Sleep(60 * time.Millisecond)
+	sf.Pause()
+	time.Sleep(60 * time.Millisecond)
+	assert.GreaterOrEqual(t, sf.LastSyncAge(), 50*time.Millisecond)
+
+	sf.Resume()
+	sf.Generate()
+	time.Sleep(60 * time.Millisecond)
+	assert.Less(t, sf.LastSyncAge(), 50*time.Millisecond)
+}
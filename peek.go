@@ -0,0 +1,77 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 预览下一个ID
+package snowflake
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// ParsedID 雪花ID的各组成部分
+type ParsedID struct {
+	// ID 雪花ID
+	ID snowflake.ID
+	// Time 时间戳
+	Time time.Time
+	// Node 节点ID
+	Node int64
+	// Type 类型前缀，未配置类型前缀时为0
+	Type int64
+	// Datacenter 数据中心ID，未划分数据中心时为0
+	Datacenter int64
+	// Worker 工作节点ID，未配置类型前缀且未划分数据中心时与Node相同
+	Worker int64
+	// Step 序列号
+	Step int64
+}
+
+// ParseID 按snowflake包当前的全局位布局拆分雪花ID
+// @param id
+// @return ParsedID
+func ParseID(id snowflake.ID) ParsedID {
+	return ParsedID{ID: id, Time: time.UnixMilli(id.Time()), Node: id.Node(), Worker: id.Node(), Step: id.Step()}
+}
+
+// recordLast 记录生成过的最大ID，供PeekNext推算下一个ID
+func (w *Wrapper) recordLast(id snowflake.ID) {
+	for {
+		last := atomic.LoadInt64(&w.lastID)
+		if int64(id) <= last || atomic.CompareAndSwapInt64(&w.lastID, last, int64(id)) {
+			return
+		}
+	}
+}
+
+// PeekNext 根据当前时钟与最近生成的ID推算下一次Generate将生成的ID，不消耗序列号，不修改任何状态
+// 仅用于排查顺序问题：并发生成时其他goroutine可能先消耗该ID，结果只是尽力而为的预估
+// @return ParsedID
+// @return error 暂停期间返回ErrPaused，与GenerateContext一致
+func (w *Wrapper) PeekNext() (ParsedID, error) {
+	if w.Paused() {
+		return ParsedID{}, ErrPaused
+	}
+
+	nodeId := w.NodeId()
+	stepMask := int64(-1) ^ (int64(-1) << snowflake.StepBits)
+	now := time.Now().UnixMilli() - snowflake.Epoch
+	step := int64(0)
+	// 与Generate一致：同一毫秒内序列号递增，序列号用尽时等待下一毫秒
+	if last := snowflake.ID(atomic.LoadInt64(&w.lastID)); last != 0 && last.Node() == nodeId {
+		if lastTime := last.Time() - snowflake.Epoch; lastTime >= now {
+			now = lastTime
+			if step = (last.Step() + 1) & stepMask; step == 0 {
+				now++
+			}
+		}
+	}
+
+	id := snowflake.ID(now<<(snowflake.NodeBits+snowflake.StepBits) | nodeId<<snowflake.StepBits | step)
+	return w.Parse(id), nil
+}
@@ -0,0 +1,47 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 预览下一个ID测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_PeekNext 测试预览的ID与随后生成的ID节点一致、时间戳接近，且预览不消耗序列号
+func TestWrapper_PeekNext(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_peek", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	for i := 0; i < 100; i++ {
+		peeked, err := sf.PeekNext()
+		require.NoError(t, err)
+		again, err := sf.PeekNext()
+		require.NoError(t, err)
+		generated := ParseID(sf.Generate())
+
+		assert.Equal(t, sf.NodeId(), peeked.Node)
+		assert.Equal(t, generated.Node, peeked.Node)
+		assert.LessOrEqual(t, generated.Time.Sub(peeked.Time), 5*time.Millisecond)
+		assert.False(t, generated.Time.Before(peeked.Time))
+		if generated.Time.Equal(peeked.Time) {
+			assert.Equal(t, peeked, again)
+			assert.Equal(t, peeked.Step, generated.Step)
+			assert.Equal(t, peeked.ID, generated.ID)
+		}
+	}
+
+	sf.Pause()
+	_, err = sf.PeekNext()
+	assert.True(t, errors.Is(err, ErrPaused))
+}
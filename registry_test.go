@@ -0,0 +1,87 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 多纪元生成器注册表测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRegistry_MultipleEpochs 测试不同纪元的生成器共存，各自按自己的位布局解码
+func TestRegistry_MultipleEpochs(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_registry", 8080, time.Second, 5*time.Second,
+		logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	global := Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits}
+	registry := NewRegistry(sf)
+	usersLayout := Layout{Epoch: time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), NodeBits: 10, StepBits: 12}
+	ordersLayout := Layout{Epoch: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(), NodeBits: 12, StepBits: 10}
+	users, err := registry.Register("users", usersLayout)
+	require.NoError(t, err)
+	orders, err := registry.Register("orders", ordersLayout)
+	require.NoError(t, err)
+	assert.Equal(t, usersLayout, users.Layout())
+	assert.Equal(t, "orders", orders.Name())
+
+	for _, generator := range []*LayoutGenerator{users, orders} {
+		var last snowflake.ID
+		for i := 0; i < 5000; i++ {
+			id := generator.Generate()
+			require.Greater(t, id, last)
+			last = id
+		}
+		parsed := generator.Parse(last)
+		assert.Equal(t, sf.NodeId(), parsed.Node)
+		assert.WithinDuration(t, time.Now(), parsed.Time, time.Second)
+	}
+
+	// 按另一个生成器的位布局解码得到错误的时间
+	userID, err := registry.Generate("users")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), users.Parse(userID).Time, time.Second)
+	assert.False(t, orders.Parse(userID).Time.Before(time.Now().Add(time.Hour)))
+
+	// 不修改全局位布局，Wrapper照常生成
+	assert.Equal(t, global, Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits})
+	assert.WithinDuration(t, time.Now(), ParseID(sf.Generate()).Time, time.Second)
+}
+
+// TestRegistry_Errors 测试重复注册、未注册与无效的位布局
+func TestRegistry_Errors(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_registry_errors", 8080, time.Second,
+		5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	registry := NewRegistry(sf)
+	generator, err := registry.Register("default", Layout{})
+	require.NoError(t, err)
+	assert.Equal(t, Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits},
+		generator.Layout())
+
+	_, err = registry.Register("default", Layout{})
+	assert.True(t, errors.Is(err, ErrGeneratorExists))
+	_, err = registry.Generate("missing")
+	assert.True(t, errors.Is(err, ErrGeneratorNotFound))
+	_, err = registry.Get("missing")
+	assert.True(t, errors.Is(err, ErrGeneratorNotFound))
+
+	// 节点ID位数小于分配的节点ID位数时无法容纳节点ID
+	_, err = registry.Register("narrow", Layout{Epoch: 1, NodeBits: snowflake.NodeBits - 1, StepBits: 12})
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	_, err = registry.Register("wide", Layout{Epoch: 1, NodeBits: 12, StepBits: 12})
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+}
@@ -9,36 +9,377 @@ package snowflake
 
 import (
 	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
 	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
 	"github.com/bwmarrin/snowflake"
 	"gorm.io/gorm"
 )
 
-type Config struct {
-	DB                       *gorm.DB
-	Port                     int
-	AcceptableClockDrift     time.Duration
-	NodeIdContentionInterval time.Duration
+var _ io.Closer = new(Wrapper)
+
+// Wrapper 雪花算法包装器，持有雪花节点及其节点ID分配器、时间同步器
+type Wrapper struct {
+	// node 当前生效的雪花节点 *snowflake.Node，节点ID变化时整体替换
+	node atomic.Value
+	// nodeId 当前雪花节点的节点ID，由nodeMu保护
+	nodeId int64
+	// nodeMu 保护节点ID与雪花节点的切换，分配器的节点ID变化回调在持有mu时也可能触发，因此与mu分开
+	nodeMu sync.Mutex
+	// mu 串行执行Refresh与SwapAllocator
+	mu sync.Mutex
+	// allocatorGen 分配器的代数，SwapAllocator后旧分配器的节点ID变化不再切换雪花节点
+	allocatorGen int64
+
+	// allocator 节点ID分配器，默认为*nodeidgorm.NodeIdAllocator，可通过WithAllocator注入，由mu保护
+	allocator snowflake.NodeIdAllocator
+	// synchronizer 时间同步器，默认为*nodeidgorm.TimeSynchronizer，可通过WithSynchronizer注入
+	synchronizer snowflake.TimeSynchronizer
+	// encoding GenerateString使用的编码
+	encoding Encoding
+	// paused 是否暂停生成，1表示暂停
+	paused int32
+	// pauseSynchronizer Pause时是否同时暂停时间同步器
+	pauseSynchronizer bool
+	// clockLag 时间戳滞后回调 *clockLagHook
+	clockLag atomic.Value
+	// clockAhead 时间戳超前回调 *clockAheadHook
+	clockAhead atomic.Value
+	// lastID 生成过的最大ID，供PeekNext推算下一个ID
+	lastID int64
+	// typeBits 节点ID中类型前缀的位数
+	typeBits uint8
+	// typeValue 类型前缀
+	typeValue int64
+	// datacenterBits 节点ID中数据中心ID的位数
+	datacenterBits uint8
+	// datacenterId 数据中心ID
+	datacenterId int64
+	// detectDuplicate 是否检测重复ID
+	detectDuplicate bool
+	// onDuplicate 重复ID回调 func(id snowflake.ID)
+	onDuplicate atomic.Value
+	// logger 分配器与时间同步器共用的日志
+	logger nodeidgorm.Logger
+	// asyncLogger WithAsyncLogging包装的异步日志，nil表示同步记录日志
+	asyncLogger *nodeidgorm.AsyncLogger
+	// ordering WithClusterOrdering的集群时间下限协调，nil表示不协调
+	ordering *clusterOrdering
+	// summary 创建时确定的配置摘要
+	summary StartupSummary
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewSnowflake 创建一个雪花算法
 // @param config
-// @return *snowflake.Node
+// @return *Wrapper
 // @return error
 func NewSnowflake(ctx context.Context, db *gorm.DB, name string, port int, acceptableClockDrift,
-	nodeIdContentionInterval time.Duration, logger nodeidgorm.Logger) (*snowflake.Node, error) {
+	nodeIdContentionInterval time.Duration, logger nodeidgorm.Logger, opts ...OptionFn) (*Wrapper, error) {
+	if err := nodeidgorm.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	if err := nodeidgorm.ValidatePort(port); err != nil {
+		return nil, err
+	}
+	op := newOption(opts...)
+	if op.minimumID > 0 {
+		if err := checkMinimumID(op.minimumID); err != nil {
+			return nil, err
+		}
+	}
+	if op.coordinationDB != nil {
+		db = op.coordinationDB
+	}
+	// 配置类型前缀或划分数据中心时，分配器只在对应的节点ID范围内分配工作节点ID
+	datacenterId := int64(0)
+	if op.typeBits > 0 || op.datacenterBits > 0 {
+		var nodeRange nodeid.NodeIdRange
+		var err error
+		if op.typeBits > 0 {
+			if nodeRange, err = resolveTypePrefix(op.typeBits, op.typeValue); err != nil {
+				return nil, err
+			}
+		}
+		if op.datacenterBits > 0 {
+			if datacenterId, nodeRange, err = resolveDatacenter(op.typeBits, op.typeValue, op.datacenterBits,
+				op.datacenterId); err != nil {
+				return nil, err
+			}
+		}
+		if op.allocator != nil {
+			op.allocator = nodeid.NewRangeNodeIdAllocator(op.allocator, nodeRange)
+		}
+		op.nodeIdOptions = append(op.nodeIdOptions, nodeidgorm.WithNodeIdRange(nodeRange))
+	}
+	// 同时注入分配器与时间同步器时无需数据库
+	if db == nil && (op.allocator == nil || op.synchronizer == nil || op.autoMigrate) {
+		return nil, ErrNilDB
+	}
+	if op.autoMigrate {
+		if err := nodeidgorm.AutoMigrate(db); err != nil {
+			return nil, err
+		}
+	}
+	// 日志异步记录时，分配器与时间同步器共享同一个缓冲区
+	var asyncLogger *nodeidgorm.AsyncLogger
+	if op.asyncLogBuffer > 0 {
+		asyncLogger = nodeidgorm.NewAsyncLogger(logger, op.asyncLogBuffer)
+		logger = asyncLogger
+	}
 	// 1. 节点id分配器
-	allocator := nodeidgorm.NewNodeIdAllocator(ctx, db, name, port, acceptableClockDrift, nodeIdContentionInterval, logger)
+	allocator := op.allocator
+	if allocator == nil {
+		allocator = nodeidgorm.NewNodeIdAllocator(ctx, db, name, port, acceptableClockDrift, nodeIdContentionInterval,
+			logger, op.nodeIdOptions...)
+	}
 	// 2. 时间同步器
-	synchronizer := nodeidgorm.NewTimeSynchronizer(ctx, db, name, port, acceptableClockDrift, logger)
+	synchronizer := op.synchronizer
+	if synchronizer == nil {
+		synchronizer = nodeidgorm.NewTimeSynchronizer(ctx, db, name, port, op.syncInterval, logger, op.nodeIdOptions...)
+	}
 	// 2.1 启动时间同步器
-	synchronizer.Run()
+	if runner, ok := synchronizer.(interface{ Run() }); ok {
+		runner.Run()
+	}
 	// 3. 雪花算法
-	option, err := snowflake.NewWithOption(snowflake.WithNodeIdAllocator(allocator), snowflake.WithTimeSynchronizer(synchronizer))
+	recorder := &nodeIdRecorder{NodeIdAllocator: allocator}
+	node, err := snowflake.NewWithOption(snowflake.WithNodeIdAllocator(recorder),
+		snowflake.WithTimeSynchronizer(synchronizer))
 	if err != nil {
+		if asyncLogger != nil {
+			_ = asyncLogger.Close()
+		}
 		return nil, err
 	}
-	return option, nil
+	w := &Wrapper{
+		nodeId:            recorder.NodeId(),
+		allocator:         allocator,
+		synchronizer:      synchronizer,
+		encoding:          op.encoding,
+		pauseSynchronizer: op.pauseSynchronizer,
+		typeBits:          op.typeBits,
+		typeValue:         op.typeValue,
+		datacenterBits:    op.datacenterBits,
+		datacenterId:      datacenterId,
+		detectDuplicate:   op.duplicateWindow > 0,
+		logger:            logger,
+		asyncLogger:       asyncLogger,
+		summary:           newStartupSummary(recorder.NodeId(), allocator, op.syncInterval),
+	}
+	if w.detectDuplicate {
+		recentIDs.grow(op.duplicateWindow)
+	}
+	w.node.Store(node)
+	w.followNodeIdChange(allocator)
+	if op.orderingInterval > 0 {
+		w.startClusterOrdering(ctx, op.orderingInterval, op.orderingMaxLead, logger)
+	}
+	return w, nil
+}
+
+// Generate 生成一个雪花ID，开启WithClusterOrdering时时间戳不低于集群时间下限
+// @return snowflake.ID
+func (w *Wrapper) Generate() snowflake.ID {
+	var id snowflake.ID
+	if w.ordering != nil {
+		id = w.ordering.generate(w.NodeId(), w.synchronizer)
+	} else {
+		id = w.node.Load().(*snowflake.Node).Generate()
+	}
+	w.recordLast(id)
+	w.checkDuplicate(id)
+	w.checkClockLag(id)
+	w.checkClockAhead(id)
+	return id
+}
+
+// GenerateInt64 生成一个雪花ID并返回int64，与Generate().Int64()相同
+// 调用方的接口无需依赖bwmarrin/snowflake的ID类型
+// @return int64
+func (w *Wrapper) GenerateInt64() int64 {
+	return int64(w.Generate())
+}
+
+// Refresh 重新执行节点ID的分配与抢占逻辑，节点ID发生变化时切换到新的雪花节点
+// 适用于虚拟机挂起恢复后，节点ID可能已在挂起期间被回收或被其他实例持有的场景
+// @param ctx
+// @return error
+func (w *Wrapper) Refresh(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nodeId, err := refreshNodeId(ctx, w.allocator)
+	if err != nil {
+		return err
+	}
+	return w.switchNode(nodeId)
+}
+
+// switchNode 切换到指定节点ID的雪花节点，节点ID未变化时沿用当前雪花节点，序列号与时间戳继续递增；
+// 节点ID变化时等待到最近生成的ID所在毫秒之后再切换，新节点从序列号0开始也不会与之前生成的ID重复
+// @param nodeId
+// @return error
+func (w *Wrapper) switchNode(nodeId int64) error {
+	w.nodeMu.Lock()
+	defer w.nodeMu.Unlock()
+
+	if nodeId == w.nodeId {
+		return nil
+	}
+	node, err := snowflake.NewWithOption(snowflake.WithNodeIdAllocator(fixedNodeIdAllocator(nodeId)),
+		snowflake.WithTimeSynchronizer(w.synchronizer))
+	if err != nil {
+		return err
+	}
+	waitAfterMilli(snowflake.ID(atomic.LoadInt64(&w.lastID)).Time())
+	w.node.Store(node)
+	w.nodeId = nodeId
+	return nil
+}
+
+// followNodeIdChange 在分配器上注册内部的节点ID变化回调，租约被回收后重新分配、时钟回拨漂移等分配器自行改变节点ID时，
+// 同步切换雪花节点，避免继续以已被其他实例持有的节点ID生成ID；分配器未实现OnNodeIdChange时不注册
+// @param allocator
+func (w *Wrapper) followNodeIdChange(allocator snowflake.NodeIdAllocator) {
+	notifier, ok := allocator.(interface{ OnNodeIdChange(func(old, new int64)) })
+	if !ok {
+		return
+	}
+	gen := atomic.LoadInt64(&w.allocatorGen)
+	notifier.OnNodeIdChange(func(old, new int64) {
+		if atomic.LoadInt64(&w.allocatorGen) != gen {
+			return
+		}
+		if err := w.switchNode(new); err != nil {
+			w.logger.Errorf("switch snowflake node after node id change failed. old: %d, new: %d, error: %v",
+				old, new, err)
+		}
+	})
+}
+
+// SwapAllocator 替换节点ID分配器，以新的分配器重新分配节点ID并原子地切换到新的雪花节点，无需重新创建雪花算法
+// 用于测试不同分配策略间的故障切换；分配失败时保留原分配器与节点ID。
+// 原分配器不会被关闭，由调用方决定是否Close以释放其节点ID；在原分配器上注册的OnNodeIdChange回调不会迁移到新的分配器。
+// 新节点ID与原节点ID相同时沿用当前雪花节点，序列号与时间戳继续递增；
+// 节点ID不同时等待到最近生成的ID所在毫秒之后再切换，新节点从序列号0开始也不会与之前生成的ID重复
+// 之后新分配器自行改变节点ID时同样切换雪花节点，原分配器的节点ID变化不再影响Wrapper
+// @param allocator
+// @return int64 新的节点ID
+// @return error
+func (w *Wrapper) SwapAllocator(allocator snowflake.NodeIdAllocator) (int64, error) {
+	if allocator == nil {
+		return 0, errors.New("allocator must not be nil")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	nodeId, err := allocator.Alloc()
+	if err != nil {
+		return 0, err
+	}
+	if err = w.switchNode(nodeId); err != nil {
+		return 0, err
+	}
+	w.allocator = allocator
+	atomic.AddInt64(&w.allocatorGen, 1)
+	w.followNodeIdChange(allocator)
+	return nodeId, nil
+}
+
+// waitAfterMilli 等待直到本机时钟进入指定毫秒时间戳之后
+// @param milli 含Epoch的毫秒时间戳
+func waitAfterMilli(milli int64) {
+	for time.Now().UnixMilli() <= milli {
+		time.Sleep(time.Duration(milli-time.Now().UnixMilli()+1) * time.Millisecond)
+	}
+}
+
+// currentAllocator 当前生效的节点ID分配器
+func (w *Wrapper) currentAllocator() snowflake.NodeIdAllocator {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.allocator
+}
+
+// fixedNodeIdAllocator 返回固定节点ID的分配器，用于以已分配的节点ID创建雪花节点
+type fixedNodeIdAllocator int64
+
+// Alloc 返回固定的节点ID
+func (f fixedNodeIdAllocator) Alloc() (int64, error) {
+	return int64(f), nil
+}
+
+// Migration 固定节点ID不支持漂移
+func (f fixedNodeIdAllocator) Migration(_ int64) (int64, error) {
+	return int64(f), nil
+}
+
+// OnNodeIdChange 注册节点ID变化回调，内嵌了节点ID的下游缓存可据此失效
+// 构造期间的首次分配发生在注册之前，不会触发回调；注入的分配器未实现OnNodeIdChange时不会触发回调
+// @param callback
+func (w *Wrapper) OnNodeIdChange(callback func(old, new int64)) {
+	if notifier, ok := w.currentAllocator().(interface{ OnNodeIdChange(func(old, new int64)) }); ok {
+		notifier.OnNodeIdChange(callback)
+	}
+}
+
+// LastSyncAge 距时间同步器最近一次成功写入数据库的时长，注入的时间同步器未实现LastSyncAge时返回0
+// @return time.Duration
+func (w *Wrapper) LastSyncAge() time.Duration {
+	if reporter, ok := w.synchronizer.(interface{ LastSyncAge() time.Duration }); ok {
+		return reporter.LastSyncAge()
+	}
+	return 0
+}
+
+// FlushNow 立即将时间同步器中最近生成的时间写入数据库，不等待下一次同步，注入的时间同步器未实现FlushNow时返回nil
+// 用于生成ID后需要立即读取到一致记录的场景，如测试
+// @param ctx
+// @return error
+func (w *Wrapper) FlushNow(ctx context.Context) error {
+	if flusher, ok := w.synchronizer.(interface{ FlushNow(ctx context.Context) error }); ok {
+		return flusher.FlushNow(ctx)
+	}
+	return nil
+}
+
+// DroppedLogs 开启WithAsyncLogging时缓冲区已满而丢弃的日志数，未开启时返回0
+// @return int64
+func (w *Wrapper) DroppedLogs() int64 {
+	if w.asyncLogger == nil {
+		return 0
+	}
+	return w.asyncLogger.Dropped()
+}
+
+// Close 停止时间同步器并释放节点ID，便于接入fx、wire等生命周期管理
+// 默认分配器释放时删除key的记录，正常关闭后重启时没有保存的时间可用于保证重启前后ID递增
+// Close后不应再生成ID，重复调用返回首次调用的结果
+// @return error
+func (w *Wrapper) Close() error {
+	w.closeOnce.Do(func() {
+		if w.ordering != nil {
+			w.ordering.stopOrdering()
+		}
+		if stopper, ok := w.synchronizer.(interface{ Stop() }); ok {
+			stopper.Stop()
+		}
+		if closer, ok := w.currentAllocator().(io.Closer); ok {
+			w.closeErr = closer.Close()
+		}
+		// 最后关闭异步日志，输出停止与释放过程中记录的日志
+		if w.asyncLogger != nil {
+			_ = w.asyncLogger.Close()
+		}
+	})
+	return w.closeErr
 }
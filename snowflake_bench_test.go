@@ -12,6 +12,7 @@ import (
 	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -238,3 +239,69 @@ func BenchmarkNewSnowflake_Serialization(b *testing.B) {
 		_ = id.String()
 	}
 }
+
+// reportTailLatency 逐次记录耗时并上报P50/P99/P999延迟
+func reportTailLatency(b *testing.B, generate func()) {
+	latencies := make([]time.Duration, b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		generate()
+		latencies[i] = time.Since(start)
+	}
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) float64 {
+		return float64(latencies[int(float64(len(latencies)-1)*p)].Nanoseconds())
+	}
+	b.ReportMetric(percentile(0.5), "p50-ns")
+	b.ReportMetric(percentile(0.99), "p99-ns")
+	b.ReportMetric(percentile(0.999), "p999-ns")
+}
+
+// BenchmarkNewSnowflake_GenerateID_TailLatency 测试直接生成雪花ID的尾延迟
+func BenchmarkNewSnowflake_GenerateID_TailLatency(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sf, err := NewSnowflake(ctx, setupTestDB(b), "test_name", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(b, err)
+
+	reportTailLatency(b, func() { _ = sf.Generate() })
+}
+
+// BenchmarkBufferedSnowflake_GenerateID_TailLatency 测试预生成缓冲雪花ID的尾延迟
+func BenchmarkBufferedSnowflake_GenerateID_TailLatency(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sf, err := NewBufferedSnowflake(ctx, setupTestDB(b), "test_name", 8080, time.Second, 5*time.Second, logger, 4096)
+	require.NoError(b, err)
+	defer sf.Close()
+
+	reportTailLatency(b, func() { _ = sf.Generate() })
+}
+
+// BenchmarkWrapper_GenerateInt64 对比GenerateInt64与Generate().Int64()的性能
+func BenchmarkWrapper_GenerateInt64(b *testing.B) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sf, err := NewSnowflake(ctx, setupTestDB(b), "test_name", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(b, err)
+
+	b.Run("GenerateInt64", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sf.GenerateInt64()
+		}
+	})
+	b.Run("Generate().Int64", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = sf.Generate().Int64()
+		}
+	})
+}
@@ -0,0 +1,455 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model/dao"
+	"github.com/bwmarrin/snowflake"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestWrapper_Close 测试关闭后释放节点ID记录并停止时间同步器
+func TestWrapper_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := setupTestDB(t)
+
+	sf, err := NewSnowflake(ctx, db, "test_close", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	var closer io.Closer = sf
+	sf.Generate()
+
+	tab := dao.Use(db).SnowflakeKv
+	count := func() int64 {
+		n, err := tab.WithContext(ctx).Where(tab.Key.Eq(nodeidgorm.GetNodeIdKey("test_close", 8080))).Count()
+		require.NoError(t, err)
+		return n
+	}
+	require.Equal(t, int64(1), count())
+
+	// Close等待时间同步器的goroutine退出后返回
+	require.NoError(t, closer.Close())
+	assert.Zero(t, count())
+
+	// 重复关闭
+	assert.NoError(t, closer.Close())
+}
+
+// TestNewSnowflake_ContextCancelled 测试传入已结束的context时返回明确的错误
+func TestNewSnowflake_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sf, err := NewSnowflake(ctx, setupTestDB(t), "test_cancelled", 8080, time.Second, 5*time.Second, logger)
+	assert.Nil(t, sf)
+	assert.True(t, errors.Is(err, nodeidgorm.ErrContextCancelled))
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+
+	_, err = NewBufferedSnowflake(ctx, setupTestDB(t), "test_cancelled", 8080, time.Second, 5*time.Second, logger, 16)
+	assert.True(t, errors.Is(err, nodeidgorm.ErrContextCancelled))
+}
+
+// TestNewSnowflake_InvalidPort 测试非正数端口被拒绝，不会生成格式错误的节点ID Key
+func TestNewSnowflake_InvalidPort(t *testing.T) {
+	for _, port := range []int{-1, 0} {
+		sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_port", port, time.Second, 5*time.Second, logger)
+		assert.Nil(t, sf)
+		assert.True(t, errors.Is(err, nodeidgorm.ErrInvalidPort))
+	}
+}
+
+// memSynchronizer 内存时间同步器，记录最大的同步时间
+type memSynchronizer struct {
+	last    int64
+	stopped bool
+}
+
+// Async 同步时间
+func (m *memSynchronizer) Async(t int64) {
+	if t > m.last {
+		m.last = t
+	}
+}
+
+// Stop 停止同步
+func (m *memSynchronizer) Stop() {
+	m.stopped = true
+}
+
+// TestNewSnowflake_NilDB 测试未注入分配器与时间同步器时nil数据库返回ErrNilDB，同时注入时无需数据库
+func TestNewSnowflake_NilDB(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), nil, "test_nil_db", 8080, time.Second, 5*time.Second, logger)
+	assert.Nil(t, sf)
+	assert.True(t, errors.Is(err, ErrNilDB))
+
+	_, err = NewSnowflake(context.Background(), nil, "test_nil_db", 8080, time.Second, 5*time.Second, logger,
+		WithAllocator(nodeid.NewHashNodeIdAllocator("test_nil_db")))
+	assert.True(t, errors.Is(err, ErrNilDB))
+
+	synchronizer := &memSynchronizer{}
+	sf, err = NewSnowflake(context.Background(), nil, "test_nil_db", 8080, time.Second, 5*time.Second, logger,
+		WithAllocator(nodeid.NewHashNodeIdAllocator("test_nil_db")), WithSynchronizer(synchronizer))
+	require.NoError(t, err)
+
+	expected, err := nodeid.NewHashNodeIdAllocator("test_nil_db").Alloc()
+	require.NoError(t, err)
+	assert.Equal(t, expected, sf.NodeId())
+	id := sf.Generate()
+	assert.Equal(t, expected, id.Node())
+	assert.Equal(t, id.Time(), synchronizer.last)
+
+	require.NoError(t, sf.Refresh(context.Background()))
+	assert.Equal(t, expected, sf.NodeId())
+	assert.Equal(t, time.Duration(0), sf.LastSyncAge())
+	require.NoError(t, sf.Close())
+	assert.True(t, synchronizer.stopped)
+}
+
+// TestWrapper_SwapAllocator 测试替换分配器后节点ID切换为新分配器分配的节点ID，分配失败时保留原节点ID
+func TestWrapper_SwapAllocator(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), nil, "test_swap", 8080, time.Second, 5*time.Second, logger,
+		WithAllocator(nodeid.NewHashNodeIdAllocator("test_swap")), WithSynchronizer(&memSynchronizer{}))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	stub := fixedNodeIdAllocator(42)
+	if sf.NodeId() == 42 {
+		stub = 43
+	}
+	before := sf.Generate()
+	nodeId, err := sf.SwapAllocator(stub)
+	require.NoError(t, err)
+	assert.Equal(t, int64(stub), nodeId)
+	assert.Equal(t, int64(stub), sf.NodeId())
+	after := sf.Generate()
+	assert.Equal(t, int64(stub), after.Node())
+	assert.GreaterOrEqual(t, after.Time(), before.Time())
+
+	_, err = sf.SwapAllocator(failingAllocator{})
+	assert.Error(t, err)
+	assert.Equal(t, int64(stub), sf.NodeId())
+}
+
+// TestWrapper_SwapAllocator_SameNodeId 测试新分配器返回相同节点ID时切换前后生成的ID不重复
+func TestWrapper_SwapAllocator_SameNodeId(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), nil, "test_swap_same", 8080, time.Second, 5*time.Second, logger,
+		WithAllocator(nodeid.NewHashNodeIdAllocator("test_swap_same")), WithSynchronizer(&memSynchronizer{}))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	seen := make(map[snowflake.ID]struct{})
+	for round := 0; round < 200; round++ {
+		for i := 0; i < 50; i++ {
+			id := sf.Generate()
+			_, duplicate := seen[id]
+			require.False(t, duplicate, "round %d: duplicate id %d", round, id)
+			seen[id] = struct{}{}
+		}
+		nodeId, err := sf.SwapAllocator(fixedNodeIdAllocator(sf.NodeId()))
+		require.NoError(t, err)
+		assert.Equal(t, sf.NodeId(), nodeId)
+	}
+}
+
+// TestWrapper_SwapAllocator_Alternate 测试在两个节点ID之间来回切换时生成的ID不重复
+func TestWrapper_SwapAllocator_Alternate(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), nil, "test_swap_alternate", 8080, time.Second, 5*time.Second,
+		logger, WithAllocator(fixedNodeIdAllocator(1)), WithSynchronizer(&memSynchronizer{}))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	seen := make(map[snowflake.ID]struct{})
+	for round := 0; round < 20; round++ {
+		for i := 0; i < 50; i++ {
+			id := sf.Generate()
+			_, duplicate := seen[id]
+			require.False(t, duplicate, "round %d: duplicate id %d", round, id)
+			seen[id] = struct{}{}
+		}
+		_, err := sf.SwapAllocator(fixedNodeIdAllocator(1 + int64(round+1)%2))
+		require.NoError(t, err)
+	}
+}
+
+// TestWrapper_LeaseReclaimed 测试租约被其他实例回收后，Wrapper随分配器切换到新的节点ID，不再以被回收的节点ID生成ID
+func TestWrapper_LeaseReclaimed(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "lease.db")))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// 持有者的时钟落后一小时，写入的租约对其他实例而言已到期
+	holder, err := NewSnowflake(ctx, db, "test_lease_holder", 8080, time.Second, 5*time.Second, logger,
+		WithAutoMigrate(true), WithNodeIdOptions(nodeidgorm.WithNodeIdRange(nodeid.NodeIdRange{Min: 5, Max: 6}),
+			nodeidgorm.WithClock(nodeidgorm.NewOffsetClock(-time.Hour)),
+			nodeidgorm.WithLease(100*time.Millisecond, 20*time.Millisecond)))
+	require.NoError(t, err)
+	defer holder.Close()
+	reclaimed := holder.NodeId()
+	assert.Equal(t, reclaimed, holder.Generate().Node())
+
+	claimer, err := NewSnowflake(ctx, db, "test_lease_claimer", 8080, time.Second, 5*time.Second, logger,
+		WithNodeIdOptions(nodeidgorm.WithNodeIdRange(nodeid.NodeIdRange{Min: reclaimed, Max: reclaimed}),
+			nodeidgorm.WithLease(time.Hour, 0)))
+	require.NoError(t, err)
+	defer claimer.Close()
+	assert.Equal(t, reclaimed, claimer.NodeId())
+
+	// 持有者续约失败后重新分配到范围内的另一个节点ID，Wrapper随之切换
+	other := 11 - reclaimed
+	require.Eventually(t, func() bool { return holder.NodeId() == other }, time.Second, 5*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, other, holder.Generate().Node())
+	}
+	assert.Equal(t, reclaimed, claimer.Generate().Node())
+}
+
+// failingAllocator 总是分配失败的分配器
+type failingAllocator struct{}
+
+// Alloc 分配失败
+func (failingAllocator) Alloc() (int64, error) {
+	return 0, errors.New("alloc failed")
+}
+
+// Migration 漂移失败
+func (failingAllocator) Migration(_ int64) (int64, error) {
+	return 0, errors.New("migration failed")
+}
+
+// TestWrapper_Refresh 测试挂起期间节点ID被其他实例持有时，Refresh切换到新的节点ID
+func TestWrapper_Refresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := setupTestDB(t)
+
+	sf, err := NewSnowflake(ctx, db, "test_refresh", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+	before := sf.Generate()
+
+	// 未被回收时节点ID不变
+	require.NoError(t, sf.Refresh(ctx))
+	assert.Equal(t, before.Node(), sf.Generate().Node())
+
+	// 挂起期间记录被回收，节点ID被其他实例持有
+	var changed [][2]int64
+	sf.OnNodeIdChange(func(old, new int64) { changed = append(changed, [2]int64{old, new}) })
+	tab := dao.Use(db).SnowflakeKv
+	key := nodeidgorm.GetNodeIdKey("test_refresh", 8080)
+	_, err = tab.WithContext(ctx).Where(tab.Key.Eq(key)).Delete()
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, tab.WithContext(ctx).Omit(tab.IP, tab.DeployType).Create(&model.SnowflakeKv{
+		Key: "test_refresh_other", NodeID: before.Node(), Time: now.UnixMilli(), Created: &now, Updated: now,
+	}))
+	t.Cleanup(func() { _, _ = tab.WithContext(context.Background()).Where(tab.Key.Eq("test_refresh_other")).Delete() })
+
+	require.NoError(t, sf.Refresh(ctx))
+	after := sf.Generate()
+	assert.NotEqual(t, before.Node(), after.Node())
+	assert.Equal(t, [][2]int64{{before.Node(), after.Node()}}, changed)
+
+	record, err := tab.WithContext(ctx).Where(tab.Key.Eq(key)).First()
+	require.NoError(t, err)
+	assert.Equal(t, after.Node(), record.NodeID)
+}
+
+// TestNewSnowflake_CoordinationDB 测试节点ID注册表只写入协调数据库，不影响业务数据库
+func TestNewSnowflake_CoordinationDB(t *testing.T) {
+	appDB, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")))
+	require.NoError(t, err)
+	require.NoError(t, appDB.AutoMigrate(&testOrder{}))
+	coordinationDB, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "coordination.db")))
+	require.NoError(t, err)
+
+	sf, err := NewSnowflake(context.Background(), appDB, "test_coordination", 8080, time.Second, 5*time.Second, logger,
+		WithCoordinationDB(coordinationDB), WithAutoMigrate(true))
+	require.NoError(t, err)
+	defer sf.Close()
+	require.NoError(t, appDB.Callback().Create().Before("gorm:create").
+		Register("snowflake:id", sf.GormCreateCallback("ID")))
+	require.NoError(t, appDB.Create(&testOrder{Name: "order"}).Error)
+
+	var count int64
+	require.NoError(t, coordinationDB.Model(&model.SnowflakeKv{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	assert.False(t, appDB.Migrator().HasTable(&model.SnowflakeKv{}))
+	require.NoError(t, appDB.Model(&testOrder{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+// syncCounter 统计时间同步器写入次数的监控指标钩子
+type syncCounter struct {
+	syncs int64
+}
+
+// ObserveDBLatency 统计写入次数
+func (c *syncCounter) ObserveDBLatency(op string, _ time.Duration) {
+	if op == nodeidgorm.DBOpSync {
+		atomic.AddInt64(&c.syncs, 1)
+	}
+}
+
+// TestNewSnowflake_SyncInterval 测试时间同步器按配置的间隔写入，而不是按时钟回拨容忍时间
+func TestNewSnowflake_SyncInterval(t *testing.T) {
+	counter := &syncCounter{}
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_sync_interval", 8080, 5*time.Second,
+		5*time.Second, logger, WithSyncInterval(20*time.Millisecond),
+		WithNodeIdOptions(nodeidgorm.WithMetrics(counter)))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	sf.Generate()
+	time.Sleep(210 * time.Millisecond)
+	syncs := atomic.LoadInt64(&counter.syncs)
+	assert.GreaterOrEqual(t, syncs, int64(5))
+	assert.LessOrEqual(t, syncs, int64(11))
+
+	assert.Equal(t, DefaultSyncInterval, newOption().syncInterval)
+	assert.Equal(t, DefaultSyncInterval, newOption(WithSyncInterval(0)).syncInterval)
+}
+
+// countingLogger 统计日志条数的日志记录器
+type countingLogger struct {
+	nodeidgorm.DefaultLogger
+	logs int64
+}
+
+// Info 统计条数
+func (l *countingLogger) Info(args ...interface{}) {
+	atomic.AddInt64(&l.logs, 1)
+}
+
+// TestNewSnowflake_AsyncLogging 测试开启异步日志后照常生成ID，Close输出缓冲区中剩余的日志
+func TestNewSnowflake_AsyncLogging(t *testing.T) {
+	counting := &countingLogger{}
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_async_logging", 8080, time.Second,
+		5*time.Second, counting, WithAsyncLogging(16))
+	require.NoError(t, err)
+	assert.NotZero(t, sf.Generate())
+	require.NoError(t, sf.Close())
+
+	// 时间同步器停止时记录的日志在Close返回前已输出
+	assert.Equal(t, int64(1), atomic.LoadInt64(&counting.logs))
+	assert.Zero(t, sf.DroppedLogs())
+}
+
+// TestWrapper_GenerateInt64 测试GenerateInt64与Generate().Int64()生成同一序列上的ID
+func TestWrapper_GenerateInt64(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_generate_int64", 8080, time.Second,
+		5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	// 同一毫秒内两次生成的ID只有序列号相差1
+	for i := 0; i < 100; i++ {
+		wrapped := sf.Generate().Int64()
+		raw := sf.GenerateInt64()
+		require.Greater(t, raw, wrapped)
+		if snowflake.ID(raw).Time() == snowflake.ID(wrapped).Time() {
+			assert.Equal(t, wrapped+1, raw)
+			assert.Equal(t, sf.NodeId(), snowflake.ID(raw).Node())
+			return
+		}
+	}
+	t.Fatal("no pair of ids was generated within the same millisecond")
+}
+
+// TestNewSnowflake_WaitsForStoredWatermark 测试重启时保存的时间晚于当前时间且在容忍范围内，创建阻塞到时钟追上后再生成ID，
+// 节点生成的ID不会早于重启前的ID
+func TestNewSnowflake_WaitsForStoredWatermark(t *testing.T) {
+	db := setupTestDB(t)
+	name := "test_stored_watermark"
+	const port = 8080
+	key := nodeidgorm.GetNodeIdKey(name, port)
+	require.NoError(t, db.Where("key = ?", key).Delete(&model.SnowflakeKv{}).Error)
+
+	nodeId, err := nodeid.NewHashNodeIdAllocator(key).Alloc()
+	require.NoError(t, err)
+	require.NoError(t, db.Where("node_id = ?", nodeId).Delete(&model.SnowflakeKv{}).Error)
+
+	// 重启前的ID已推进到未来300ms
+	now := time.Now()
+	watermark := now.Add(300 * time.Millisecond)
+	require.NoError(t, db.Create(&model.SnowflakeKv{Key: key, NodeID: nodeId, Time: watermark.UnixMilli(),
+		Created: &now, Updated: now}).Error)
+
+	sf, err := NewSnowflake(context.Background(), db, name, port, time.Second, 5*time.Second, logger,
+		WithNodeIdOptions(nodeidgorm.WithRollbackPollInterval(5*time.Millisecond)))
+	require.NoError(t, err)
+	defer sf.Close()
+	// 按持久化的毫秒时间戳比较
+	assert.GreaterOrEqual(t, time.Now().UnixMilli(), watermark.UnixMilli())
+
+	// 未漂移，沿用原节点ID，生成的ID不早于保存的时间
+	assert.Equal(t, nodeId, sf.NodeId())
+	assert.GreaterOrEqual(t, snowflake.ID(sf.GenerateInt64()).Time(), watermark.UnixMilli())
+}
+
+// TestNewSnowflake_WatermarkAfterRestart 测试进程崩溃时记录保留最后写入的时间，重启后生成的ID不早于该时间；
+// Close删除记录，正常重启后没有可读取的水位
+func TestNewSnowflake_WatermarkAfterRestart(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "restart.db")))
+	require.NoError(t, err)
+	const name, port = "test_restart_watermark", 8080
+	tab := dao.Use(db).SnowflakeKv
+	stored := func() []*model.SnowflakeKv {
+		rows, err := tab.WithContext(context.Background()).Where(tab.Key.Eq(nodeidgorm.GetNodeIdKey(name, port))).Find()
+		require.NoError(t, err)
+		return rows
+	}
+
+	// 崩溃：context结束但未调用Close，记录中保留最后写入的时间
+	ctx, crash := context.WithCancel(context.Background())
+	crashed, err := NewSnowflake(ctx, db, name, port, time.Second, 5*time.Second, logger, WithAutoMigrate(true))
+	require.NoError(t, err)
+	last := crashed.Generate()
+	require.NoError(t, crashed.FlushNow(context.Background()))
+	crash()
+	rows := stored()
+	require.Len(t, rows, 1)
+	assert.GreaterOrEqual(t, rows[0].Time, last.Time())
+
+	restarted, err := NewSnowflake(context.Background(), db, name, port, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	assert.Equal(t, crashed.NodeId(), restarted.NodeId())
+	assert.GreaterOrEqual(t, restarted.Generate().Time(), rows[0].Time)
+
+	// 正常关闭：Close删除记录，重启时没有可读取的水位
+	require.NoError(t, restarted.Close())
+	assert.Empty(t, stored())
+}
+
+// TestWrapper_FlushNow 测试生成ID后立即写入，记录中的时间不早于生成的ID
+func TestWrapper_FlushNow(t *testing.T) {
+	db := setupTestDB(t)
+	sf, err := NewSnowflake(context.Background(), db, "test_flush_now", 8080, time.Second, 5*time.Second, logger,
+		WithSyncInterval(time.Hour))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	id := sf.Generate()
+	require.NoError(t, sf.FlushNow(context.Background()))
+	var saved model.SnowflakeKv
+	require.NoError(t, db.Where("key = ?", nodeidgorm.GetNodeIdKey("test_flush_now", 8080)).Take(&saved).Error)
+	assert.GreaterOrEqual(t, saved.Time, id.Time())
+}
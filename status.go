@@ -0,0 +1,45 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 运行状态
+package snowflake
+
+import "time"
+
+// Status 雪花算法的运行状态
+type Status struct {
+	// NodeId 当前生效的节点ID
+	NodeId int64 `json:"node_id"`
+	// Paused 是否已暂停生成
+	Paused bool `json:"paused"`
+	// Degraded 时间同步器是否因连续写入失败而降级，降级期间仍可生成ID，但重启后的时钟回拨保护退化为最近一次成功写入的时间
+	Degraded bool `json:"degraded"`
+	// LastSyncAge 距时间同步器最近一次成功写入数据库的时长
+	LastSyncAge time.Duration `json:"last_sync_age"`
+}
+
+// Status 当前的运行状态，可用于健康检查，注入的时间同步器未实现Degraded时Degraded总为false
+// @return Status
+func (w *Wrapper) Status() Status {
+	status := Status{
+		NodeId:      w.NodeId(),
+		Paused:      w.Paused(),
+		LastSyncAge: w.LastSyncAge(),
+	}
+	if reporter, ok := w.synchronizer.(interface{ Degraded() bool }); ok {
+		status.Degraded = reporter.Degraded()
+	}
+	return status
+}
+
+// OnDegradedChange 注册时间同步器降级状态变化回调，进入与退出降级状态时调用，不应阻塞
+// 注入的时间同步器未实现OnDegradedChange时不会触发回调
+// @param callback
+func (w *Wrapper) OnDegradedChange(callback func(degraded bool)) {
+	if notifier, ok := w.synchronizer.(interface{ OnDegradedChange(func(degraded bool)) }); ok {
+		notifier.OnDegradedChange(callback)
+	}
+}
@@ -0,0 +1,50 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 运行状态测试
+package snowflake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid/gorm/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_Status 测试运行状态反映节点ID、暂停与时间同步器的降级状态
+func TestWrapper_Status(t *testing.T) {
+	db := setupTestDB(t)
+	sf, err := NewSnowflake(context.Background(), db, "test_status", 8080, time.Second, 5*time.Second, logger,
+		WithSyncInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	status := sf.Status()
+	assert.Equal(t, sf.NodeId(), status.NodeId)
+	assert.False(t, status.Paused)
+	assert.False(t, status.Degraded)
+
+	sf.Pause()
+	assert.True(t, sf.Status().Paused)
+	sf.Resume()
+
+	// 删除表后时间同步器持续写入失败，进入降级状态
+	changes := make(chan bool, 2)
+	sf.OnDegradedChange(func(degraded bool) { changes <- degraded })
+	require.NoError(t, db.Migrator().DropTable(&model.SnowflakeKv{}))
+	sf.Generate()
+	select {
+	case degraded := <-changes:
+		assert.True(t, degraded)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "synchronizer did not degrade")
+	}
+	assert.True(t, sf.Status().Degraded)
+	assert.Greater(t, sf.Status().LastSyncAge, time.Duration(0))
+}
@@ -0,0 +1,33 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 雪花ID流
+package snowflake
+
+import (
+	"context"
+
+	"github.com/bwmarrin/snowflake"
+)
+
+// Stream 启动一个后台goroutine持续生成ID写入返回的channel，直到context结束
+// channel无缓冲，消费者读取前不会继续生成，context结束后channel被关闭
+// @param ctx
+// @return <-chan snowflake.ID
+func (w *Wrapper) Stream(ctx context.Context) <-chan snowflake.ID {
+	stream := make(chan snowflake.ID)
+	go func() {
+		defer close(stream)
+		for {
+			select {
+			case stream <- w.Generate():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return stream
+}
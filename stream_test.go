@@ -0,0 +1,71 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 雪花ID流测试
+package snowflake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_Stream 测试从ID流读取的ID唯一，context结束后流被关闭
+func TestWrapper_Stream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sf, err := NewSnowflake(ctx, setupTestDB(t), "test_stream", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+
+	streamCtx, stop := context.WithCancel(ctx)
+	stream := sf.Stream(streamCtx)
+
+	// 多个消费者并发读取
+	const consumers, perConsumer = 4, 1000
+	var (
+		mu   sync.Mutex
+		seen = make(map[snowflake.ID]struct{}, consumers*perConsumer)
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < consumers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perConsumer; j++ {
+				id := <-stream
+				mu.Lock()
+				seen[id] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, consumers*perConsumer)
+
+	// context结束后goroutine退出并关闭channel
+	stop()
+	select {
+	case <-drain(stream):
+	case <-time.After(time.Second):
+		t.Fatal("stream is not closed after context cancel")
+	}
+}
+
+// drain 读取剩余的ID直到channel关闭
+func drain(stream <-chan snowflake.ID) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range stream {
+		}
+	}()
+	return done
+}
@@ -0,0 +1,52 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 启动摘要
+package snowflake
+
+import (
+	"time"
+
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+)
+
+// StartupSummary 雪花算法创建时确定的配置，便于启动时输出一条结构化日志或通过信息接口暴露
+type StartupSummary struct {
+	// NodeId 创建时分配的节点ID，之后的变化以Status为准
+	NodeId int64 `json:"node_id"`
+	// NodeIdKey 节点ID Key，注入的分配器未实现NodeIdKey时为空
+	NodeIdKey string `json:"node_id_key,omitempty"`
+	// DeployType 部署类型，注入的分配器未实现DeployType时为空
+	DeployType string `json:"deploy_type,omitempty"`
+	// IP 解析出的IP，注入的分配器未实现IP时为空
+	IP string `json:"ip,omitempty"`
+	// Layout 创建时生效的纪元与位布局
+	Layout Layout `json:"layout"`
+	// SyncInterval 时间同步器写入数据库的间隔，注入时间同步器时为WithSyncInterval配置的值
+	SyncInterval time.Duration `json:"sync_interval"`
+}
+
+// newStartupSummary 汇总创建时确定的配置
+func newStartupSummary(nodeId int64, allocator snowflake.NodeIdAllocator, syncInterval time.Duration) StartupSummary {
+	summary := StartupSummary{NodeId: nodeId, Layout: Layout{}.resolve(), SyncInterval: syncInterval}
+	if keyed, ok := allocator.(interface{ NodeIdKey() string }); ok {
+		summary.NodeIdKey = keyed.NodeIdKey()
+	}
+	if typed, ok := allocator.(interface{ DeployType() nodeidgorm.DeployType }); ok {
+		summary.DeployType = string(typed.DeployType())
+	}
+	if addressed, ok := allocator.(interface{ IP() string }); ok {
+		summary.IP = addressed.IP()
+	}
+	return summary
+}
+
+// Summary 创建时确定的配置摘要，包括节点ID、节点ID Key、部署类型、解析出的IP、位布局与同步间隔
+// @return StartupSummary
+func (w *Wrapper) Summary() StartupSummary {
+	return w.summary
+}
@@ -0,0 +1,67 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 启动摘要测试
+package snowflake
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	nodeidgorm "github.com/GuoxinL/snowflake-gorm/nodeid/gorm"
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_Summary 测试摘要中的每个字段均为创建时确定的值
+func TestWrapper_Summary(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_summary", 8080, time.Second, 5*time.Second,
+		logger, WithSyncInterval(200*time.Millisecond))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	summary := sf.Summary()
+	assert.GreaterOrEqual(t, summary.NodeId, int64(0))
+	assert.NotEmpty(t, summary.NodeIdKey)
+	assert.NotEmpty(t, summary.DeployType)
+	assert.NotEmpty(t, summary.IP)
+	assert.Equal(t, sf.NodeId(), summary.NodeId)
+	assert.Equal(t, nodeidgorm.GetIP(), summary.IP)
+	assert.Equal(t, string(nodeidgorm.GetDeployType()), summary.DeployType)
+	assert.Equal(t, nodeidgorm.GetNodeIdKey("test_summary", 8080), summary.NodeIdKey)
+	name, ip, port, deployType, err := nodeidgorm.ParseNodeIdKey(summary.NodeIdKey)
+	require.NoError(t, err)
+	assert.Equal(t, "test_summary", name)
+	assert.Equal(t, summary.IP, ip)
+	assert.Equal(t, 8080, port)
+	assert.Equal(t, summary.DeployType, string(deployType))
+	assert.Equal(t, Layout{Epoch: snowflake.Epoch, NodeBits: snowflake.NodeBits, StepBits: snowflake.StepBits},
+		summary.Layout)
+	assert.Equal(t, 200*time.Millisecond, summary.SyncInterval)
+
+	encoded, err := json.Marshal(summary)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"node_id_key":"`+summary.NodeIdKey+`"`)
+}
+
+// TestWrapper_Summary_Injected 测试注入的分配器未提供节点ID Key等信息时对应字段为空
+func TestWrapper_Summary_Injected(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), nil, "test_summary", 8080, time.Second, 5*time.Second, logger,
+		WithAllocator(nodeid.NewHashNodeIdAllocator("test_summary")), WithSynchronizer(&memSynchronizer{}))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	summary := sf.Summary()
+	assert.Equal(t, sf.NodeId(), summary.NodeId)
+	assert.Empty(t, summary.NodeIdKey)
+	assert.Empty(t, summary.DeployType)
+	assert.Empty(t, summary.IP)
+	assert.Equal(t, DefaultSyncInterval, summary.SyncInterval)
+}
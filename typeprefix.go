@@ -0,0 +1,60 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 类型前缀
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GuoxinL/snowflake-gorm/nodeid"
+	"github.com/bwmarrin/snowflake"
+)
+
+// ErrInvalidTypePrefix 类型前缀配置无效
+var ErrInvalidTypePrefix = errors.New("invalid snowflake type prefix")
+
+// resolveTypePrefix 校验类型前缀并计算其节点ID范围
+// @param bits 类型前缀的位数
+// @param value 类型前缀
+// @return nodeid.NodeIdRange 类型前缀的节点ID范围
+// @return error
+func resolveTypePrefix(bits uint8, value int64) (nodeid.NodeIdRange, error) {
+	if bits >= snowflake.NodeBits {
+		return nodeid.NodeIdRange{}, fmt.Errorf("%w: type bits %d must be less than node bits %d",
+			ErrInvalidTypePrefix, bits, snowflake.NodeBits)
+	}
+	if value < 0 || value >= 1<<bits {
+		return nodeid.NodeIdRange{}, fmt.Errorf("%w: type %d is out of range [0, %d]",
+			ErrInvalidTypePrefix, value, int64(1)<<bits-1)
+	}
+
+	restBits := snowflake.NodeBits - bits
+	first := value << restBits
+	return nodeid.NodeIdRange{Min: first, Max: first + 1<<restBits - 1}, nil
+}
+
+// ParseIDWithTypeBits 按snowflake包当前的全局位布局拆分雪花ID，并将节点ID依次拆分为类型前缀、数据中心ID与工作节点ID
+// @param id
+// @param typeBits 节点ID中类型前缀的位数，0时Type为0
+// @param datacenterBits 节点ID中数据中心ID的位数，0时Datacenter为0
+// @return ParsedID
+func ParseIDWithTypeBits(id snowflake.ID, typeBits, datacenterBits uint8) ParsedID {
+	parsed := ParseID(id)
+	restBits := snowflake.NodeBits - typeBits
+	workerBits := restBits - datacenterBits
+	parsed.Type = parsed.Node >> restBits
+	parsed.Datacenter = (parsed.Node & (int64(1)<<restBits - 1)) >> workerBits
+	parsed.Worker = parsed.Node & (int64(1)<<workerBits - 1)
+	return parsed
+}
+
+// TypePrefix 类型前缀，未配置WithTypePrefix时返回0
+// @return int64
+func (w *Wrapper) TypePrefix() int64 {
+	return w.typeValue
+}
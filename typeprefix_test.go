@@ -0,0 +1,92 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake 类型前缀测试
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_TypePrefix 测试生成的ID中节点ID的高位为类型前缀，Parse能正确拆分
+func TestWrapper_TypePrefix(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_type_prefix", 8080, time.Second,
+		5*time.Second, logger, WithTypePrefix(2, 3))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	restBits := snowflake.NodeBits - 2
+	assert.Equal(t, int64(3), sf.TypePrefix())
+	assert.Equal(t, int64(3), sf.NodeId()>>restBits)
+
+	id := sf.Generate()
+	assert.Equal(t, int64(3), id.Node()>>restBits)
+	parsed := sf.Parse(id)
+	assert.Equal(t, int64(3), parsed.Type)
+	assert.Zero(t, parsed.Datacenter)
+	assert.Equal(t, sf.NodeId()&(1<<restBits-1), parsed.Worker)
+	assert.Equal(t, parsed.Type<<restBits|parsed.Worker, parsed.Node)
+}
+
+// TestWrapper_TypePrefix_Datacenter 测试同时配置类型前缀与数据中心时，数据中心ID位于类型前缀之后
+func TestWrapper_TypePrefix_Datacenter(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_type_prefix", 8080, time.Second,
+		5*time.Second, logger, WithTypePrefix(2, 1), WithDatacenterBits(3), WithDatacenterID(5))
+	require.NoError(t, err)
+	defer sf.Close()
+
+	restBits := snowflake.NodeBits - 2
+	workerBits := restBits - 3
+	parsed := sf.Parse(sf.Generate())
+	assert.Equal(t, int64(1), parsed.Type)
+	assert.Equal(t, int64(5), parsed.Datacenter)
+	assert.Equal(t, sf.NodeId()&(1<<workerBits-1), parsed.Worker)
+	assert.Equal(t, parsed.Type<<restBits|parsed.Datacenter<<workerBits|parsed.Worker, parsed.Node)
+}
+
+// TestWrapper_TypePrefix_Invalid 测试类型前缀越界或位数过多时创建失败
+func TestWrapper_TypePrefix_Invalid(t *testing.T) {
+	for _, opts := range [][]OptionFn{
+		{WithTypePrefix(2, 4)},
+		{WithTypePrefix(2, -1)},
+		{WithTypePrefix(snowflake.NodeBits, 0)},
+	} {
+		sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_type_prefix", 8080, time.Second,
+			5*time.Second, logger, opts...)
+		assert.Nil(t, sf)
+		assert.True(t, errors.Is(err, ErrInvalidTypePrefix))
+	}
+
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_type_prefix", 8080, time.Second,
+		5*time.Second, logger, WithTypePrefix(5, 0), WithDatacenterBits(5), WithDatacenterID(0))
+	assert.Nil(t, sf)
+	assert.True(t, errors.Is(err, ErrInvalidDatacenter))
+}
+
+// TestParseIDWithTypeBits 测试拆分类型前缀、数据中心ID与工作节点ID
+func TestParseIDWithTypeBits(t *testing.T) {
+	restBits := snowflake.NodeBits - 2
+	workerBits := restBits - 3
+	node := int64(2)<<restBits | int64(6)<<workerBits | 17
+	id := snowflake.ID(int64(1)<<(snowflake.NodeBits+snowflake.StepBits) | node<<snowflake.StepBits | 9)
+
+	parsed := ParseIDWithTypeBits(id, 2, 3)
+	assert.Equal(t, node, parsed.Node)
+	assert.Equal(t, int64(2), parsed.Type)
+	assert.Equal(t, int64(6), parsed.Datacenter)
+	assert.Equal(t, int64(17), parsed.Worker)
+	assert.Equal(t, int64(9), parsed.Step)
+
+	// 不配置类型前缀时与ParseIDWithDatacenterBits一致
+	assert.Equal(t, ParseIDWithDatacenterBits(id, 3), ParseIDWithTypeBits(id, 0, 3))
+}
@@ -0,0 +1,39 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake ULID格式的ID
+package snowflake
+
+import (
+	"encoding/binary"
+
+	"github.com/bwmarrin/snowflake"
+	"github.com/oklog/ulid/v2"
+)
+
+// GenerateULID 生成一个雪花ID并转换为ULID
+// 时间部分为雪花ID中的毫秒时间戳，随机部分由节点ID与序列号确定地填充，
+// 因此ULID在节点间唯一，且与雪花ID一样按时间有序
+// @return ulid.ULID
+func (w *Wrapper) GenerateULID() ulid.ULID {
+	return ToULID(w.Generate())
+}
+
+// ToULID 将雪花ID转换为ULID，同一个雪花ID总是得到相同的ULID
+// 依赖snowflake包当前的全局位布局，转换与生成时的位布局需保持一致
+// @param id
+// @return ulid.ULID
+func ToULID(id snowflake.ID) ulid.ULID {
+	var u ulid.ULID
+	_ = u.SetTime(uint64(id.Time()))
+
+	// 随机部分共10字节，低位写入节点ID与序列号，高位补0
+	var entropy [10]byte
+	mask := uint64(1)<<(snowflake.NodeBits+snowflake.StepBits) - 1
+	binary.BigEndian.PutUint64(entropy[2:], uint64(id)&mask)
+	_ = u.SetEntropy(entropy[:])
+	return u
+}
@@ -0,0 +1,71 @@
+//
+// Copyright (C) BABEC. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package snowflake ULID格式的ID测试
+package snowflake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrapper_GenerateULID 测试ULID随时间单调递增，且时间部分与雪花ID一致
+func TestWrapper_GenerateULID(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_ulid", 8080, time.Second, 5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	id := sf.Generate()
+	converted := ToULID(id)
+	assert.Equal(t, uint64(id.Time()), converted.Time())
+	assert.Equal(t, converted, ToULID(id))
+
+	previous := sf.GenerateULID()
+	assert.Equal(t, 1, previous.Compare(converted))
+	for i := 0; i < 10000; i++ {
+		current := sf.GenerateULID()
+		require.Equal(t, 1, current.Compare(previous), "ulid %s should be greater than %s", current, previous)
+		previous = current
+	}
+}
+
+// TestWrapper_GenerateULID_Parallel 测试并发生成的ULID不重复
+func TestWrapper_GenerateULID_Parallel(t *testing.T) {
+	sf, err := NewSnowflake(context.Background(), setupTestDB(t), "test_ulid_parallel", 8080, time.Second,
+		5*time.Second, logger)
+	require.NoError(t, err)
+	defer sf.Close()
+
+	const goroutines, perGoroutine = 8, 2000
+	var (
+		mu   sync.Mutex
+		seen = make(map[ulid.ULID]struct{}, goroutines*perGoroutine)
+		wg   sync.WaitGroup
+	)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids := make([]ulid.ULID, 0, perGoroutine)
+			for i := 0; i < perGoroutine; i++ {
+				ids = append(ids, sf.GenerateULID())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				seen[id] = struct{}{}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, goroutines*perGoroutine)
+}